@@ -19,17 +19,24 @@ var helpfilename = "GinClientHelp.md"
 var order = []string{
 	"login",
 	"logout",
+	"tokens",
 	"create",
+	"delete",
 	"init",
 	"get",
+	"fork",
 	"download",
 	"upload",
+	"resolve",
+	"shelve",
+	"unshelve",
 	"ls",
 	"get-content",
 	"remove-content",
 	"lock",
 	"unlock",
 	"commit",
+	"undo",
 	"add-remote",
 	"remove-remote",
 	"use-remote",
@@ -38,13 +45,19 @@ var order = []string{
 	"remove-server",
 	"use-server",
 	"servers",
+	"hostkeys",
+	"config",
+	"issues",
 	"version",
+	"log",
+	"diff",
 }
 
 var skip = []string{
-	"delete",
 	"git",
 	"annex",
+	"git-credential",
+	"ssh-proxy",
 }
 
 var mdTemplate = `## {{ .Short }}
@@ -123,6 +136,15 @@ func gendoc(cmd *cobra.Command) string {
 	return rendered.String()
 }
 
+// gendocs generates the help text for a command followed by the help text of its subcommands.
+func gendocs(cmd *cobra.Command) string {
+	doc := gendoc(cmd)
+	for _, subcmd := range cmd.Commands() {
+		doc += gendoc(subcmd)
+	}
+	return doc
+}
+
 func main() {
 
 	verinfo := gincmd.VersionInfo{Git: "5", Annex: "7"}
@@ -156,13 +178,13 @@ The same information can be obtained from the client with the commands
 	// Generate ordered entries first
 	for _, name := range order {
 		subcmd := cmdmap[name]
-		buf.WriteString(gendoc(subcmd))
+		buf.WriteString(gendocs(subcmd))
 		delete(cmdmap, name)
 	}
 
 	// Generate any remaining subcommands
 	for name, subcmd := range cmdmap {
-		buf.WriteString(gendoc(subcmd))
+		buf.WriteString(gendocs(subcmd))
 		delete(cmdmap, name)
 	}
 
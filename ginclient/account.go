@@ -0,0 +1,171 @@
+package ginclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/G-Node/gin-cli/ginclient/log"
+	"github.com/G-Node/gin-cli/web"
+	gogs "github.com/gogits/go-gogs-client"
+)
+
+// Functions for retrieving account details and editing the user's profile.
+
+// AccountDetails holds the profile of a user together with a summary of the account.
+// For accounts other than the logged in user's, only publicly visible information is included.
+type AccountDetails struct {
+	gogs.User
+	// Repositories is the number of repositories owned by the user. It is -1 if the repositories could not be retrieved.
+	Repositories int `json:"repositories"`
+	// StorageUsed is the total size of the repositories owned by the user in bytes, as reported by the server.
+	// It is -1 if the repositories could not be retrieved.
+	StorageUsed int64 `json:"storage_used"`
+	// Organisations are the usernames of the organisations the user is a member of. It is nil if the organisations could not be retrieved.
+	Organisations []string `json:"organisations"`
+	// Keys is the number of public keys of the account. It is -1 if the keys could not be retrieved.
+	Keys int `json:"keys"`
+}
+
+// ListUserOrgs gets the organisations that the user with the given username is a member of.
+// For the logged in user, private memberships are included.
+// All pages of the list are retrieved.
+func (gincl *Client) ListUserOrgs(username string) ([]gogs.Organization, error) {
+	fn := fmt.Sprintf("ListUserOrgs(%s)", username)
+	address := fmt.Sprintf("/api/v1/users/%s/orgs", username)
+	if username == gincl.Username {
+		address = "/api/v1/user/orgs"
+	}
+	var orgs []gogs.Organization
+	handler := func(res *http.Response, b []byte) (int, error) {
+		switch code := res.StatusCode; {
+		case code == http.StatusNotFound:
+			return 0, ginerror{UError: res.Status, Origin: fn, Description: fmt.Sprintf("user '%s' does not exist", username)}
+		case code == http.StatusUnauthorized:
+			return 0, ginerror{UError: res.Status, Origin: fn, Description: "authorisation failed"}
+		case code == http.StatusInternalServerError:
+			return 0, ginerror{UError: res.Status, Origin: fn, Description: "server error"}
+		case code != http.StatusOK:
+			return 0, ginerror{UError: res.Status, Origin: fn} // Unexpected error
+		}
+		var page []gogs.Organization
+		if err := json.Unmarshal(b, &page); err != nil {
+			return 0, ginerror{UError: err.Error(), Origin: fn, Description: "failed to parse response body"}
+		}
+		orgs = append(orgs, page...)
+		return len(page), nil
+	}
+	if err := gincl.GetPages(gincl.ctx, address, handler); err != nil {
+		return nil, err
+	}
+	return orgs, nil
+}
+
+// listPublicKeys gets the public keys of the user with the given username.
+// All pages of the list are retrieved.
+func (gincl *Client) listPublicKeys(username string) ([]gogs.PublicKey, error) {
+	fn := fmt.Sprintf("listPublicKeys(%s)", username)
+	var keys []gogs.PublicKey
+	handler := func(res *http.Response, b []byte) (int, error) {
+		if res.StatusCode != http.StatusOK {
+			return 0, ginerror{UError: res.Status, Origin: fn, Description: "failed to retrieve public keys"}
+		}
+		var page []gogs.PublicKey
+		if err := json.Unmarshal(b, &page); err != nil {
+			return 0, ginerror{UError: err.Error(), Origin: fn, Description: "failed to parse response body"}
+		}
+		keys = append(keys, page...)
+		return len(page), nil
+	}
+	if err := gincl.GetPages(gincl.ctx, fmt.Sprintf("/api/v1/users/%s/keys", username), handler); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// GetAccountDetails retrieves the profile of the user with the given username and summarises the user's repositories, organisations, and keys.
+// The summary only includes the repositories visible to the logged in user.
+// Only a failure to retrieve the profile is returned as an error: parts of
+// the summary that can not be retrieved (e.g., without logging in) are left empty.
+func (gincl *Client) GetAccountDetails(username string) (AccountDetails, error) {
+	var details AccountDetails
+	user, err := gincl.RequestAccount(username)
+	if err != nil {
+		return details, err
+	}
+	details.User = user
+
+	if repos, err := gincl.ListRepos(user.UserName); err != nil {
+		log.Write("Failed to retrieve repositories of %s: %v", user.UserName, err)
+		details.Repositories, details.StorageUsed = -1, -1
+	} else {
+		for _, repo := range repos {
+			if repo.Owner != nil && strings.EqualFold(repo.Owner.UserName, user.UserName) {
+				details.Repositories++
+				details.StorageUsed += repo.Size
+			}
+		}
+	}
+
+	if orgs, err := gincl.ListUserOrgs(user.UserName); err != nil {
+		log.Write("Failed to retrieve organisations of %s: %v", user.UserName, err)
+	} else {
+		details.Organisations = make([]string, len(orgs))
+		for idx, org := range orgs {
+			details.Organisations[idx] = org.UserName
+		}
+	}
+
+	var keys []gogs.PublicKey
+	if user.UserName == gincl.Username {
+		keys, err = gincl.GetUserKeys()
+	} else {
+		keys, err = gincl.listPublicKeys(user.UserName)
+	}
+	if err != nil {
+		// keys may not be public on all servers
+		log.Write("Failed to retrieve keys of %s: %v", user.UserName, err)
+		details.Keys = -1
+	} else {
+		details.Keys = len(keys)
+	}
+	return details, nil
+}
+
+// ProfileOptions holds the profile fields to change. Empty fields are not changed.
+type ProfileOptions struct {
+	FullName string `json:"full_name,omitempty"`
+	Email    string `json:"email,omitempty"`
+}
+
+// EditProfile changes the profile of the logged in user and returns the updated account.
+func (gincl *Client) EditProfile(opts ProfileOptions) (gogs.User, error) {
+	fn := "EditProfile()"
+	log.Write("Editing profile: %+v", opts)
+	var user gogs.User
+	if opts.FullName == "" && opts.Email == "" {
+		return user, ginerror{UError: "no changes", Origin: fn, Description: "no profile fields to change"}
+	}
+	res, err := gincl.Patch(gincl.ctx, "/api/v1/user", opts)
+	if err != nil {
+		return user, err // return error from Patch() directly
+	}
+	defer web.CloseRes(res.Body)
+	switch code := res.StatusCode; {
+	case code == http.StatusNotFound || code == http.StatusMethodNotAllowed:
+		return user, ginerror{UError: res.Status, Origin: fn, Description: fmt.Sprintf("the server does not support editing the profile; use the settings page of the web interface (%s/user/settings)", gincl.WebAddress())}
+	case code == http.StatusUnprocessableEntity:
+		return user, ginerror{UError: res.Status, Origin: fn, Description: "invalid profile settings (the email address may be invalid or already in use)"}
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		return user, ginerror{UError: res.Status, Origin: fn, Description: "authorisation failed"}
+	case code == http.StatusInternalServerError:
+		return user, ginerror{UError: res.Status, Origin: fn, Description: "server error"}
+	case code != http.StatusOK:
+		return user, ginerror{UError: res.Status, Origin: fn} // Unexpected error
+	}
+	if err = json.NewDecoder(res.Body).Decode(&user); err != nil {
+		return user, ginerror{UError: err.Error(), Origin: fn, Description: "failed to parse response body"}
+	}
+	return user, nil
+}
@@ -0,0 +1,70 @@
+package ginclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/G-Node/gin-cli/web"
+	gogs "github.com/gogits/go-gogs-client"
+)
+
+func TestGetAccountDetails(t *testing.T) {
+	alice := &gogs.User{ID: 1, UserName: "alice", FullName: "Alice"}
+	// listing repositories, organisations, and keys requires a token
+	authorised := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/users/alice" {
+			json.NewEncoder(w).Encode(alice)
+			return
+		}
+		if !authorised {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Query().Get("page") > "1" {
+			w.Write([]byte("[]"))
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v1/users/alice/repos":
+			json.NewEncoder(w).Encode([]gogs.Repository{
+				{Name: "ephys", Owner: alice, Size: 1024},
+				{Name: "imaging", Owner: alice, Size: 2048},
+				{Name: "shared", Owner: &gogs.User{UserName: "lab"}, Size: 4096},
+			})
+		case "/api/v1/users/alice/orgs":
+			json.NewEncoder(w).Encode([]gogs.Organization{{UserName: "lab"}})
+		case "/api/v1/users/alice/keys":
+			json.NewEncoder(w).Encode([]gogs.PublicKey{{ID: 1}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	gincl := &Client{Client: web.New(srv.URL), ctx: context.Background()}
+
+	details, err := gincl.GetAccountDetails("alice")
+	if err != nil {
+		t.Fatalf("Failed to get account details without login: %v", err)
+	}
+	if details.UserName != "alice" || details.Repositories != -1 || details.StorageUsed != -1 || details.Organisations != nil || details.Keys != -1 {
+		t.Fatalf("Unexpected account details without login: %+v", details)
+	}
+
+	authorised = true
+	details, err = gincl.GetAccountDetails("alice")
+	if err != nil {
+		t.Fatalf("Failed to get account details: %v", err)
+	}
+	if details.Repositories != 2 || details.StorageUsed != 3072 || !reflect.DeepEqual(details.Organisations, []string{"lab"}) || details.Keys != 1 {
+		t.Fatalf("Unexpected account details: %+v", details)
+	}
+
+	if _, err = gincl.GetAccountDetails("bob"); err == nil {
+		t.Fatal("Expected error for unknown user")
+	}
+}
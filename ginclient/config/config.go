@@ -5,6 +5,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"time"
 
 	"github.com/G-Node/gin-cli/ginclient/log"
 	"github.com/fatih/color"
@@ -16,6 +20,14 @@ const (
 	defaultFileName = "config.yml"
 )
 
+// Git transport protocols
+const (
+	// GitProtocolSSH is the default git transport: repositories are accessed through the git server using the session key.
+	GitProtocolSSH = "ssh"
+	// GitProtocolHTTPS accesses repositories through the web server, authenticating with the login token.
+	GitProtocolHTTPS = "https"
+)
+
 var (
 	configDirs = configdir.New("g-node", "gin")
 	yellow     = color.New(color.FgYellow).SprintFunc()
@@ -27,28 +39,45 @@ var (
 			Port:     443,
 		},
 		GitCfg{
-			Host:    "gin.g-node.org",
-			Port:    22,
-			User:    "git",
-			HostKey: "gin.g-node.org,141.84.41.216 ecdsa-sha2-nistp256 AAAAE2VjZHNhLXNoYTItbmlzdHAyNTYAAAAIbmlzdHAyNTYAAABBBE5IBgKP3nUryEFaACwY4N3jlqDx8Qw1xAxU2Xpt5V0p9RNefNnedVmnIBV6lA3n+9kT1OSbyqA/+SgsQ57nHo0=",
+			Host:     "gin.g-node.org",
+			Port:     22,
+			User:     "git",
+			Protocol: GitProtocolSSH,
+			HostKey:  "gin.g-node.org,141.84.41.216 ecdsa-sha2-nistp256 AAAAE2VjZHNhLXNoYTItbmlzdHAyNTYAAAAIbmlzdHAyNTYAAABBBE5IBgKP3nUryEFaACwY4N3jlqDx8Qw1xAxU2Xpt5V0p9RNefNnedVmnIBV6lA3n+9kT1OSbyqA/+SgsQ57nHo0=",
 		},
 	}
 
 	defaultConf = map[string]interface{}{
 		// Binaries
-		"bin.git":          "git",
-		"bin.gitannex":     "git-annex",
-		"gin.gitannexpath": "",
-		"bin.ssh":          "ssh",
+		"bin.git":      "git",
+		"bin.gitannex": "git-annex",
+		"bin.ssh":      "ssh",
 		// Annex filters
 		"annex.minsize": "10M",
 		"servers.gin":   ginDefaultServer,
 		"defaultserver": "gin",
+		// Session keys
+		"ssh.keytype": "ed25519",
+		// Credential storage
+		"credentials.backend": "file",
+		"credentials.helper":  "",
+		// Requests
+		"network.timeout": 60 * time.Second,
+		"network.retries": 3,
+		"network.backoff": time.Second,
 	}
 
 	// configuration cache: used to avoid rereading during a single command invocation
 	configuration GinCliCfg
 	set           = false
+	// origins holds the layer that supplied each value of the cached configuration that is not a default
+	origins map[string]Setting
+	// overrides holds the values set on the command line
+	overrides []Setting
+	// locked holds the keys (and sections) locked by the system configuration
+	locked []string
+	// systemServers holds the aliases of the servers defined in the system configuration
+	systemServers map[string]bool
 )
 
 // Types
@@ -58,6 +87,15 @@ type WebCfg struct {
 	Protocol string
 	Host     string
 	Port     uint16
+	// CABundle is a file with additional certificate authorities to trust for the server's certificate.
+	CABundle string
+	// Insecure disables verification of the server's certificate.
+	Insecure bool
+	// ClientCert and ClientKey are the certificate and key files used for TLS client authentication.
+	ClientCert string
+	ClientKey  string
+	// Proxy is the URL of the proxy used for connections to the server (web and git).
+	Proxy string
 }
 
 // AddressStr constructs a full address string from the configuration.
@@ -72,6 +110,12 @@ type GitCfg struct {
 	Host    string
 	Port    uint16
 	HostKey string
+	// Protocol is the transport used for git operations ('ssh' or 'https'). Empty means 'ssh'.
+	Protocol string
+	// IdentityFile is a private key file to use for the server instead of the key created on login.
+	IdentityFile string
+	// UseAgent enables offering the keys held by the SSH agent to the server.
+	UseAgent bool
 }
 
 // AddressStr constructs a full address string from the configuration.
@@ -80,12 +124,26 @@ func (c GitCfg) AddressStr() string {
 	return fmt.Sprintf("ssh://%s@%s:%d", c.User, c.Host, c.Port)
 }
 
+// UseHTTPS returns true if git operations should use HTTPS instead of SSH.
+func (c GitCfg) UseHTTPS() bool {
+	return c.Protocol == GitProtocolHTTPS
+}
+
 // ServerCfg holds the information required for GIN servers (web and git).
 type ServerCfg struct {
 	Web WebCfg
 	Git GitCfg
 }
 
+// GitAddressStr returns the address used for git operations.
+// For servers that use the HTTPS protocol for git, this is the address of the web server, otherwise it is the SSH address of the git server.
+func (c ServerCfg) GitAddressStr() string {
+	if c.Git.UseHTTPS() {
+		return c.Web.AddressStr()
+	}
+	return c.Git.AddressStr()
+}
+
 // BinCfg holds the paths to the external binaries that the client depends on.
 type BinCfg struct {
 	Git          string
@@ -100,15 +158,46 @@ type AnnexCfg struct {
 	MinSize string
 }
 
+// SSHCfg holds the options for the SSH keys used for git operations.
+type SSHCfg struct {
+	// KeyType is the type of session keys created on login ('ed25519' or 'rsa').
+	KeyType string
+}
+
+// CredentialsCfg selects the storage backend for credentials (login tokens and private keys).
+type CredentialsCfg struct {
+	// Backend is one of 'file', 'encrypted', or 'helper'.
+	Backend string
+	// Helper is the external program used by the 'helper' backend.
+	Helper string
+}
+
+// NetworkCfg holds the timeout and retry settings for requests to the web servers.
+type NetworkCfg struct {
+	// Timeout is the time limit for each attempt of a request.
+	Timeout time.Duration
+	// Retries is the number of times a failed request is repeated (only for requests that can safely be repeated).
+	Retries int
+	// Backoff is the time to wait before the first retry. It is doubled for each subsequent retry.
+	Backoff time.Duration
+}
+
 // GinCliCfg holds the client configuration values.
 type GinCliCfg struct {
 	Servers       map[string]ServerCfg
 	DefaultServer string
 	Bin           BinCfg
 	Annex         AnnexCfg
+	SSH           SSHCfg
+	Credentials   CredentialsCfg
+	Network       NetworkCfg
 }
 
 // Read loads in the configuration from the config file(s), merges any defined values into the default configuration, and returns a populated GinConfiguration struct.
+// Values are merged in order of precedence (see Layers): the defaults are
+// overridden by the system configuration file, the user configuration file, the configuration file in the
+// repository root, GIN_* environment variables, and values set on the command
+// line (SetOverride). The layer that supplied each value is recorded (see GetValue).
 // The configuration is cached. Subsequent reads reuse the already loaded configuration.
 func Read() GinCliCfg {
 	if set {
@@ -116,36 +205,66 @@ func Read() GinCliCfg {
 	}
 	viper.Reset()
 	viper.SetTypeByDefaultValue(true)
+	origins = make(map[string]Setting)
 
 	for k, v := range defaultConf {
+		if srvcfg, ok := v.(ServerCfg); ok {
+			setServerDefaults(k, srvcfg)
+			continue
+		}
 		viper.SetDefault(k, v)
 	}
 
-	// Merge in user config file
-	confpath, _ := Path(false)
-	confpath = filepath.Join(confpath, defaultFileName)
-	viper.SetConfigFile(confpath)
-	cerr := viper.MergeInConfig()
-	if cerr == nil {
+	locked = nil
+	systemServers = make(map[string]bool)
+	// Merge in system config file, user config file, and the configuration file in the repository root
+	for _, scope := range []string{ScopeSystem, ScopeGlobal, ScopeRepo} {
+		confpath, err := scopeFile(scope)
+		if err != nil || !pathExists(confpath) {
+			continue
+		}
+		values, err := readFile(confpath)
+		if err != nil {
+			log.Write("Failed to read config file: %v", err)
+			continue
+		}
 		log.Write("Found config file %s", confpath)
+		migrateFile(scope, confpath, values)
+		delete(values, "version")
+		switch scope {
+		case ScopeSystem:
+			readLocks(values)
+		case ScopeRepo:
+			removeUntrustedValues(values, confpath)
+		}
+		if scope != ScopeSystem {
+			removeLockedValues(values, confpath)
+		}
+		viper.MergeConfigMap(values)
+		for _, key := range flatten(values, "", nil) {
+			origins[key] = Setting{Key: key, Origin: scope, Source: confpath}
+		}
 	}
 
-	viper.Unmarshal(&configuration)
-
-	removeInvalidServerConfs()
+	applyEnv()
 
-	// configuration file in the repository root (annex excludes and size threshold only)
-	reporoot, err := findreporoot(".")
-	if err == nil {
-		confpath := filepath.Join(reporoot, defaultFileName)
-		viper.SetConfigFile(confpath)
-		cerr = viper.MergeInConfig()
-		if cerr == nil {
-			log.Write("Found config file %s", confpath)
+	for _, o := range overrides {
+		if isLocked(o.Key) {
+			continue
 		}
+		viper.Set(o.Key, o.Value)
+		origins[o.Key] = o
 	}
-	configuration.Annex.Exclude = viper.GetStringSlice("annex.exclude")
-	configuration.Annex.MinSize = viper.GetString("annex.minsize")
+
+	for key, setting := range origins {
+		log.Write("Config value %s set by %s", key, setting.OriginStr())
+	}
+
+	// start from an empty configuration: unmarshalling merges into existing maps, which would keep removed servers
+	configuration = GinCliCfg{}
+	viper.Unmarshal(&configuration)
+
+	removeInvalidServerConfs()
 
 	// if Bin.GitAnnex is set but Bin.GitAnnexPath is not, set the path
 	if configuration.Bin.GitAnnexPath == "" && configuration.Bin.GitAnnex != "" {
@@ -157,11 +276,24 @@ func Read() GinCliCfg {
 	return configuration
 }
 
+// setServerDefaults sets the default value of each option of a server
+// configuration individually, so that server options set in a configuration
+// file are merged with the defaults instead of replacing them.
+func setServerDefaults(key string, srvcfg ServerCfg) {
+	for section, cfg := range map[string]interface{}{"web": srvcfg.Web, "git": srvcfg.Git} {
+		value := reflect.ValueOf(cfg)
+		for idx := 0; idx < value.NumField(); idx++ {
+			name := strings.ToLower(value.Type().Field(idx).Name)
+			viper.SetDefault(fmt.Sprintf("%s.%s.%s", key, section, name), value.Field(idx).Interface())
+		}
+	}
+}
+
 func removeInvalidServerConfs() {
 	// Check server configurations for invalid names and port numbers
 	for alias := range viper.GetStringMap("servers") {
 		if alias == "dir" {
-			fmt.Fprintf(color.Error, "%s server alias '%s' is not allowed (reserved word): server configuration ignored\n", yellow("[warning]"), alias)
+			warnOnce(fmt.Sprintf("server alias '%s' is not allowed (reserved word): server configuration ignored", alias))
 			delete(configuration.Servers, alias)
 			continue
 		}
@@ -169,12 +301,21 @@ func removeInvalidServerConfs() {
 		gitport := viper.GetInt(fmt.Sprintf("servers.%s.git.port", alias))
 		if webport < 0 || webport > 65535 || gitport < 0 || gitport > 65535 {
 			if alias == "gin" {
-				fmt.Fprintf(color.Error, "%s invalid value found in configuration for '%s': using default\n", yellow("[warning]"), alias)
+				warnOnce(fmt.Sprintf("invalid value found in configuration for '%s': using default", alias))
 				configuration.Servers["gin"] = ginDefaultServer
 			} else {
-				fmt.Fprintf(color.Error, "%s invalid value found in configuration for '%s': server configuration ignored\n", yellow("[warning]"), alias)
+				warnOnce(fmt.Sprintf("invalid value found in configuration for '%s': server configuration ignored", alias))
 				delete(configuration.Servers, alias)
 			}
+			continue
+		}
+		srvcfg := configuration.Servers[alias]
+		switch srvcfg.Git.Protocol {
+		case "", GitProtocolSSH, GitProtocolHTTPS:
+		default:
+			warnOnce(fmt.Sprintf("unknown git protocol '%s' in configuration for '%s': using %s", srvcfg.Git.Protocol, alias, GitProtocolSSH))
+			srvcfg.Git.Protocol = GitProtocolSSH
+			configuration.Servers[alias] = srvcfg
 		}
 	}
 }
@@ -190,15 +331,16 @@ func SetConfig(key string, value interface{}) error {
 		return err
 	}
 	confpath = filepath.Join(confpath, defaultFileName)
-	v := viper.New()
-	v.SetConfigFile(confpath)
-
-	v.ReadInConfig()
-	v.Set(key, value)
-	v.WriteConfig()
-	// invalidate the read cache
-	set = false
-	return nil
+	values, err := readFileForUpdate(confpath)
+	if err != nil {
+		return err
+	}
+	key = strings.ToLower(key)
+	if IsLocked(key) {
+		return lockedError(key)
+	}
+	setNested(values, key, value)
+	return writeFile(confpath, values)
 }
 
 // AddServerConf writes a new server configuration into the user config file.
@@ -208,25 +350,27 @@ func AddServerConf(alias string, newcfg ServerCfg) error {
 }
 
 // RmServerConf removes a server configuration from the user config file.
-func RmServerConf(alias string) {
-	var c GinCliCfg
-	v := viper.New()
-	// Merge in user config file
+// Servers defined in the system configuration can not be removed.
+func RmServerConf(alias string) error {
+	if IsSystemServer(alias) {
+		return fmt.Errorf("server '%s' is defined in the system configuration (%s) and can not be removed", alias, SystemFile())
+	}
 	confpath, _ := Path(false)
 	confpath = filepath.Join(confpath, defaultFileName)
-	v.SetConfigFile(confpath)
-	v.ReadInConfig()
-	v.Unmarshal(&c)
-	delete(c.Servers, alias)
-	v.Set("servers", c.Servers)
-	v.WriteConfig()
-	set = false
+	values, err := readFileForUpdate(confpath)
+	if err != nil {
+		return err
+	}
+	if deleteNested(values, fmt.Sprintf("servers.%s", strings.ToLower(alias))) {
+		return writeFile(confpath, values)
+	}
+	return nil
 }
 
 // SetDefaultServer writes the given name to the config file to server as the default server for web calls.
 // An error is returned if the name doesn't exist in the current configuration.
-func SetDefaultServer(alias string) {
-	SetConfig("defaultserver", alias)
+func SetDefaultServer(alias string) error {
+	return SetConfig("defaultserver", alias)
 }
 
 // Path returns the configuration path where configuration files should be stored.
@@ -247,6 +391,20 @@ func Path(create bool) (string, error) {
 	return confpath, err
 }
 
+// SystemFile returns the path of the system configuration file.
+// If the GIN_SYSTEM_CONFIG environment variable is set, its value is returned, otherwise the platform default is used
+// (/etc/gin/config.yml on Linux and other Unix systems).
+func SystemFile() string {
+	if syspath := os.Getenv("GIN_SYSTEM_CONFIG"); syspath != "" {
+		return syspath
+	}
+	switch runtime.GOOS {
+	case "windows", "darwin":
+		return filepath.Join(configDirs.QueryFolders(configdir.System)[0].Path, defaultFileName)
+	}
+	return filepath.Join("/etc", "gin", defaultFileName)
+}
+
 // Util functions //
 
 // pathExists returns true if the path exists
@@ -257,6 +415,19 @@ func pathExists(path string) bool {
 	return true
 }
 
+// ExpandHome replaces a leading ~ in a path with the user's home directory.
+func ExpandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") && !strings.HasPrefix(path, "~"+string(filepath.Separator)) {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		log.Write("Failed to determine home directory: %v", err)
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
+
 func findreporoot(path string) (string, error) {
 	var err error
 	path, err = filepath.Abs(path)
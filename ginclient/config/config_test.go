@@ -0,0 +1,417 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// nested builds a nested map of configuration values from dot separated keys.
+func nested(flat map[string]interface{}) map[string]interface{} {
+	values := make(map[string]interface{})
+	for key, value := range flat {
+		setNested(values, key, value)
+	}
+	return values
+}
+
+func checkValues(t *testing.T, values map[string]interface{}, expected map[string]interface{}) {
+	if !reflect.DeepEqual(values, nested(expected)) {
+		t.Fatalf("Unexpected values after migration:\n%v\nexpected:\n%v", values, nested(expected))
+	}
+}
+
+func TestMigrateGitAnnexPath(t *testing.T) {
+	values := nested(map[string]interface{}{"gin.gitannexpath": "/opt/annex", "bin.git": "git"})
+	changes := migrateGitAnnexPath(values)
+	checkValues(t, values, map[string]interface{}{"bin.gitannexpath": "/opt/annex", "bin.git": "git"})
+	if len(changes) != 1 {
+		t.Fatalf("Expected 1 change, got %v", changes)
+	}
+
+	// the new key takes precedence
+	values = nested(map[string]interface{}{"gin.gitannexpath": "/opt/annex", "bin.gitannexpath": "/usr/local/annex"})
+	migrateGitAnnexPath(values)
+	checkValues(t, values, map[string]interface{}{"bin.gitannexpath": "/usr/local/annex"})
+
+	values = nested(map[string]interface{}{"bin.gitannexpath": "/opt/annex"})
+	if changes := migrateGitAnnexPath(values); len(changes) != 0 {
+		t.Fatalf("Unexpected changes for current configuration: %v", changes)
+	}
+}
+
+func TestMigrateLegacyServer(t *testing.T) {
+	values := nested(map[string]interface{}{
+		"gin.address":   "https://gin.example.org",
+		"gin.port":      8443,
+		"git.address":   "git.example.org",
+		"git.port":      2222,
+		"git.user":      "git",
+		"git.hostkey":   "git.example.org ssh-ed25519 AAAA",
+		"annex.minsize": "1M",
+	})
+	changes := migrateLegacyServer(values)
+	checkValues(t, values, map[string]interface{}{
+		"servers.gin.web.protocol": "https",
+		"servers.gin.web.host":     "gin.example.org",
+		"servers.gin.web.port":     8443,
+		"servers.gin.git.host":     "git.example.org",
+		"servers.gin.git.port":     2222,
+		"servers.gin.git.user":     "git",
+		"servers.gin.git.hostkey":  "git.example.org ssh-ed25519 AAAA",
+		"annex.minsize":            "1M",
+	})
+	if len(changes) != 6 {
+		t.Fatalf("Expected 6 changes, got %v", changes)
+	}
+
+	// port in address, no scheme
+	values = nested(map[string]interface{}{"gin.address": "gin.example.org:3000"})
+	migrateLegacyServer(values)
+	checkValues(t, values, map[string]interface{}{
+		"servers.gin.web.protocol": "https",
+		"servers.gin.web.host":     "gin.example.org",
+		"servers.gin.web.port":     3000,
+	})
+
+	// existing server configuration is not replaced
+	values = nested(map[string]interface{}{"gin.address": "http://old.example.org", "servers.gin.web.host": "new.example.org"})
+	migrateLegacyServer(values)
+	checkValues(t, values, map[string]interface{}{"servers.gin.web.host": "new.example.org"})
+}
+
+func TestMigrateServerEntries(t *testing.T) {
+	values := nested(map[string]interface{}{
+		"servers.dir.web.host":     "dir.example.org",
+		"servers.dir.web.protocol": "HTTPS",
+		"servers.gin.git.protocol": "SSH",
+		"defaultserver":            "dir",
+	})
+	changes := migrateServerEntries(values)
+	checkValues(t, values, map[string]interface{}{
+		"servers.dir-server.web.host":     "dir.example.org",
+		"servers.dir-server.web.protocol": "https",
+		"servers.gin.git.protocol":        "ssh",
+		"defaultserver":                   "dir-server",
+	})
+	if len(changes) != 4 {
+		t.Fatalf("Expected 4 changes, got %v", changes)
+	}
+
+	values = nested(map[string]interface{}{"servers.gin.web.protocol": "https"})
+	if changes := migrateServerEntries(values); len(changes) != 0 {
+		t.Fatalf("Unexpected changes for current configuration: %v", changes)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	values := nested(map[string]interface{}{"gin.gitannexpath": "/opt/annex", "git.port": 2222})
+	from, changes := migrate(values)
+	if from != 0 || len(changes) != 2 {
+		t.Fatalf("Expected 2 changes from version 0, got %v from version %d", changes, from)
+	}
+	checkValues(t, values, map[string]interface{}{"bin.gitannexpath": "/opt/annex", "servers.gin.git.port": 2222, "version": CurrentVersion})
+
+	// only newer migrations are applied
+	values = nested(map[string]interface{}{"version": 1, "gin.gitannexpath": "/opt/annex"})
+	if _, changes = migrate(values); len(changes) != 0 {
+		t.Fatalf("Migration of version 0 applied to version 1 file: %v", changes)
+	}
+
+	// files of newer versions are not changed
+	values = nested(map[string]interface{}{"version": CurrentVersion + 1, "gin.gitannexpath": "/opt/annex"})
+	from, changes = migrate(values)
+	if from != CurrentVersion+1 || len(changes) != 0 || Version(values) != CurrentVersion+1 {
+		t.Fatalf("Newer file was changed: %v (version %d)", changes, Version(values))
+	}
+}
+
+func TestReadMigratesUserFile(t *testing.T) {
+	confdir := t.TempDir()
+	os.Setenv("GIN_CONFIG_DIR", confdir)
+	defer os.Unsetenv("GIN_CONFIG_DIR")
+	os.Setenv("GIN_SYSTEM_CONFIG", filepath.Join(confdir, "system.yml"))
+	defer os.Unsetenv("GIN_SYSTEM_CONFIG")
+	cwd, _ := os.Getwd()
+	os.Chdir(confdir)
+	defer os.Chdir(cwd)
+
+	confpath := filepath.Join(confdir, defaultFileName)
+	legacy := "# old configuration\ngin:\n  address: http://gin.example.org\n  port: 3000\n  gitannexpath: /opt/annex\n"
+	if err := ioutil.WriteFile(confpath, []byte(legacy), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %s", err.Error())
+	}
+	set = false
+	conf := Read()
+	web := conf.Servers["gin"].Web
+	if web.Protocol != "http" || web.Host != "gin.example.org" || web.Port != 3000 {
+		t.Fatalf("Legacy server configuration not migrated: %+v", web)
+	}
+	if conf.Servers["gin"].Git.Host != ginDefaultServer.Git.Host {
+		t.Fatalf("Default git server configuration not kept: %+v", conf.Servers["gin"].Git)
+	}
+	if conf.Bin.GitAnnexPath != "/opt/annex" {
+		t.Fatalf("Legacy git-annex path not migrated: %q", conf.Bin.GitAnnexPath)
+	}
+
+	backup, err := ioutil.ReadFile(confpath + ".v0.bak")
+	if err != nil || string(backup) != legacy {
+		t.Fatalf("Previous config file not backed up: %q (%v)", backup, err)
+	}
+	if info, err := os.Stat(confpath); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("Config file permissions not kept: %v", info.Mode())
+	}
+	values, err := readFile(confpath)
+	if err != nil || Version(values) != CurrentVersion {
+		t.Fatalf("Upgraded config file has version %d (%v)", Version(values), err)
+	}
+	if _, ok := getNested(values, "gin.address"); ok {
+		t.Fatal("Legacy key still present in upgraded config file")
+	}
+
+	// current files are not rewritten
+	os.Remove(confpath + ".v0.bak")
+	set = false
+	Read()
+	if _, err := os.Stat(confpath + ".v0.bak"); !os.IsNotExist(err) {
+		t.Fatal("Current config file was migrated again")
+	}
+}
+
+// testConfigFiles sets up empty configuration directories for the system and user configuration files and changes into a new repository (with a .git directory).
+// The user configuration directory and the repository root are returned.
+func testConfigFiles(t *testing.T) (confdir, reporoot string) {
+	confdir, reporoot = t.TempDir(), t.TempDir()
+	os.Setenv("GIN_CONFIG_DIR", confdir)
+	os.Setenv("GIN_SYSTEM_CONFIG", filepath.Join(confdir, "system.yml"))
+	cwd, _ := os.Getwd()
+	os.Mkdir(filepath.Join(reporoot, ".git"), 0777)
+	os.Chdir(reporoot)
+	t.Cleanup(func() {
+		os.Unsetenv("GIN_CONFIG_DIR")
+		os.Unsetenv("GIN_SYSTEM_CONFIG")
+		os.Chdir(cwd)
+		overrides = nil
+		set = false
+	})
+	set = false
+	return confdir, reporoot
+}
+
+func TestSetConfigKeepsOverrides(t *testing.T) {
+	confdir, _ := testConfigFiles(t)
+	confpath := filepath.Join(confdir, defaultFileName)
+	userconf := "servers:\n  test:\n    web:\n      host: web.example.org\n      port: 3000\n    git:\n      host: git.example.org\n      port: 2222\n"
+	if err := ioutil.WriteFile(confpath, []byte(userconf), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %s", err.Error())
+	}
+	os.Setenv("GIN_SERVERS_TEST_WEB_PORT", "4000")
+	defer os.Unsetenv("GIN_SERVERS_TEST_WEB_PORT")
+	if err := SetOverride("servers.test.git.user", "override"); err != nil {
+		t.Fatalf("Failed to set override: %v", err)
+	}
+	srvcfg := Read().Servers["test"]
+	if srvcfg.Web.Port != 4000 || srvcfg.Git.User != "override" {
+		t.Fatalf("Overrides not applied: %+v", srvcfg)
+	}
+
+	if err := SetConfig("servers.test.git.hostkey", "git.example.org ssh-ed25519 AAAA"); err != nil {
+		t.Fatalf("SetConfig failed: %v", err)
+	}
+	values, err := readFile(confpath)
+	if err != nil {
+		t.Fatalf("Failed to read config file: %v", err)
+	}
+	expected := map[string]interface{}{
+		"servers.test.web.host":    "web.example.org",
+		"servers.test.web.port":    3000,
+		"servers.test.git.host":    "git.example.org",
+		"servers.test.git.port":    2222,
+		"servers.test.git.hostkey": "git.example.org ssh-ed25519 AAAA",
+	}
+	for key, value := range expected {
+		if v, _ := getNested(values, key); v != value {
+			t.Errorf("Unexpected value for %s in config file: %v (expected %v)", key, v, value)
+		}
+	}
+	if v, ok := getNested(values, "servers.test.git.user"); ok {
+		t.Errorf("Command line override written to config file: %v", v)
+	}
+	if keys := flatten(values, "", nil); len(keys) > len(expected)+1 { // +1 for the version
+		t.Errorf("Unexpected keys written to config file: %v", keys)
+	}
+}
+
+// writeConfigFiles writes the system, user, and repository configuration files set up by testConfigFiles.
+func writeConfigFiles(t *testing.T, confdir, reporoot, system, global, repo string) {
+	for path, content := range map[string]string{
+		filepath.Join(confdir, "system.yml"):     system,
+		filepath.Join(confdir, defaultFileName):  global,
+		filepath.Join(reporoot, defaultFileName): repo,
+	} {
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatalf("Failed to write config file: %s", err.Error())
+		}
+	}
+}
+
+func TestPrecedence(t *testing.T) {
+	confdir, reporoot := testConfigFiles(t)
+	system := "annex:\n  minsize: 1M\nnetwork:\n  retries: 1\n  timeout: 1s\n  backoff: 1s\nssh:\n  keytype: rsa\n"
+	global := "annex:\n  minsize: 2M\nnetwork:\n  retries: 2\n  timeout: 2s\n  backoff: 2s\n"
+	repo := "annex:\n  minsize: 3M\nnetwork:\n  retries: 3\n  timeout: 3s\n"
+	writeConfigFiles(t, confdir, reporoot, system, global, repo)
+	os.Setenv("GIN_ANNEX_MINSIZE", "4M")
+	defer os.Unsetenv("GIN_ANNEX_MINSIZE")
+	os.Setenv("GIN_NETWORK_RETRIES", "4")
+	defer os.Unsetenv("GIN_NETWORK_RETRIES")
+	if err := SetOverride("annex.minsize", "5M"); err != nil {
+		t.Fatalf("Failed to set override: %v", err)
+	}
+
+	tests := []struct {
+		key    string
+		value  interface{}
+		origin string
+	}{
+		{"annex.minsize", "5M", OriginFlag},
+		{"network.retries", 4, OriginEnv},
+		{"network.timeout", "3s", ScopeRepo},
+		{"network.backoff", "2s", ScopeGlobal},
+		{"ssh.keytype", "rsa", ScopeSystem},
+		{"credentials.backend", "file", OriginDefault},
+	}
+	for _, test := range tests {
+		setting, err := GetValue("", test.key)
+		if err != nil {
+			t.Errorf("Failed to get %s: %v", test.key, err)
+			continue
+		}
+		if setting.Value != test.value || setting.Origin != test.origin {
+			t.Errorf("Unexpected value for %s: %v (%s), expected %v (%s)", test.key, setting.Value, setting.Origin, test.value, test.origin)
+		}
+	}
+}
+
+func TestRemoveUntrustedValues(t *testing.T) {
+	tests := []struct {
+		values   map[string]interface{}
+		expected map[string]interface{}
+	}{
+		{
+			map[string]interface{}{"annex.minsize": "1M", "annex.exclude": []interface{}{"*.py"}, "ssh.keytype": "rsa", "network.retries": 5},
+			map[string]interface{}{"annex.minsize": "1M", "annex.exclude": []interface{}{"*.py"}, "ssh.keytype": "rsa", "network.retries": 5},
+		},
+		{
+			map[string]interface{}{"annex.minsize": "1M", "bin.git": "/tmp/git", "credentials.helper": "evil", "defaultserver": "evil"},
+			map[string]interface{}{"annex.minsize": "1M"},
+		},
+		{
+			map[string]interface{}{"servers.gin.git.host": "evil.example.org", "servers.evil.web.host": "evil.example.org", "unknown.key": "value"},
+			map[string]interface{}{},
+		},
+	}
+	for idx, test := range tests {
+		values := nested(test.values)
+		removeUntrustedValues(values, "config.yml")
+		keys, expkeys := flatten(values, "", nil), flatten(nested(test.expected), "", nil)
+		sort.Strings(keys)
+		sort.Strings(expkeys)
+		if !reflect.DeepEqual(keys, expkeys) {
+			t.Errorf("[%d] Unexpected keys after removing untrusted values: %v, expected %v", idx, keys, expkeys)
+		}
+	}
+}
+
+func TestLockedKeys(t *testing.T) {
+	confdir, reporoot := testConfigFiles(t)
+	system := "locked:\n  - annex.minsize\n  - network\nannex:\n  minsize: 1M\nservers:\n  sys:\n    web:\n      protocol: https\n      host: web.example.org\n      port: 443\n    git:\n      host: git.example.org\n      port: 22\n      user: git\n"
+	global := "annex:\n  minsize: 2M\nnetwork:\n  retries: 2\nservers:\n  sys:\n    web:\n      host: evil.example.org\n    git:\n      hostkey: git.example.org ssh-ed25519 AAAA\n      useagent: true\n"
+	repo := "annex:\n  minsize: 3M\nnetwork:\n  timeout: 3s\n"
+	writeConfigFiles(t, confdir, reporoot, system, global, repo)
+	os.Setenv("GIN_NETWORK_BACKOFF", "9s")
+	defer os.Unsetenv("GIN_NETWORK_BACKOFF")
+	os.Setenv("GIN_SERVERS_SYS_GIT_PORT", "2222")
+	defer os.Unsetenv("GIN_SERVERS_SYS_GIT_PORT")
+
+	tests := []struct {
+		key      string
+		value    interface{}
+		locked   bool
+		override string
+	}{
+		{"annex.minsize", "1M", true, "5M"},
+		{"network.retries", 3, true, "5"},
+		{"network.timeout", "1m0s", true, "5s"},
+		{"network.backoff", "1s", true, "5s"},
+		{"servers.sys.web.host", "web.example.org", true, "evil.example.org"},
+		{"servers.sys.git.port", uint16(22), true, "2222"},
+		{"servers.sys.git.hostkey", "git.example.org ssh-ed25519 AAAA", false, ""},
+		{"servers.sys.git.useagent", true, false, ""},
+		{"servers.gin.git.port", uint16(22), false, ""},
+		{"ssh.keytype", "ed25519", false, ""},
+	}
+	for _, test := range tests {
+		if locked := IsLocked(test.key); locked != test.locked {
+			t.Errorf("IsLocked(%s) = %t, expected %t", test.key, locked, test.locked)
+		}
+		if test.override != "" {
+			if err := SetOverride(test.key, test.override); err == nil {
+				t.Errorf("Override of locked key %s accepted", test.key)
+			}
+			if err := SetConfig(test.key, test.override); err == nil {
+				t.Errorf("Locked key %s written to config file", test.key)
+			}
+		}
+		setting, err := GetValue("", test.key)
+		if err != nil {
+			t.Errorf("Failed to get %s: %v", test.key, err)
+			continue
+		}
+		if setting.Value != test.value {
+			t.Errorf("Unexpected value for %s: %v (%s), expected %v", test.key, setting.Value, setting.Origin, test.value)
+		}
+	}
+}
+
+func TestIsSystemServer(t *testing.T) {
+	confdir, reporoot := testConfigFiles(t)
+	system := "servers:\n  sys:\n    web:\n      host: web.example.org\n    git:\n      host: git.example.org\n"
+	global := "servers:\n  test:\n    web:\n      host: test.example.org\n    git:\n      host: git.test.example.org\n"
+	writeConfigFiles(t, confdir, reporoot, system, global, "")
+
+	tests := map[string]bool{
+		"sys":     true,
+		"SYS":     true,
+		"test":    false,
+		"gin":     false,
+		"unknown": false,
+	}
+	for alias, expected := range tests {
+		if IsSystemServer(alias) != expected {
+			t.Errorf("IsSystemServer(%s) = %t, expected %t", alias, !expected, expected)
+		}
+	}
+	if err := RmServerConf("sys"); err == nil {
+		t.Error("System server removed")
+	}
+}
+
+func TestReadAfterRemovingServer(t *testing.T) {
+	confdir, reporoot := testConfigFiles(t)
+	global := "servers:\n  test:\n    web:\n      host: test.example.org\n    git:\n      host: git.test.example.org\n"
+	writeConfigFiles(t, confdir, reporoot, "", global, "")
+
+	if _, ok := Read().Servers["test"]; !ok {
+		t.Fatal("Server 'test' not found in configuration")
+	}
+	if err := RmServerConf("test"); err != nil {
+		t.Fatalf("Failed to remove server: %v", err)
+	}
+	if srvcfg, ok := Read().Servers["test"]; ok {
+		t.Fatalf("Removed server still in configuration: %+v", srvcfg)
+	}
+}
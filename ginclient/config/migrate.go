@@ -0,0 +1,230 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/G-Node/gin-cli/ginclient/log"
+	"github.com/fatih/color"
+)
+
+// Functions for upgrading configuration files written for older versions of the client.
+
+// migration upgrades the values of a configuration file by one version.
+// It changes the values in place and returns a description of each change.
+type migration struct {
+	description string
+	apply       func(values map[string]interface{}) []string
+}
+
+// migrations holds the upgrade steps of the configuration format.
+// The migration at index i upgrades a file from version i to version i+1.
+// Files without a version number are version 0.
+var migrations = []migration{
+	{"move git-annex path option to the bin section", migrateGitAnnexPath},
+	{"convert single server configuration to the servers section", migrateLegacyServer},
+	{"normalise server configurations", migrateServerEntries},
+}
+
+// CurrentVersion is the version of the configuration format used by this version of the client.
+var CurrentVersion = len(migrations)
+
+// Version returns the format version of the values of a configuration file.
+func Version(values map[string]interface{}) int {
+	switch v := values["version"].(type) {
+	case int:
+		return v
+	case string:
+		version, _ := strconv.Atoi(v)
+		return version
+	}
+	return 0
+}
+
+// migrate upgrades the values of a configuration file to the current version.
+// It returns the version of the file before the upgrade and a description of each change.
+// Files of a newer version are not changed.
+func migrate(values map[string]interface{}) (int, []string) {
+	from := Version(values)
+	var changes []string
+	for version := from; version < CurrentVersion; version++ {
+		log.Write("Config migration %d: %s", version+1, migrations[version].description)
+		changes = append(changes, migrations[version].apply(values)...)
+	}
+	if from < CurrentVersion {
+		values["version"] = CurrentVersion
+	}
+	return from, changes
+}
+
+// migrateFile upgrades a configuration file that was read by Read.
+// The user configuration file is written back after saving a copy of the
+// previous file next to it, and the changes are reported. Other files are
+// only upgraded in memory: the system configuration file can not be written by
+// users and the repository configuration file is shared with others.
+// Files without any changes are not rewritten.
+func migrateFile(scope, path string, values map[string]interface{}) {
+	from, changes := migrate(values)
+	if from > CurrentVersion {
+		warnOnce(fmt.Sprintf("configuration file %s is version %d, which is newer than the version supported by this client (%d): some options may not be recognised", path, from, CurrentVersion))
+		return
+	}
+	if len(changes) == 0 {
+		return
+	}
+	if scope != ScopeGlobal {
+		for _, change := range changes {
+			log.Write("Config file %s: %s", path, change)
+		}
+		if scope == ScopeSystem {
+			warnOnce(fmt.Sprintf("system configuration file %s uses an old format (version %d): it should be updated to version %d", path, from, CurrentVersion))
+		}
+		return
+	}
+	backup := fmt.Sprintf("%s.v%d.bak", path, from)
+	if err := copyFile(path, backup); err != nil {
+		warnOnce(fmt.Sprintf("configuration file %s uses an old format but could not be upgraded: %v", path, err))
+		return
+	}
+	if err := writeFile(path, values); err != nil {
+		warnOnce(fmt.Sprintf("configuration file %s uses an old format but could not be upgraded: %v", path, err))
+		return
+	}
+	fmt.Fprintf(color.Error, ":: Configuration file %s upgraded from version %d to %d (previous file saved as %s)\n", path, from, CurrentVersion, backup)
+	for _, change := range changes {
+		fmt.Fprintf(color.Error, "   - %s\n", change)
+	}
+}
+
+// readFileForUpdate reads a configuration file that is about to be changed and upgrades its values to the current version.
+func readFileForUpdate(path string) (map[string]interface{}, error) {
+	values, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if from, _ := migrate(values); from > CurrentVersion {
+		return nil, fmt.Errorf("configuration file %s is version %d, which is newer than the version supported by this client (%d)", path, from, CurrentVersion)
+	}
+	return values, nil
+}
+
+// copyFile copies the contents and permissions of a file.
+func copyFile(src, dst string) error {
+	data, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(src); err == nil {
+		mode = info.Mode().Perm()
+	}
+	return ioutil.WriteFile(dst, data, mode)
+}
+
+// moveValue moves a value to a new key, unless the new key is already set, in which case the old value is dropped.
+// It returns a description of the change, or an empty string if the old key is not set.
+func moveValue(values map[string]interface{}, oldkey, newkey string) string {
+	value, ok := getNested(values, oldkey)
+	if !ok {
+		return ""
+	}
+	deleteNested(values, oldkey)
+	if _, exists := getNested(values, newkey); exists {
+		return fmt.Sprintf("removed '%s' ('%s' is already set)", oldkey, newkey)
+	}
+	setNested(values, newkey, value)
+	return fmt.Sprintf("moved '%s' to '%s'", oldkey, newkey)
+}
+
+// migrateGitAnnexPath moves gin.gitannexpath (used by early versions of the client) to bin.gitannexpath.
+func migrateGitAnnexPath(values map[string]interface{}) []string {
+	if change := moveValue(values, "gin.gitannexpath", "bin.gitannexpath"); change != "" {
+		return []string{change}
+	}
+	return nil
+}
+
+// migrateLegacyServer converts the configuration of the single server used
+// before multiple servers were supported (gin.address and gin.port for the web
+// server, git.address, git.port, git.user, and git.hostkey for the git server)
+// into the configuration of the server 'gin'.
+func migrateLegacyServer(values map[string]interface{}) []string {
+	var changes []string
+	if address, ok := getNested(values, "gin.address"); ok {
+		deleteNested(values, "gin.address")
+		webaddr := fmt.Sprint(address)
+		if !strings.Contains(webaddr, "://") {
+			webaddr = "https://" + webaddr
+		}
+		if u, err := url.Parse(webaddr); err == nil && u.Hostname() != "" {
+			if _, exists := getNested(values, "servers.gin.web.host"); exists {
+				changes = append(changes, "removed 'gin.address' ('servers.gin.web.host' is already set)")
+			} else {
+				setNested(values, "servers.gin.web.protocol", u.Scheme)
+				setNested(values, "servers.gin.web.host", u.Hostname())
+				changes = append(changes, fmt.Sprintf("moved 'gin.address' to 'servers.gin.web.protocol' (%s) and 'servers.gin.web.host' (%s)", u.Scheme, u.Hostname()))
+				if port, err := strconv.Atoi(u.Port()); err == nil {
+					if _, ok := getNested(values, "gin.port"); !ok {
+						setNested(values, "servers.gin.web.port", port)
+						changes = append(changes, fmt.Sprintf("moved port of 'gin.address' to 'servers.gin.web.port' (%d)", port))
+					}
+				}
+			}
+		} else {
+			changes = append(changes, fmt.Sprintf("removed invalid 'gin.address' (%s)", address))
+		}
+	}
+	legacy := []struct{ oldkey, newkey string }{
+		{"gin.port", "servers.gin.web.port"},
+		{"git.address", "servers.gin.git.host"},
+		{"git.port", "servers.gin.git.port"},
+		{"git.user", "servers.gin.git.user"},
+		{"git.hostkey", "servers.gin.git.hostkey"},
+	}
+	for _, keys := range legacy {
+		if change := moveValue(values, keys.oldkey, keys.newkey); change != "" {
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+// migrateServerEntries renames servers with the reserved alias 'dir' (which
+// were ignored) and converts protocol names to lower case.
+func migrateServerEntries(values map[string]interface{}) []string {
+	servers, ok := values["servers"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	var changes []string
+	if srvcfg, ok := servers["dir"]; ok {
+		newalias := "dir-server"
+		for idx := 2; servers[newalias] != nil; idx++ {
+			newalias = fmt.Sprintf("dir-server%d", idx)
+		}
+		delete(servers, "dir")
+		servers[newalias] = srvcfg
+		changes = append(changes, fmt.Sprintf("renamed server 'dir' to '%s' ('dir' is reserved for directory remotes)", newalias))
+		if values["defaultserver"] == "dir" {
+			values["defaultserver"] = newalias
+			changes = append(changes, fmt.Sprintf("changed 'defaultserver' to '%s'", newalias))
+		}
+	}
+	for alias := range servers {
+		for _, key := range []string{"web.protocol", "git.protocol"} {
+			fullkey := fmt.Sprintf("servers.%s.%s", alias, key)
+			value, ok := getNested(values, fullkey)
+			protocol, isstr := value.(string)
+			if !ok || !isstr || protocol == strings.ToLower(protocol) {
+				continue
+			}
+			setNested(values, fullkey, strings.ToLower(protocol))
+			changes = append(changes, fmt.Sprintf("changed '%s' from '%s' to '%s'", fullkey, protocol, strings.ToLower(protocol)))
+		}
+	}
+	return changes
+}
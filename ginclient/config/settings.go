@@ -0,0 +1,714 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/viper"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Functions for reading and writing individual configuration values (gin config).
+
+// Configuration files that can be read with GetValue and ListValues and written with SetValue and UnsetValue.
+const (
+	// ScopeSystem is the system configuration file (see SystemFile). It can not be written by the client.
+	ScopeSystem = "system"
+	// ScopeGlobal is the user configuration file in the configuration directory.
+	ScopeGlobal = "global"
+	// ScopeRepo is the configuration file at the root of the current repository.
+	ScopeRepo = "repo"
+)
+
+// Layers of the configuration, in order of increasing precedence. Values of
+// higher layers override values of lower layers. The configuration files are
+// identified by their scope (ScopeSystem, ScopeGlobal, and ScopeRepo).
+// Locked keys (see IsLocked) are only taken from the default and system layers.
+//
+//	default: built-in defaults
+//	system:  system configuration file (see SystemFile)
+//	global:  user configuration file
+//	repo:    configuration file at the root of the current repository (only keys that are safe to take from a repository)
+//	env:     GIN_* environment variables (see EnvVar)
+//	flag:    values set on the command line (see SetOverride)
+const (
+	// OriginDefault is the origin of values that are not set in any other layer.
+	OriginDefault = "default"
+	// OriginEnv is the origin of values set in environment variables.
+	OriginEnv = "env"
+	// OriginFlag is the origin of values set on the command line.
+	OriginFlag = "flag"
+)
+
+// Setting is a configuration value and the layer it was taken from.
+type Setting struct {
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+	// Origin is the layer the value was taken from: OriginDefault, the scope of a configuration file, OriginEnv, or OriginFlag.
+	Origin string `json:"origin"`
+	// Source is the path of the configuration file or the name of the environment variable the value was read from.
+	Source string `json:"source,omitempty"`
+}
+
+// OriginStr returns a description of the origin of the setting (e.g., "global: /home/alice/.config/g-node/gin/config.yml").
+func (s Setting) OriginStr() string {
+	if s.Source == "" {
+		return s.Origin
+	}
+	return fmt.Sprintf("%s: %s", s.Origin, s.Source)
+}
+
+// valueKind determines how values for a key are validated and stored.
+type valueKind int
+
+const (
+	kindString valueKind = iota
+	kindChoice
+	kindBool
+	kindCount
+	kindPort
+	kindSize
+	kindDuration
+	kindList
+	kindBinary
+	kindFile
+	kindURL
+)
+
+// keySpec describes a configuration key.
+type keySpec struct {
+	kind valueKind
+	// choices are the valid values for kindChoice keys.
+	choices []string
+	// repo is true for keys that are read from the repository configuration file.
+	// Keys that select programs, credential storage, or servers are not: repository files are shared with other users.
+	repo bool
+}
+
+// keySpecs holds all known configuration keys. Keys of server configurations use '*' in place of the server alias.
+var keySpecs = map[string]keySpec{
+	"bin.git":          {kind: kindBinary},
+	"bin.gitannex":     {kind: kindBinary},
+	"bin.gitannexpath": {kind: kindString},
+	"bin.ssh":          {kind: kindBinary},
+
+	"annex.minsize": {kind: kindSize, repo: true},
+	"annex.exclude": {kind: kindList, repo: true},
+
+	"defaultserver": {kind: kindString},
+
+	"ssh.keytype": {kind: kindChoice, choices: []string{"ed25519", "rsa"}, repo: true},
+
+	"credentials.backend": {kind: kindChoice, choices: []string{"file", "encrypted", "helper"}},
+	"credentials.helper":  {kind: kindString},
+
+	"network.timeout": {kind: kindDuration, repo: true},
+	"network.retries": {kind: kindCount, repo: true},
+	"network.backoff": {kind: kindDuration, repo: true},
+
+	"servers.*.web.protocol":   {kind: kindChoice, choices: []string{"http", "https"}},
+	"servers.*.web.host":       {kind: kindString},
+	"servers.*.web.port":       {kind: kindPort},
+	"servers.*.web.cabundle":   {kind: kindFile},
+	"servers.*.web.insecure":   {kind: kindBool},
+	"servers.*.web.clientcert": {kind: kindFile},
+	"servers.*.web.clientkey":  {kind: kindFile},
+	"servers.*.web.proxy":      {kind: kindURL},
+
+	"servers.*.git.host":         {kind: kindString},
+	"servers.*.git.port":         {kind: kindPort},
+	"servers.*.git.user":         {kind: kindString},
+	"servers.*.git.hostkey":      {kind: kindString},
+	"servers.*.git.protocol":     {kind: kindChoice, choices: []string{GitProtocolSSH, GitProtocolHTTPS}},
+	"servers.*.git.identityfile": {kind: kindFile},
+	"servers.*.git.useagent":     {kind: kindBool},
+}
+
+// sizeRE matches the sizes accepted by git-annex (e.g., 10M, 1.5GB, 500kB, 100).
+var sizeRE = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?\s*([kKMGTPE]i?[bB]?|[bB]|bytes?)?$`)
+
+// lookupSpec returns the specification of a (lower case) key and the server alias for server keys.
+func lookupSpec(key string) (keySpec, string, bool) {
+	parts := strings.Split(key, ".")
+	if len(parts) == 4 && parts[0] == "servers" {
+		spec, ok := keySpecs[strings.Join([]string{parts[0], "*", parts[2], parts[3]}, ".")]
+		return spec, parts[1], ok
+	}
+	spec, ok := keySpecs[key]
+	return spec, "", ok
+}
+
+// expandKey returns the keys for all given server aliases if the key contains a '*' in place of the alias.
+func expandKey(pattern string, aliases []string) []string {
+	if !strings.Contains(pattern, "*") {
+		return []string{pattern}
+	}
+	keys := make([]string, len(aliases))
+	for idx, alias := range aliases {
+		keys[idx] = strings.Replace(pattern, "*", alias, 1)
+	}
+	return keys
+}
+
+// splitValue splits a value given as a single string into list items (separated by commas) for list keys.
+func splitValue(spec keySpec, value string) []string {
+	if spec.kind != kindList {
+		return []string{value}
+	}
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// EnvVar returns the name of the environment variable that overrides a configuration key.
+// The name is the key in upper case, with dots (and dashes in server aliases) replaced by underscores, prefixed with GIN_ (e.g., GIN_ANNEX_MINSIZE or GIN_SERVERS_GIN_WEB_PORT).
+func EnvVar(key string) string {
+	return "GIN_" + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+}
+
+// applyEnv sets the configuration values given in environment variables.
+// Invalid values are ignored with a warning.
+func applyEnv() {
+	var aliases []string
+	for alias := range viper.GetStringMap("servers") {
+		aliases = append(aliases, alias)
+	}
+	for pattern, spec := range keySpecs {
+		for _, key := range expandKey(pattern, aliases) {
+			envvar := EnvVar(key)
+			envval, ok := os.LookupEnv(envvar)
+			if !ok {
+				continue
+			}
+			if isLocked(key) {
+				warnOnce(fmt.Sprintf("ignoring environment variable %s: %s is locked by the system configuration", envvar, key))
+				continue
+			}
+			value, err := parseValue(key, spec, splitValue(spec, envval))
+			if err != nil {
+				warnOnce(fmt.Sprintf("ignoring environment variable %s: %v", envvar, err))
+				continue
+			}
+			viper.Set(key, value)
+			origins[key] = Setting{Key: key, Value: value, Origin: OriginEnv, Source: envvar}
+		}
+	}
+}
+
+// warned holds the warnings that have already been printed.
+var warned = make(map[string]bool)
+
+// warnOnce prints a warning about the configuration, unless it was already
+// printed during this invocation (the configuration is read again when it changes).
+func warnOnce(msg string) {
+	if warned[msg] {
+		return
+	}
+	warned[msg] = true
+	fmt.Fprintf(color.Error, "%s %s\n", yellow("[warning]"), msg)
+}
+
+// readLocks takes the locked keys and the server aliases from the values of the system configuration file.
+// The list of locked keys is removed from the values.
+func readLocks(values map[string]interface{}) {
+	if list, ok := values["locked"].([]interface{}); ok {
+		for _, item := range list {
+			locked = append(locked, strings.ToLower(fmt.Sprint(item)))
+		}
+	}
+	delete(values, "locked")
+	if servers, ok := values["servers"].(map[string]interface{}); ok {
+		for alias := range servers {
+			systemServers[alias] = true
+		}
+	}
+}
+
+// userServerKeys are the server options that belong to the user rather than the server.
+// They are not locked for servers defined in the system configuration file, unless listed under 'locked'.
+var userServerKeys = map[string]bool{
+	"git.hostkey":      true,
+	"git.identityfile": true,
+	"git.useagent":     true,
+}
+
+// isLocked returns true if the (lower case) key is locked in the loaded system configuration.
+func isLocked(key string) bool {
+	for _, lockedkey := range locked {
+		if key == lockedkey || strings.HasPrefix(key, lockedkey+".") {
+			return true
+		}
+	}
+	parts := strings.SplitN(key, ".", 3)
+	if len(parts) < 2 || parts[0] != "servers" || !systemServers[parts[1]] {
+		return false
+	}
+	return len(parts) < 3 || !userServerKeys[parts[2]]
+}
+
+// IsLocked returns true if a configuration key can not be changed by the user.
+// Keys are locked by listing them (or a section that contains them) under
+// 'locked' in the system configuration file. The options of servers defined in
+// the system configuration file are locked, except for the host key, identity
+// file, and agent options (see userServerKeys).
+func IsLocked(key string) bool {
+	Read()
+	return isLocked(strings.ToLower(key))
+}
+
+// IsSystemServer returns true if the server with the given alias is defined in the system configuration file.
+// System servers can not be removed by the user and only their host key, identity file, and agent options can be changed.
+func IsSystemServer(alias string) bool {
+	Read()
+	return systemServers[strings.ToLower(alias)]
+}
+
+// lockedError returns the error for attempts to change a locked key.
+func lockedError(key string) error {
+	return fmt.Errorf("'%s' is locked by the system configuration (%s) and can not be changed", key, SystemFile())
+}
+
+// removeLockedValues removes the values of locked keys from the values of a configuration file.
+// A single warning lists the ignored keys (the options of system servers are listed by server).
+func removeLockedValues(values map[string]interface{}, path string) {
+	ignored := make(map[string]bool)
+	for _, key := range flatten(values, "", nil) {
+		if !isLocked(key) {
+			continue
+		}
+		deleteNested(values, key)
+		if parts := strings.Split(key, "."); parts[0] == "servers" && systemServers[parts[1]] {
+			key = "servers." + parts[1]
+		}
+		ignored[key] = true
+	}
+	if len(ignored) == 0 {
+		return
+	}
+	var keys []string
+	for key := range ignored {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	warnOnce(fmt.Sprintf("options in %s ignored: locked by the system configuration (%s)", path, strings.Join(keys, ", ")))
+}
+
+// removeUntrustedValues removes the values that are not read from repository configuration files.
+// A warning is printed for known keys; unknown keys are removed silently.
+func removeUntrustedValues(values map[string]interface{}, path string) {
+	for _, key := range flatten(values, "", nil) {
+		spec, _, ok := lookupSpec(key)
+		if ok && spec.repo {
+			continue
+		}
+		deleteNested(values, key)
+		if !ok {
+			continue
+		}
+		warnOnce(fmt.Sprintf("option '%s' in %s ignored: only annex, network, and ssh key type options are read from repository configuration files", key, path))
+	}
+}
+
+// SetOverride sets a configuration value for the current invocation only (the command line layer).
+// The value is checked like in SetValue. List values are separated by commas.
+func SetOverride(key, value string) error {
+	key = strings.ToLower(key)
+	spec, _, ok := lookupSpec(key)
+	if !ok {
+		return fmt.Errorf("unknown configuration key '%s'", key)
+	}
+	if IsLocked(key) {
+		return lockedError(key)
+	}
+	parsed, err := parseValue(key, spec, splitValue(spec, value))
+	if err != nil {
+		return err
+	}
+	overrides = append(overrides, Setting{Key: key, Value: parsed, Origin: OriginFlag})
+	// invalidate the read cache
+	set = false
+	return nil
+}
+
+// parseValue checks the given value(s) for a key and returns the value to store in the configuration file.
+func parseValue(key string, spec keySpec, values []string) (interface{}, error) {
+	if spec.kind == kindList {
+		return values, nil
+	}
+	if len(values) != 1 {
+		return nil, fmt.Errorf("'%s' takes a single value", key)
+	}
+	value := values[0]
+	switch spec.kind {
+	case kindChoice:
+		for _, choice := range spec.choices {
+			if value == choice {
+				return value, nil
+			}
+		}
+		return nil, fmt.Errorf("invalid value '%s' for '%s' (valid values are: %s)", value, key, strings.Join(spec.choices, ", "))
+	case kindBool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value '%s' for '%s': must be true or false", value, key)
+		}
+		return b, nil
+	case kindCount:
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid value '%s' for '%s': must be a non-negative integer", value, key)
+		}
+		return n, nil
+	case kindPort:
+		port, err := strconv.ParseUint(value, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port '%s' for '%s': must be a number between 0 and 65535", value, key)
+		}
+		return int(port), nil
+	case kindSize:
+		if !sizeRE.MatchString(value) {
+			return nil, fmt.Errorf("invalid size '%s' for '%s' (e.g., 10M, 500kB, 1.5GB)", value, key)
+		}
+		return value, nil
+	case kindDuration:
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid duration '%s' for '%s' (e.g., 30s, 2m, 1m30s)", value, key)
+		}
+		return d.String(), nil
+	case kindBinary:
+		if _, err := exec.LookPath(value); err != nil {
+			return nil, fmt.Errorf("invalid value '%s' for '%s': executable not found", value, key)
+		}
+		return value, nil
+	case kindFile:
+		if value == "" {
+			return value, nil
+		}
+		// the file is read with ~ expanded to the home directory
+		if info, err := os.Stat(ExpandHome(value)); err != nil || info.IsDir() {
+			return nil, fmt.Errorf("invalid value '%s' for '%s': file not found", value, key)
+		}
+		return value, nil
+	case kindURL:
+		if value == "" {
+			return value, nil
+		}
+		if u, err := url.Parse(value); err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid URL '%s' for '%s'", value, key)
+		}
+		return value, nil
+	}
+	return value, nil
+}
+
+// scopeFile returns the path of the configuration file for the given scope.
+func scopeFile(scope string) (string, error) {
+	switch scope {
+	case ScopeSystem:
+		return SystemFile(), nil
+	case ScopeGlobal:
+		confpath, err := Path(false)
+		return filepath.Join(confpath, defaultFileName), err
+	case ScopeRepo:
+		reporoot, err := findreporoot(".")
+		if err != nil {
+			return "", fmt.Errorf("the repository configuration can only be used inside a repository")
+		}
+		return filepath.Join(reporoot, defaultFileName), nil
+	}
+	return "", fmt.Errorf("unknown configuration scope '%s'", scope)
+}
+
+// readFile returns the values in a configuration file as a nested map.
+// A file that does not exist is treated as empty.
+func readFile(path string) (map[string]interface{}, error) {
+	if !pathExists(path) {
+		return map[string]interface{}{}, nil
+	}
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read configuration file %s: %v", path, err)
+	}
+	return v.AllSettings(), nil
+}
+
+// writeFile writes the values to a configuration file, replacing its contents.
+// The values are written to a temporary file in the same directory, which then replaces the original, so that the file is never left incomplete.
+func writeFile(path string, values map[string]interface{}) error {
+	data, err := yaml.Marshal(values)
+	if err != nil {
+		return fmt.Errorf("failed to encode configuration: %v", err)
+	}
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	tmpfile, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write configuration file %s: %v", path, err)
+	}
+	tmpname := tmpfile.Name()
+	_, err = tmpfile.Write(data)
+	if err == nil {
+		err = tmpfile.Sync()
+	}
+	if cerr := tmpfile.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmpname, mode)
+	}
+	if err == nil {
+		err = os.Rename(tmpname, path)
+	}
+	if err != nil {
+		os.Remove(tmpname)
+		return fmt.Errorf("failed to write configuration file %s: %v", path, err)
+	}
+	// invalidate the read cache
+	set = false
+	return nil
+}
+
+// getNested returns the value at the (dot separated) key in a nested map.
+func getNested(values map[string]interface{}, key string) (interface{}, bool) {
+	parts := strings.Split(key, ".")
+	for _, part := range parts[:len(parts)-1] {
+		sub, ok := values[part].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		values = sub
+	}
+	value, ok := values[parts[len(parts)-1]]
+	return value, ok
+}
+
+// setNested sets the value at the (dot separated) key in a nested map, creating intermediate maps as necessary.
+func setNested(values map[string]interface{}, key string, value interface{}) {
+	parts := strings.Split(key, ".")
+	for _, part := range parts[:len(parts)-1] {
+		sub, ok := values[part].(map[string]interface{})
+		if !ok {
+			sub = map[string]interface{}{}
+			values[part] = sub
+		}
+		values = sub
+	}
+	values[parts[len(parts)-1]] = value
+}
+
+// deleteNested removes the value at the (dot separated) key from a nested map, along with any maps left empty.
+// It returns false if the key does not exist.
+func deleteNested(values map[string]interface{}, key string) bool {
+	parts := strings.SplitN(key, ".", 2)
+	if len(parts) == 1 {
+		_, ok := values[key]
+		delete(values, key)
+		return ok
+	}
+	sub, ok := values[parts[0]].(map[string]interface{})
+	if !ok || !deleteNested(sub, parts[1]) {
+		return false
+	}
+	if len(sub) == 0 {
+		delete(values, parts[0])
+	}
+	return true
+}
+
+// flatten returns the dot separated keys of all values in a nested map.
+func flatten(values map[string]interface{}, prefix string, keys []string) []string {
+	for k, v := range values {
+		if sub, ok := v.(map[string]interface{}); ok {
+			keys = flatten(sub, prefix+k+".", keys)
+		} else {
+			keys = append(keys, prefix+k)
+		}
+	}
+	return keys
+}
+
+// effectiveValue returns the value of a key in the loaded configuration.
+// Field names match the key components regardless of case and server configurations are looked up by alias.
+func effectiveValue(conf GinCliCfg, key string) (interface{}, bool) {
+	value := reflect.ValueOf(conf)
+	for _, part := range strings.Split(key, ".") {
+		switch value.Kind() {
+		case reflect.Struct:
+			value = value.FieldByNameFunc(func(name string) bool { return strings.EqualFold(name, part) })
+		case reflect.Map:
+			value = value.MapIndex(reflect.ValueOf(part))
+		default:
+			return nil, false
+		}
+		if !value.IsValid() {
+			return nil, false
+		}
+	}
+	if d, ok := value.Interface().(time.Duration); ok {
+		return d.String(), true
+	}
+	return value.Interface(), true
+}
+
+// effectiveSetting returns the value of a key in the loaded configuration along with the layer that supplied it.
+func effectiveSetting(conf GinCliCfg, key string) (Setting, bool) {
+	value, ok := effectiveValue(conf, key)
+	if !ok {
+		return Setting{}, false
+	}
+	setting, ok := origins[key]
+	if !ok {
+		setting = Setting{Key: key, Origin: OriginDefault}
+	}
+	setting.Value = value
+	return setting, true
+}
+
+// GetValue returns the value of a configuration key.
+// If scope is empty, the value in effect is returned along with the layer that supplied it, otherwise the value is read from the configuration file of the given scope.
+func GetValue(scope, key string) (Setting, error) {
+	key = strings.ToLower(key)
+	if scope != "" {
+		path, err := scopeFile(scope)
+		if err != nil {
+			return Setting{}, err
+		}
+		values, err := readFile(path)
+		if err != nil {
+			return Setting{}, err
+		}
+		value, ok := getNested(values, key)
+		if !ok {
+			return Setting{}, fmt.Errorf("'%s' is not set in %s", key, path)
+		}
+		return Setting{Key: key, Value: value, Origin: scope, Source: path}, nil
+	}
+	if _, _, ok := lookupSpec(key); !ok {
+		return Setting{}, fmt.Errorf("unknown configuration key '%s'", key)
+	}
+	setting, ok := effectiveSetting(Read(), key)
+	if !ok {
+		return Setting{}, fmt.Errorf("'%s' is not set", key)
+	}
+	return setting, nil
+}
+
+// ListValues returns all configuration values sorted by key.
+// If scope is empty, all known keys are listed with the values in effect, otherwise the values set in the configuration file of the given scope are listed.
+func ListValues(scope string) ([]Setting, error) {
+	var settings []Setting
+	if scope != "" {
+		path, err := scopeFile(scope)
+		if err != nil {
+			return nil, err
+		}
+		values, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range flatten(values, "", nil) {
+			value, _ := getNested(values, key)
+			settings = append(settings, Setting{Key: key, Value: value, Origin: scope, Source: path})
+		}
+	} else {
+		conf := Read()
+		var aliases []string
+		for alias := range conf.Servers {
+			aliases = append(aliases, alias)
+		}
+		for pattern := range keySpecs {
+			for _, key := range expandKey(pattern, aliases) {
+				if setting, ok := effectiveSetting(conf, key); ok {
+					settings = append(settings, setting)
+				}
+			}
+		}
+	}
+	sort.Slice(settings, func(i, j int) bool { return settings[i].Key < settings[j].Key })
+	return settings, nil
+}
+
+// SetValue validates the value(s) for a configuration key and writes them to the configuration file of the given scope.
+// Multiple values can only be given for list keys (annex.exclude).
+func SetValue(scope, key string, values ...string) error {
+	key = strings.ToLower(key)
+	spec, alias, ok := lookupSpec(key)
+	if !ok {
+		return fmt.Errorf("unknown configuration key '%s'", key)
+	}
+	if scope == ScopeSystem {
+		return fmt.Errorf("the system configuration can not be changed with the client: edit %s instead", SystemFile())
+	}
+	if IsLocked(key) {
+		return lockedError(key)
+	}
+	if scope == ScopeRepo && !spec.repo {
+		return fmt.Errorf("'%s' can not be set in the repository configuration (only annex, network, and ssh key type options are read from it)", key)
+	}
+	value, err := parseValue(key, spec, values)
+	if err != nil {
+		return err
+	}
+	servers := Read().Servers
+	if _, ok := servers[alias]; alias != "" && !ok {
+		return fmt.Errorf("unknown server alias '%s': use 'gin add-server' to configure a new server", alias)
+	}
+	if _, ok := servers[fmt.Sprint(value)]; key == "defaultserver" && !ok {
+		return fmt.Errorf("unknown server alias '%s'", value)
+	}
+	path, err := scopeFile(scope)
+	if err != nil {
+		return err
+	}
+	if scope == ScopeGlobal {
+		if _, err = Path(true); err != nil {
+			return err
+		}
+	}
+	filevalues, err := readFileForUpdate(path)
+	if err != nil {
+		return err
+	}
+	setNested(filevalues, key, value)
+	return writeFile(path, filevalues)
+}
+
+// UnsetValue removes a configuration key from the configuration file of the given scope.
+// Unknown keys can also be removed.
+func UnsetValue(scope, key string) error {
+	key = strings.ToLower(key)
+	if scope == ScopeSystem {
+		return fmt.Errorf("the system configuration can not be changed with the client: edit %s instead", SystemFile())
+	}
+	path, err := scopeFile(scope)
+	if err != nil {
+		return err
+	}
+	values, err := readFileForUpdate(path)
+	if err != nil {
+		return err
+	}
+	if !deleteNested(values, key) {
+		return fmt.Errorf("'%s' is not set in %s", key, path)
+	}
+	return writeFile(path, values)
+}
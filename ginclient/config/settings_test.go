@@ -0,0 +1,140 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestParseValue(t *testing.T) {
+	home := t.TempDir()
+	for _, envvar := range []string{"HOME", "USERPROFILE"} {
+		defer os.Setenv(envvar, os.Getenv(envvar))
+		os.Setenv(envvar, home)
+	}
+	dir := t.TempDir()
+	certfile := filepath.Join(dir, "cert.pem")
+	if err := ioutil.WriteFile(certfile, []byte("cert"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(home, "ca.pem"), []byte("ca"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	binary, _ := os.Executable()
+
+	choice := keySpec{kind: kindChoice, choices: []string{"ed25519", "rsa"}}
+	tests := []struct {
+		spec     keySpec
+		values   []string
+		expected interface{}
+		valid    bool
+	}{
+		{keySpec{kind: kindString}, []string{"gin"}, "gin", true},
+		{keySpec{kind: kindString}, []string{""}, "", true},
+		{keySpec{kind: kindString}, []string{"a", "b"}, nil, false},
+		{keySpec{kind: kindString}, nil, nil, false},
+		{choice, []string{"rsa"}, "rsa", true},
+		{choice, []string{"RSA"}, nil, false},
+		{choice, []string{"dsa"}, nil, false},
+		{keySpec{kind: kindBool}, []string{"true"}, true, true},
+		{keySpec{kind: kindBool}, []string{"0"}, false, true},
+		{keySpec{kind: kindBool}, []string{"yes"}, nil, false},
+		{keySpec{kind: kindCount}, []string{"3"}, 3, true},
+		{keySpec{kind: kindCount}, []string{"0"}, 0, true},
+		{keySpec{kind: kindCount}, []string{"-1"}, nil, false},
+		{keySpec{kind: kindCount}, []string{"two"}, nil, false},
+		{keySpec{kind: kindPort}, []string{"2222"}, 2222, true},
+		{keySpec{kind: kindPort}, []string{"65535"}, 65535, true},
+		{keySpec{kind: kindPort}, []string{"65536"}, nil, false},
+		{keySpec{kind: kindPort}, []string{"-22"}, nil, false},
+		{keySpec{kind: kindSize}, []string{"10M"}, "10M", true},
+		{keySpec{kind: kindSize}, []string{"1.5GB"}, "1.5GB", true},
+		{keySpec{kind: kindSize}, []string{"500 kB"}, "500 kB", true},
+		{keySpec{kind: kindSize}, []string{"100"}, "100", true},
+		{keySpec{kind: kindSize}, []string{"10X"}, nil, false},
+		{keySpec{kind: kindSize}, []string{"M10"}, nil, false},
+		{keySpec{kind: kindDuration}, []string{"90s"}, "1m30s", true},
+		{keySpec{kind: kindDuration}, []string{"0s"}, "0s", true},
+		{keySpec{kind: kindDuration}, []string{"-1s"}, nil, false},
+		{keySpec{kind: kindDuration}, []string{"30"}, nil, false},
+		{keySpec{kind: kindList}, []string{"*.md", "*.txt"}, []string{"*.md", "*.txt"}, true},
+		{keySpec{kind: kindList}, nil, []string(nil), true},
+		{keySpec{kind: kindBinary}, []string{binary}, binary, true},
+		{keySpec{kind: kindBinary}, []string{filepath.Join(dir, "missing")}, nil, false},
+		{keySpec{kind: kindFile}, []string{certfile}, certfile, true},
+		{keySpec{kind: kindFile}, []string{"~/ca.pem"}, "~/ca.pem", true},
+		{keySpec{kind: kindFile}, []string{""}, "", true},
+		{keySpec{kind: kindFile}, []string{"~/missing.pem"}, nil, false},
+		{keySpec{kind: kindFile}, []string{dir}, nil, false},
+		{keySpec{kind: kindURL}, []string{"http://proxy.example.org:3128"}, "http://proxy.example.org:3128", true},
+		{keySpec{kind: kindURL}, []string{""}, "", true},
+		{keySpec{kind: kindURL}, []string{"proxy.example.org:3128"}, nil, false},
+		{keySpec{kind: kindURL}, []string{"http://"}, nil, false},
+	}
+	for _, test := range tests {
+		value, err := parseValue("test.key", test.spec, test.values)
+		if test.valid && err != nil {
+			t.Errorf("parseValue(%d, %v) failed: %v", test.spec.kind, test.values, err)
+			continue
+		}
+		if !test.valid {
+			if err == nil {
+				t.Errorf("parseValue(%d, %v) = %v; expected error", test.spec.kind, test.values, value)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(value, test.expected) {
+			t.Errorf("parseValue(%d, %v) = %#v; expected %#v", test.spec.kind, test.values, value, test.expected)
+		}
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, defaultFileName)
+	values := nested(map[string]interface{}{"annex.minsize": "10M", "servers.test.web.port": 3000})
+	if err := writeFile(path, values); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	read, err := readFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read config file: %v", err)
+	}
+	if !reflect.DeepEqual(read, values) {
+		t.Fatalf("Unexpected values after writing:\n%v\nexpected:\n%v", read, values)
+	}
+
+	// the permissions of an existing file are kept
+	if runtime.GOOS != "windows" {
+		os.Chmod(path, 0600)
+		if err = writeFile(path, nested(map[string]interface{}{"annex.minsize": "1M"})); err != nil {
+			t.Fatalf("Failed to replace config file: %v", err)
+		}
+		if info, err := os.Stat(path); err != nil {
+			t.Fatalf("Failed to stat config file: %v", err)
+		} else if info.Mode().Perm() != 0600 {
+			t.Fatalf("Permissions of config file not kept: %v", info.Mode().Perm())
+		}
+	}
+
+	// no temporary files are left behind
+	entries, _ := ioutil.ReadDir(dir)
+	if len(entries) != 1 || entries[0].Name() != defaultFileName {
+		t.Fatalf("Unexpected files in config directory: %v", entries)
+	}
+
+	// a failed write leaves no temporary file behind (the target can not be replaced by a file)
+	dirpath := filepath.Join(dir, "dir.yml")
+	os.Mkdir(dirpath, 0700)
+	ioutil.WriteFile(filepath.Join(dirpath, "keep"), nil, 0600)
+	if err = writeFile(dirpath, values); err == nil {
+		t.Fatal("Expected error when replacing a directory")
+	}
+	entries, _ = ioutil.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Fatalf("Unexpected files in config directory after failed write: %v", entries)
+	}
+}
@@ -0,0 +1,239 @@
+package ginclient
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/G-Node/gin-cli/ginclient/log"
+	"github.com/G-Node/gin-cli/git"
+)
+
+// Functions for inspecting and resolving merge conflicts left behind by a failed download.
+
+// ConflictVersion describes one side (local or remote) of a conflicted file.
+type ConflictVersion struct {
+	// Exists is false if the file was deleted on this side of the merge.
+	Exists      bool      `json:"exists"`
+	Hash        string    `json:"hash,omitempty"`
+	Mode        string    `json:"mode,omitempty"`
+	Commit      string    `json:"commit,omitempty"`
+	AuthorName  string    `json:"authorname,omitempty"`
+	AuthorEmail string    `json:"authoremail,omitempty"`
+	Date        time.Time `json:"date"`
+	// Size of the file content (the size of the annexed content for annexed files).
+	Size     int64  `json:"size"`
+	Annexed  bool   `json:"annexed"`
+	AnnexKey string `json:"annexkey,omitempty"`
+
+	object git.Object
+}
+
+// Conflict describes a file that was changed both locally and remotely and
+// could not be merged automatically.
+type Conflict struct {
+	// FileName is relative to the root of the repository.
+	FileName string          `json:"filename"`
+	Local    ConflictVersion `json:"local"`
+	Remote   ConflictVersion `json:"remote"`
+}
+
+// ResolveStrategy specifies how a conflict should be resolved.
+type ResolveStrategy uint8
+
+// Conflict resolution strategies
+const (
+	// KeepLocal keeps the local version of the file and discards the remote changes.
+	KeepLocal ResolveStrategy = iota
+	// KeepRemote keeps the remote version of the file and discards the local changes.
+	KeepRemote
+	// KeepBoth keeps the local version under the original name and the remote version under a new name.
+	KeepBoth
+)
+
+// String returns the short name of the strategy.
+func (s ResolveStrategy) String() string {
+	switch s {
+	case KeepLocal:
+		return "local"
+	case KeepRemote:
+		return "remote"
+	case KeepBoth:
+		return "both"
+	}
+	return "unknown"
+}
+
+// checkIndirect returns an error if the repository is in direct mode.
+// In direct mode, the index is managed by git-annex, which resolves
+// conflicting changes by itself during a download, so conflicts can not be
+// resolved by changing the index.
+func checkIndirect(fn string) error {
+	if git.IsDirect() {
+		return ginerror{Origin: fn, Description: "resolving conflicts is not supported for repositories in direct mode: conflicting files are kept as separate variants (*.variant-*) by git-annex during download"}
+	}
+	return nil
+}
+
+// Conflicts returns the list of files with unresolved merge conflicts along
+// with the metadata of the local and remote versions of each file.
+// Must be called from the root of the repository.
+func Conflicts() ([]Conflict, error) {
+	if err := checkIndirect("Conflicts()"); err != nil {
+		return nil, err
+	}
+	if !git.MergeInProgress() {
+		return nil, ginerror{Origin: "Conflicts()", Description: "no download or merge in progress"}
+	}
+	unmerged, err := git.LsUnmerged()
+	if err != nil {
+		return nil, err
+	}
+
+	var hashes []string
+	for _, uf := range unmerged {
+		if uf.Local.Hash != "" {
+			hashes = append(hashes, uf.Local.Hash)
+		}
+		if uf.Remote.Hash != "" {
+			hashes = append(hashes, uf.Remote.Hash)
+		}
+	}
+	blobinfo, err := git.CatFileBlobInfo(hashes)
+	if err != nil {
+		// sizes are informational; carry on without them
+		log.Write("Failed to retrieve blob info for conflicted files: %v", err)
+		blobinfo = make(map[string]git.BlobInfo)
+	}
+
+	conflicts := make([]Conflict, len(unmerged))
+	for idx, uf := range unmerged {
+		conflicts[idx] = Conflict{
+			FileName: uf.Name,
+			Local:    conflictVersion(uf.Local, "HEAD", uf.Name, blobinfo),
+			Remote:   conflictVersion(uf.Remote, "MERGE_HEAD", uf.Name, blobinfo),
+		}
+	}
+	return conflicts, nil
+}
+
+func conflictVersion(obj git.Object, rev, fname string, blobinfo map[string]git.BlobInfo) ConflictVersion {
+	cv := ConflictVersion{object: obj}
+	if obj.Hash == "" {
+		return cv
+	}
+	cv.Exists = true
+	cv.Hash = obj.Hash
+	cv.Mode = obj.Mode
+	if info, ok := blobinfo[obj.Hash]; ok {
+		cv.Size = info.ContentSize()
+		cv.AnnexKey = info.AnnexKey
+		cv.Annexed = info.AnnexKey != ""
+	}
+	// last commit that touched the file on this side of the merge
+	commits, err := git.Log(1, rev, []string{fname}, true)
+	if err != nil || len(commits) == 0 {
+		log.Write("Failed to retrieve log for %s (%s): %v", fname, rev, err)
+		return cv
+	}
+	cv.Commit = commits[0].Hash
+	cv.AuthorName = commits[0].AuthorName
+	cv.AuthorEmail = commits[0].AuthorEmail
+	cv.Date = commits[0].Date
+	return cv
+}
+
+// RemoteCopyName returns the name under which the remote version of a
+// conflicted file is stored when both versions are kept.
+// The abbreviated hash of the remote commit is appended to the original
+// filename (before the extension).
+func (c Conflict) RemoteCopyName() string {
+	suffix := "remote"
+	if len(c.Remote.Commit) >= 7 {
+		suffix = fmt.Sprintf("remote-%s", c.Remote.Commit[:7])
+	}
+	filext := filepath.Ext(c.FileName)
+	return fmt.Sprintf("%s-%s%s", strings.TrimSuffix(c.FileName, filext), suffix, filext)
+}
+
+// ResolveConflict resolves the conflict for a single file using the given
+// strategy, updating both the index and the working tree.
+// Must be called from the root of the repository.
+func ResolveConflict(c Conflict, strategy ResolveStrategy) error {
+	fn := fmt.Sprintf("ResolveConflict(%s, %s)", c.FileName, strategy)
+	if err := checkIndirect(fn); err != nil {
+		return err
+	}
+	log.Write("Resolving conflict for %s: keep %s", c.FileName, strategy)
+	switch strategy {
+	case KeepLocal:
+		return keepVersion(c.FileName, c.Local)
+	case KeepRemote:
+		return keepVersion(c.FileName, c.Remote)
+	case KeepBoth:
+		if !c.Local.Exists || !c.Remote.Exists {
+			// one side deleted the file: keeping both means keeping the one that exists
+			if c.Local.Exists {
+				return keepVersion(c.FileName, c.Local)
+			}
+			return keepVersion(c.FileName, c.Remote)
+		}
+		copyname := c.RemoteCopyName()
+		if _, err := os.Lstat(copyname); err == nil {
+			return ginerror{Origin: fn, Description: fmt.Sprintf("cannot keep both versions: file '%s' already exists", copyname)}
+		}
+		if err := keepVersion(c.FileName, c.Local); err != nil {
+			return err
+		}
+		return keepVersion(copyname, c.Remote)
+	}
+	return ginerror{Origin: fn, Description: "unknown conflict resolution strategy"}
+}
+
+// keepVersion sets the file at fname to the given version in the index and
+// the working tree, removing the file if the version does not exist.
+func keepVersion(fname string, cv ConflictVersion) error {
+	obj := cv.object
+	if !cv.Exists {
+		obj.Hash = ""
+	}
+	if err := git.UpdateIndexEntry(fname, obj); err != nil {
+		return err
+	}
+	if !cv.Exists {
+		if err := os.Remove(fname); err != nil && !os.IsNotExist(err) {
+			return ginerror{UError: err.Error(), Origin: "keepVersion()", Description: fmt.Sprintf("failed to remove '%s'", fname)}
+		}
+		return nil
+	}
+	return git.CheckoutIndex([]string{fname})
+}
+
+// FinishMerge records the merge commit once all conflicts have been resolved.
+func FinishMerge() error {
+	if err := checkIndirect("FinishMerge()"); err != nil {
+		return err
+	}
+	unmerged, err := git.LsUnmerged()
+	if err != nil {
+		return err
+	}
+	if len(unmerged) > 0 {
+		return ginerror{Origin: "FinishMerge()", Description: fmt.Sprintf("%d conflicted file(s) have not been resolved", len(unmerged))}
+	}
+	return git.CommitMerge()
+}
+
+// AbortMerge cancels the merge in progress and restores the repository to its
+// state before the download.
+func AbortMerge() error {
+	if err := checkIndirect("AbortMerge()"); err != nil {
+		return err
+	}
+	if !git.MergeInProgress() {
+		return ginerror{Origin: "AbortMerge()", Description: "no download or merge in progress"}
+	}
+	return git.MergeAbort()
+}
@@ -0,0 +1,278 @@
+// Package credentials provides storage backends for the credentials of the client (login tokens and private keys).
+//
+// Credentials are identified by name (e.g., "gin.token" or "gin.key") and
+// stored in the backend selected in the configuration:
+//   - file: plain files in the configuration directory, readable only by the owner (default)
+//   - encrypted: a single file in the configuration directory, encrypted with a passphrase
+//   - helper: an external program (the freedesktop Secret Service via secret-tool, pass, or a custom helper)
+package credentials
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/G-Node/gin-cli/ginclient/config"
+	"github.com/G-Node/gin-cli/ginclient/log"
+	"github.com/G-Node/gin-cli/git/shell"
+)
+
+// crederror alias to shell.Error
+type crederror = shell.Error
+
+// Storage backends
+const (
+	BackendFile      = "file"
+	BackendEncrypted = "encrypted"
+	BackendHelper    = "helper"
+)
+
+// errNotFound is returned by stores when a credential does not exist.
+var errNotFound = errors.New("credential not found")
+
+// IsNotFound returns true if the error signifies that the requested credential does not exist.
+func IsNotFound(err error) bool {
+	if err == errNotFound {
+		return true
+	}
+	if cerr, ok := err.(crederror); ok {
+		return cerr.UError == errNotFound.Error()
+	}
+	return false
+}
+
+// Store is a storage backend for credentials.
+type Store interface {
+	// Name returns the name of the backend.
+	Name() string
+	// Get returns the credential with the given name.
+	Get(name string) ([]byte, error)
+	// Set stores a credential under the given name, replacing any existing value.
+	Set(name string, data []byte) error
+	// Delete removes the credential with the given name.
+	Delete(name string) error
+}
+
+// Open returns the credential store selected in the configuration.
+func Open() (Store, error) {
+	conf := config.Read().Credentials
+	dir, _ := config.Path(false) // Error can only occur when create=True
+	switch conf.Backend {
+	case "", BackendFile:
+		return fileStore{dir: dir}, nil
+	case BackendEncrypted:
+		return encryptedStore{path: filepath.Join(dir, encryptedFileName)}, nil
+	case BackendHelper:
+		if conf.Helper == "" {
+			return nil, crederror{Origin: "Open()", Description: "credential backend 'helper' requires the credentials.helper configuration option"}
+		}
+		return helperStore{command: conf.Helper}, nil
+	}
+	return nil, crederror{Origin: "Open()", Description: fmt.Sprintf("unknown credential backend '%s' (valid values are '%s', '%s', and '%s')", conf.Backend, BackendFile, BackendEncrypted, BackendHelper)}
+}
+
+// legacyStore returns the file store in the configuration directory, which
+// was the only storage location for credentials before backends were
+// configurable.
+func legacyStore() fileStore {
+	dir, _ := config.Path(false)
+	return fileStore{dir: dir}
+}
+
+// Load returns the credential with the given name from the configured store.
+// When a backend other than 'file' is configured, credentials that are still
+// stored in plain files are moved into the configured store.
+func Load(name string) ([]byte, error) {
+	store, err := Open()
+	if err != nil {
+		return nil, err
+	}
+	data, err := store.Get(name)
+	if err == nil || !IsNotFound(err) || store.Name() == BackendFile {
+		return data, err
+	}
+	legacy := legacyStore()
+	data, lerr := legacy.Get(name)
+	if lerr != nil {
+		// not found in either location: report the original error
+		return nil, err
+	}
+	log.Write("Migrating credential %s to %s store", name, store.Name())
+	if serr := store.Set(name, data); serr != nil {
+		log.Write("Failed to migrate credential %s: %v", name, serr)
+		return data, nil
+	}
+	if derr := legacy.Delete(name); derr != nil {
+		log.Write("Failed to delete migrated credential file %s: %v", name, derr)
+	}
+	return data, nil
+}
+
+// Save stores a credential in the configured store.
+// Any plain file copy left over from the 'file' backend is removed.
+func Save(name string, data []byte) error {
+	store, err := Open()
+	if err != nil {
+		return err
+	}
+	forgetKeyFile(name)
+	if err = store.Set(name, data); err != nil {
+		return err
+	}
+	log.Write("Saved credential %s [%s]", name, store.Name())
+	if store.Name() != BackendFile {
+		if derr := legacyStore().Delete(name); derr != nil && !IsNotFound(derr) {
+			log.Write("Failed to delete credential file %s: %v", name, derr)
+		}
+	}
+	return nil
+}
+
+// Delete removes a credential from the configured store and any plain file
+// copy left over from the 'file' backend.
+// An error satisfying IsNotFound is returned if the credential did not exist in either location.
+func Delete(name string) error {
+	store, err := Open()
+	if err != nil {
+		return err
+	}
+	forgetKeyFile(name)
+	err = store.Delete(name)
+	if store.Name() != BackendFile {
+		lerr := legacyStore().Delete(name)
+		if IsNotFound(err) && lerr == nil {
+			err = nil
+		}
+	}
+	if err == nil {
+		log.Write("Deleted credential %s [%s]", name, store.Name())
+	}
+	return err
+}
+
+var (
+	// keyfiles caches the paths returned by KeyFile; an empty path records a credential that does not exist
+	keyfiles   = make(map[string]string)
+	keyfileDir string
+	keyfileMu  sync.Mutex
+)
+
+// forgetKeyFile removes a credential from the KeyFile cache and deletes its temporary file.
+// It is called when the credential is changed or deleted.
+func forgetKeyFile(name string) {
+	keyfileMu.Lock()
+	defer keyfileMu.Unlock()
+	if path, ok := keyfiles[name]; ok && path != "" && keyfileDir != "" && filepath.Dir(path) == keyfileDir {
+		os.Remove(path)
+	}
+	delete(keyfiles, name)
+}
+
+// KeyFile returns the path to a file that contains the credential with the
+// given name, for use with external programs that require a file (e.g., the
+// private key for ssh).
+// For the 'file' backend, this is the credential file itself. For all other
+// backends, the credential is written to a private temporary directory which
+// is removed by Cleanup.
+// The result is cached for the duration of the command, including whether
+// the credential exists, so that external helpers are run at most once for
+// each credential.
+func KeyFile(name string) (string, error) {
+	fn := fmt.Sprintf("KeyFile(%s)", name)
+	store, err := Open()
+	if err != nil {
+		return "", err
+	}
+	if fs, ok := store.(fileStore); ok {
+		fs.fixPermissions(name)
+		path := fs.path(name)
+		if _, err := os.Stat(path); err != nil {
+			return "", crederror{UError: errNotFound.Error(), Origin: fn}
+		}
+		return path, nil
+	}
+
+	keyfileMu.Lock()
+	defer keyfileMu.Unlock()
+	if path, ok := keyfiles[name]; ok {
+		if path == "" {
+			return "", crederror{UError: errNotFound.Error(), Origin: fn}
+		}
+		return path, nil
+	}
+	data, err := Load(name)
+	if err != nil {
+		if IsNotFound(err) {
+			keyfiles[name] = ""
+		}
+		return "", err
+	}
+	if err = makeKeyfileDir(); err != nil {
+		return "", crederror{UError: err.Error(), Origin: fn, Description: "failed to create temporary directory for credentials"}
+	}
+	path := filepath.Join(keyfileDir, name)
+	if err = ioutil.WriteFile(path, data, 0600); err != nil {
+		return "", crederror{UError: err.Error(), Origin: fn, Description: "failed to write temporary credential file"}
+	}
+	keyfiles[name] = path
+	return path, nil
+}
+
+// makeKeyfileDir creates the private temporary directory for credential files if it does not exist yet.
+// The caller must hold keyfileMu.
+func makeKeyfileDir() error {
+	if keyfileDir != "" {
+		return nil
+	}
+	dir, err := ioutil.TempDir("", "gin-credentials-")
+	if err != nil {
+		return err
+	}
+	keyfileDir = dir
+	return nil
+}
+
+// WriteTempFile writes data to a file with the given name in the private
+// temporary directory used by KeyFile and returns its path. It is meant for
+// files that refer to temporary credential files (e.g., ssh configuration).
+// Existing files are not rewritten, so the name should identify the contents.
+// The file is removed by Cleanup.
+func WriteTempFile(name string, data []byte) (string, error) {
+	fn := fmt.Sprintf("WriteTempFile(%s)", name)
+	keyfileMu.Lock()
+	defer keyfileMu.Unlock()
+	if err := makeKeyfileDir(); err != nil {
+		return "", crederror{UError: err.Error(), Origin: fn, Description: "failed to create temporary directory for credentials"}
+	}
+	path := filepath.Join(keyfileDir, name)
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+	// write to a different name first so that the file is never read while incomplete
+	tmppath := path + ".tmp"
+	if err := ioutil.WriteFile(tmppath, data, 0600); err != nil {
+		return "", crederror{UError: err.Error(), Origin: fn, Description: "failed to write temporary file"}
+	}
+	if err := os.Rename(tmppath, path); err != nil {
+		return "", crederror{UError: err.Error(), Origin: fn, Description: "failed to write temporary file"}
+	}
+	return path, nil
+}
+
+// Cleanup removes any temporary credential files created by KeyFile and WriteTempFile.
+// It should be called before the program exits.
+func Cleanup() {
+	keyfileMu.Lock()
+	defer keyfileMu.Unlock()
+	if keyfileDir == "" {
+		return
+	}
+	if err := os.RemoveAll(keyfileDir); err != nil {
+		log.Write("Failed to remove temporary credential directory %s: %v", keyfileDir, err)
+	}
+	keyfileDir = ""
+	keyfiles = make(map[string]string)
+}
@@ -0,0 +1,223 @@
+package credentials
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// encryptedFileName is the name of the encrypted credential store file in the configuration directory.
+const encryptedFileName = "credentials.enc"
+
+// PassphraseEnvVar is the environment variable that provides the passphrase
+// for the encrypted credential store for non-interactive use.
+const PassphraseEnvVar = "GIN_CREDENTIALS_PASSPHRASE"
+
+// PassphraseFunc is called to request the passphrase for the encrypted
+// credential store when it is not set in the environment. If confirm is
+// true, a new store is being created and the passphrase should be entered
+// twice. If PassphraseFunc is nil, the passphrase must be set in the environment.
+var PassphraseFunc func(confirm bool) (string, error)
+
+var (
+	// passphrase is cached for the duration of a single command invocation
+	passphrase   string
+	passphraseMu sync.Mutex
+)
+
+func getPassphrase(confirm bool) (string, error) {
+	passphraseMu.Lock()
+	defer passphraseMu.Unlock()
+	if passphrase != "" {
+		return passphrase, nil
+	}
+	if pp := os.Getenv(PassphraseEnvVar); pp != "" {
+		passphrase = pp
+		return passphrase, nil
+	}
+	if PassphraseFunc == nil {
+		return "", crederror{Origin: "getPassphrase()", Description: fmt.Sprintf("the encrypted credential store requires a passphrase: set the %s environment variable", PassphraseEnvVar)}
+	}
+	pp, err := PassphraseFunc(confirm)
+	if err != nil {
+		return "", err
+	}
+	if pp == "" {
+		return "", crederror{Origin: "getPassphrase()", Description: "empty passphrase"}
+	}
+	passphrase = pp
+	return passphrase, nil
+}
+
+// encryptedFile is the on-disk format of the encrypted store.
+// The data is a JSON object mapping credential names to values, encrypted
+// with AES-256-GCM using a key derived from the passphrase with scrypt.
+type encryptedFile struct {
+	Version int    `json:"version"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+// scrypt parameters for key derivation
+const (
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	scryptKeyLen = 32
+)
+
+func newCipher(pp string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(pp), salt, scryptN, scryptR, scryptP, scryptKeyLen)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptedStore keeps all credentials in a single file encrypted with a passphrase.
+type encryptedStore struct {
+	path string
+}
+
+func (s encryptedStore) Name() string {
+	return BackendEncrypted
+}
+
+// read decrypts and returns all credentials in the store.
+// The first return value is false if the store file does not exist.
+func (s encryptedStore) read() (map[string][]byte, bool, error) {
+	fn := "encryptedStore.read()"
+	raw, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return make(map[string][]byte), false, nil
+	} else if err != nil {
+		return nil, true, crederror{UError: err.Error(), Origin: fn, Description: fmt.Sprintf("failed to read credential store %s", s.path)}
+	}
+	var ef encryptedFile
+	if err = json.Unmarshal(raw, &ef); err != nil {
+		return nil, true, crederror{UError: err.Error(), Origin: fn, Description: fmt.Sprintf("credential store %s is corrupted", s.path)}
+	}
+	pp, err := getPassphrase(false)
+	if err != nil {
+		return nil, true, err
+	}
+	aead, err := newCipher(pp, ef.Salt)
+	if err != nil {
+		return nil, true, crederror{UError: err.Error(), Origin: fn, Description: "failed to initialise decryption"}
+	}
+	plain, err := aead.Open(nil, ef.Nonce, ef.Data, nil)
+	if err != nil {
+		return nil, true, crederror{UError: err.Error(), Origin: fn, Description: "failed to decrypt credential store: wrong passphrase or corrupted file"}
+	}
+	creds := make(map[string][]byte)
+	if err = json.Unmarshal(plain, &creds); err != nil {
+		return nil, true, crederror{UError: err.Error(), Origin: fn, Description: fmt.Sprintf("credential store %s is corrupted", s.path)}
+	}
+	return creds, true, nil
+}
+
+// write encrypts the credentials with a new salt and nonce and replaces the store file.
+// The store file is removed if there are no credentials.
+func (s encryptedStore) write(creds map[string][]byte, exists bool) error {
+	fn := "encryptedStore.write()"
+	if len(creds) == 0 {
+		if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+			return crederror{UError: err.Error(), Origin: fn, Description: fmt.Sprintf("failed to delete credential store %s", s.path)}
+		}
+		return nil
+	}
+	pp, err := getPassphrase(!exists)
+	if err != nil {
+		return err
+	}
+	plain, err := json.Marshal(creds)
+	if err != nil {
+		return crederror{UError: err.Error(), Origin: fn}
+	}
+	ef := encryptedFile{Version: 1, Salt: make([]byte, 16)}
+	if _, err = io.ReadFull(rand.Reader, ef.Salt); err != nil {
+		return crederror{UError: err.Error(), Origin: fn, Description: "failed to generate salt"}
+	}
+	aead, err := newCipher(pp, ef.Salt)
+	if err != nil {
+		return crederror{UError: err.Error(), Origin: fn, Description: "failed to initialise encryption"}
+	}
+	ef.Nonce = make([]byte, aead.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, ef.Nonce); err != nil {
+		return crederror{UError: err.Error(), Origin: fn, Description: "failed to generate nonce"}
+	}
+	ef.Data = aead.Seal(nil, ef.Nonce, plain, nil)
+	raw, err := json.Marshal(ef)
+	if err != nil {
+		return crederror{UError: err.Error(), Origin: fn}
+	}
+
+	// write to a temporary file and rename so that a failed write never leaves a broken store
+	dir := filepath.Dir(s.path)
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return crederror{UError: err.Error(), Origin: fn, Description: fmt.Sprintf("could not create config directory %s", dir)}
+	}
+	tmp, err := ioutil.TempFile(dir, encryptedFileName+".tmp")
+	if err != nil {
+		return crederror{UError: err.Error(), Origin: fn, Description: "failed to write credential store"}
+	}
+	_, err = tmp.Write(raw)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return crederror{UError: err.Error(), Origin: fn, Description: "failed to write credential store"}
+	}
+	return nil
+}
+
+func (s encryptedStore) Get(name string) ([]byte, error) {
+	creds, _, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	data, ok := creds[name]
+	if !ok {
+		return nil, crederror{UError: errNotFound.Error(), Origin: fmt.Sprintf("encryptedStore.Get(%s)", name)}
+	}
+	return data, nil
+}
+
+func (s encryptedStore) Set(name string, data []byte) error {
+	creds, exists, err := s.read()
+	if err != nil {
+		return err
+	}
+	creds[name] = data
+	return s.write(creds, exists)
+}
+
+func (s encryptedStore) Delete(name string) error {
+	creds, exists, err := s.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := creds[name]; !ok {
+		return crederror{UError: errNotFound.Error(), Origin: fmt.Sprintf("encryptedStore.Delete(%s)", name)}
+	}
+	delete(creds, name)
+	return s.write(creds, exists)
+}
@@ -0,0 +1,82 @@
+package credentials
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/G-Node/gin-cli/ginclient/log"
+)
+
+// fileStore stores each credential in a separate file in a directory.
+// The files are only readable and writable by the owner.
+type fileStore struct {
+	dir string
+}
+
+func (s fileStore) Name() string {
+	return BackendFile
+}
+
+func (s fileStore) path(name string) string {
+	return filepath.Join(s.dir, name)
+}
+
+// fixPermissions restricts the permissions of an existing credential file
+// that was created by an earlier version with the default permissions.
+func (s fileStore) fixPermissions(name string) {
+	path := s.path(name)
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm()&0077 == 0 {
+		return
+	}
+	log.Write("Restricting permissions of credential file %s", path)
+	if err = os.Chmod(path, 0600); err != nil {
+		log.Write("Failed to change permissions of %s: %v", path, err)
+	}
+}
+
+func (s fileStore) Get(name string) ([]byte, error) {
+	fn := fmt.Sprintf("fileStore.Get(%s)", name)
+	s.fixPermissions(name)
+	data, err := ioutil.ReadFile(s.path(name))
+	if os.IsNotExist(err) {
+		return nil, crederror{UError: errNotFound.Error(), Origin: fn}
+	} else if err != nil {
+		return nil, crederror{UError: err.Error(), Origin: fn, Description: fmt.Sprintf("failed to read credential file %s", s.path(name))}
+	}
+	return data, nil
+}
+
+func (s fileStore) Set(name string, data []byte) error {
+	fn := fmt.Sprintf("fileStore.Set(%s)", name)
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return crederror{UError: err.Error(), Origin: fn, Description: fmt.Sprintf("could not create config directory %s", s.dir)}
+	}
+	path := s.path(name)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return crederror{UError: err.Error(), Origin: fn, Description: fmt.Sprintf("failed to create credential file %s", path)}
+	}
+	defer file.Close()
+	// the file may have existed with wider permissions
+	if err = file.Chmod(0600); err != nil {
+		log.Write("Failed to change permissions of %s: %v", path, err)
+	}
+	if _, err = file.Write(data); err != nil {
+		return crederror{UError: err.Error(), Origin: fn, Description: fmt.Sprintf("failed to write credential file %s", path)}
+	}
+	return nil
+}
+
+func (s fileStore) Delete(name string) error {
+	fn := fmt.Sprintf("fileStore.Delete(%s)", name)
+	err := os.Remove(s.path(name))
+	if os.IsNotExist(err) {
+		return crederror{UError: errNotFound.Error(), Origin: fn}
+	} else if err != nil {
+		return crederror{UError: err.Error(), Origin: fn, Description: fmt.Sprintf("failed to delete credential file %s", s.path(name))}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package credentials
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/G-Node/gin-cli/ginclient/log"
+)
+
+// Built-in helpers
+const (
+	// HelperSecretService stores credentials in the freedesktop Secret Service (e.g., GNOME Keyring, KWallet) using secret-tool.
+	HelperSecretService = "secret-service"
+	// HelperPass stores credentials in the pass password store (https://www.passwordstore.org).
+	HelperPass = "pass"
+)
+
+// helperService is the service (or directory) name under which credentials are stored by the built-in helpers.
+const helperService = "gin-cli"
+
+// helperStore stores credentials using an external program.
+// Values are base64 encoded since most helpers only handle text.
+//
+// Apart from the built-in helpers, any program can be used that supports the following
+// subcommands:
+//   - <helper> get <name>: print the credential to stdout (print nothing if it does not exist)
+//   - <helper> store <name>: store the credential read from stdin
+//   - <helper> erase <name>: delete the credential
+type helperStore struct {
+	command string
+}
+
+func (s helperStore) Name() string {
+	return BackendHelper
+}
+
+// args returns the command line for an action ("get", "store", or "erase") on the named credential.
+func (s helperStore) args(action, name string) []string {
+	switch s.command {
+	case HelperSecretService:
+		attrs := []string{"service", helperService, "credential", name}
+		switch action {
+		case "get":
+			return append([]string{"secret-tool", "lookup"}, attrs...)
+		case "store":
+			return append([]string{"secret-tool", "store", fmt.Sprintf("--label=GIN client credential %s", name)}, attrs...)
+		default:
+			return append([]string{"secret-tool", "clear"}, attrs...)
+		}
+	case HelperPass:
+		entry := fmt.Sprintf("%s/%s", helperService, name)
+		switch action {
+		case "get":
+			return []string{"pass", "show", entry}
+		case "store":
+			return []string{"pass", "insert", "--multiline", "--force", entry}
+		default:
+			return []string{"pass", "rm", "--force", entry}
+		}
+	}
+	return append(strings.Fields(s.command), action, name)
+}
+
+func (s helperStore) run(action, name string, stdin []byte) ([]byte, []byte, error) {
+	args := s.args(action, name)
+	log.Write("Running credential helper: %s", strings.Join(args, " "))
+	cmd := exec.Command(args[0], args[1:]...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	err := cmd.Run()
+	return stdout.Bytes(), stderr.Bytes(), err
+}
+
+// notFound checks the output of a failed helper invocation for signs that the credential does not exist.
+func (s helperStore) notFound(stdout, stderr []byte) bool {
+	if s.command == HelperPass {
+		return bytes.Contains(stderr, []byte("is not in the password store"))
+	}
+	return len(bytes.TrimSpace(stdout)) == 0 && len(bytes.TrimSpace(stderr)) == 0
+}
+
+func (s helperStore) Get(name string) ([]byte, error) {
+	fn := fmt.Sprintf("helperStore.Get(%s)", name)
+	stdout, stderr, err := s.run("get", name, nil)
+	if _, exited := err.(*exec.ExitError); err != nil && !exited {
+		// the helper could not be run (e.g., the program does not exist)
+		return nil, crederror{UError: err.Error(), Origin: fn, Description: fmt.Sprintf("failed to run credential helper '%s'", s.command)}
+	}
+	if err != nil {
+		if s.notFound(stdout, stderr) {
+			return nil, crederror{UError: errNotFound.Error(), Origin: fn}
+		}
+		return nil, crederror{UError: fmt.Sprintf("%s: %s", err, bytes.TrimSpace(stderr)), Origin: fn, Description: "credential helper failed to retrieve credential"}
+	}
+	encoded := bytes.TrimSpace(stdout)
+	if len(encoded) == 0 {
+		return nil, crederror{UError: errNotFound.Error(), Origin: fn}
+	}
+	data, err := base64.StdEncoding.DecodeString(string(encoded))
+	if err != nil {
+		return nil, crederror{UError: err.Error(), Origin: fn, Description: "credential helper returned an invalid credential"}
+	}
+	return data, nil
+}
+
+func (s helperStore) Set(name string, data []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(data) + "\n"
+	_, stderr, err := s.run("store", name, []byte(encoded))
+	if err != nil {
+		return crederror{UError: fmt.Sprintf("%s: %s", err, bytes.TrimSpace(stderr)), Origin: fmt.Sprintf("helperStore.Set(%s)", name), Description: "credential helper failed to store credential"}
+	}
+	return nil
+}
+
+func (s helperStore) Delete(name string) error {
+	fn := fmt.Sprintf("helperStore.Delete(%s)", name)
+	if _, err := s.Get(name); err != nil {
+		return err
+	}
+	_, stderr, err := s.run("erase", name, nil)
+	if err != nil {
+		return crederror{UError: fmt.Sprintf("%s: %s", err, bytes.TrimSpace(stderr)), Origin: fn, Description: "credential helper failed to delete credential"}
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package ginclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/G-Node/gin-cli/ginclient/log"
+	"github.com/G-Node/gin-cli/git"
+	"github.com/G-Node/gin-cli/web"
+)
+
+// Functions for checking what would be lost by deleting a repository from the server and for archiving it before deletion.
+
+// gitTree is the response of the git trees API.
+type gitTree struct {
+	Tree []struct {
+		Path string `json:"path"`
+		Type string `json:"type"`
+	} `json:"tree"`
+	Truncated bool `json:"truncated"`
+}
+
+// RepoFileCount returns the number of files in the given branch of the repository at repopath (owner/name).
+// An error is returned if the server does not provide a complete recursive listing of the files.
+func (gincl *Client) RepoFileCount(repopath, branch string) (int, error) {
+	fn := fmt.Sprintf("RepoFileCount(%s, %s)", repopath, branch)
+	res, err := gincl.Get(gincl.ctx, fmt.Sprintf("/api/v1/repos/%s/git/trees/%s?recursive=1", repopath, branch))
+	if err != nil {
+		return 0, err // return error from Get() directly
+	}
+	defer web.CloseRes(res.Body)
+	if res.StatusCode != http.StatusOK {
+		return 0, ginerror{UError: res.Status, Origin: fn, Description: "failed to retrieve the list of files"}
+	}
+	var tree gitTree
+	if err = json.NewDecoder(res.Body).Decode(&tree); err != nil {
+		return 0, ginerror{UError: err.Error(), Origin: fn, Description: "failed to parse response body"}
+	}
+	if tree.Truncated {
+		return 0, ginerror{UError: "truncated tree", Origin: fn, Description: "the list of files is incomplete"}
+	}
+	nfiles := 0
+	dirs := make(map[string]bool)
+	for _, entry := range tree.Tree {
+		switch entry.Type {
+		case "blob":
+			nfiles++
+		case "tree":
+			dirs[entry.Path] = false
+		}
+		if dir := filepath.ToSlash(filepath.Dir(entry.Path)); dir != "." {
+			dirs[dir] = true
+		}
+	}
+	for dir, listed := range dirs {
+		if !listed {
+			// the contents of a directory are missing: the server does not list files recursively
+			return 0, ginerror{UError: fmt.Sprintf("directory %s not listed", dir), Origin: fn, Description: "the server does not list files recursively"}
+		}
+	}
+	return nfiles, nil
+}
+
+// LocalContentCheck reports which annexed content of the current version of
+// a repository would be lost, or only remain in the local clone, if the
+// repository was deleted from the server.
+type LocalContentCheck struct {
+	// Remote is the name of the remote of the local clone that refers to the repository on the server.
+	Remote string
+	// Files is the number of annexed files that were checked.
+	Files int
+	// ServerOnly lists the files whose content is only stored on the server.
+	ServerOnly []string
+	// LocalOnly lists the files whose content is only stored in the local clone and on the server.
+	LocalOnly []string
+}
+
+// DeletionAllowed returns true if the repository may be deleted from the server:
+// if the check found that no content would be lost, or if the repository is archived first or the deletion is forced.
+// Without archive or force, deletion is refused if the check could not be performed (not in a clone of the repository).
+func (check LocalContentCheck) DeletionAllowed(archive, force bool) bool {
+	return archive || force || (check.Remote != "" && len(check.ServerOnly) == 0)
+}
+
+// add records the locations of the content of an annexed file, where remoteuuid is the annex UUID of the server remote.
+// Content that is not on the server, or also stored in a location other than the local clone, is not affected by the deletion.
+func (check *LocalContentCheck) add(info git.AnnexWhereisRes, remoteuuid string) {
+	check.Files++
+	var here, onremote, elsewhere bool
+	for _, loc := range info.Whereis {
+		switch {
+		case loc.Here:
+			here = true
+		case loc.UUID == remoteuuid:
+			onremote = true
+		default:
+			elsewhere = true
+		}
+	}
+	if !onremote || elsewhere {
+		return
+	}
+	if here {
+		check.LocalOnly = append(check.LocalOnly, info.File)
+	} else {
+		check.ServerOnly = append(check.ServerOnly, info.File)
+	}
+}
+
+// findRepoRemote returns the name of the remote of the current repository that refers to the repository at repopath on the client's server.
+func (gincl *Client) findRepoRemote(repopath string) (string, error) {
+	remotes, err := git.RemoteShow()
+	if err != nil {
+		return "", err
+	}
+	repourl := strings.ToLower(fmt.Sprintf("%s/%s", gincl.GitAddress(), repopath))
+	for name, remoteurl := range remotes {
+		remoteurl = strings.ToLower(strings.TrimSuffix(strings.TrimSuffix(remoteurl, "/"), ".git"))
+		if remoteurl == repourl {
+			return name, nil
+		}
+	}
+	return "", nil
+}
+
+// CheckLocalContent checks the annexed content of the local clone of the repository at repopath (owner/name) in the current directory.
+// The Remote field of the result is empty if the current directory is not in a clone of the repository.
+func (gincl *Client) CheckLocalContent(repopath string) (LocalContentCheck, error) {
+	fn := fmt.Sprintf("CheckLocalContent(%s)", repopath)
+	var check LocalContentCheck
+	if !git.IsRepo() {
+		return check, nil
+	}
+	remote, err := gincl.findRepoRemote(repopath)
+	if err != nil || remote == "" {
+		return check, err
+	}
+	check.Remote = remote
+	remoteuuid, err := git.ConfigGet(fmt.Sprintf("remote.%s.annex-uuid", remote))
+	if err != nil || remoteuuid == "" {
+		// the remote has no annex: all content is stored locally or elsewhere
+		log.Write("Remote %s has no annex UUID", remote)
+		return check, nil
+	}
+
+	root, err := git.FindRepoRoot(".")
+	if err != nil {
+		return check, ginerror{UError: err.Error(), Origin: fn, Description: "failed to find the root of the local repository"}
+	}
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	os.Chdir(root)
+
+	wichan := make(chan git.AnnexWhereisRes)
+	go git.AnnexWhereis(nil, wichan)
+	for info := range wichan {
+		if info.Err != nil {
+			return check, ginerror{UError: info.Err.Error(), Origin: fn, Description: "failed to determine the locations of annexed content"}
+		}
+		check.add(info, remoteuuid)
+	}
+	return check, nil
+}
+
+// ArchiveRepo downloads the repository at repopath (owner/name) into a new directory inside dir, including the content of all versions of all annexed files.
+// The archive is a regular clone of the repository; its remote 'origin' refers to the repository on the server.
+// The working directory is changed to the archive.
+// The status channel 'archivechan' is closed when this function returns.
+func (gincl *Client) ArchiveRepo(repopath, dir string, archivechan chan<- git.RepoFileStatus) {
+	defer close(archivechan)
+	fn := fmt.Sprintf("ArchiveRepo(%s, %s)", repopath, dir)
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		archivechan <- git.RepoFileStatus{Err: ginerror{UError: err.Error(), Origin: fn, Description: fmt.Sprintf("failed to create archive directory %s", dir)}}
+		return
+	}
+	if err := os.Chdir(dir); err != nil {
+		archivechan <- git.RepoFileStatus{Err: ginerror{UError: err.Error(), Origin: fn, Description: fmt.Sprintf("failed to change to archive directory %s", dir)}}
+		return
+	}
+	clonechan := make(chan git.RepoFileStatus)
+	go gincl.CloneRepo(repopath, clonechan)
+	for stat := range clonechan {
+		archivechan <- stat
+		if stat.Err != nil {
+			return
+		}
+	}
+	getchan := make(chan git.RepoFileStatus)
+	go git.AnnexGetAll("origin", getchan)
+	for stat := range getchan {
+		archivechan <- stat
+	}
+}
+
+// MissingContent returns the annexed files of the current version of the repository in the current directory whose content is not available locally.
+// (git annex find --not --in here)
+func MissingContent() ([]string, error) {
+	cmd := git.AnnexCommand("find", "--not", "--in", "here")
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		log.Write("Error during annex find: %s", string(stderr))
+		return nil, ginerror{UError: string(stderr), Origin: "MissingContent()", Description: "failed to check the content of the local repository"}
+	}
+	var files []string
+	for _, line := range strings.Split(string(stdout), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			files = append(files, line)
+		}
+	}
+	return files, nil
+}
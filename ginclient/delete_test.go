@@ -0,0 +1,173 @@
+package ginclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/G-Node/gin-cli/git"
+	"github.com/G-Node/gin-cli/web"
+)
+
+func TestRepoFileCount(t *testing.T) {
+	type entry struct {
+		Path string `json:"path"`
+		Type string `json:"type"`
+	}
+	tests := []struct {
+		name      string
+		tree      []entry
+		truncated bool
+		status    int
+		nfiles    int
+		errmsg    string
+	}{
+		{
+			name:   "recursive",
+			tree:   []entry{{"README.md", "blob"}, {"data", "tree"}, {"data/raw", "tree"}, {"data/raw/a.nix", "blob"}, {"data/b.nix", "blob"}},
+			status: http.StatusOK,
+			nfiles: 3,
+		},
+		{
+			name:   "files only",
+			tree:   []entry{{"README.md", "blob"}, {"LICENSE", "blob"}},
+			status: http.StatusOK,
+			nfiles: 2,
+		},
+		{
+			name:   "empty",
+			tree:   []entry{},
+			status: http.StatusOK,
+			nfiles: 0,
+		},
+		{
+			// submodules are listed as commits and do not count as files
+			name:   "submodule",
+			tree:   []entry{{"README.md", "blob"}, {"lib", "tree"}, {"lib/external", "commit"}},
+			status: http.StatusOK,
+			nfiles: 1,
+		},
+		{
+			name:   "not recursive",
+			tree:   []entry{{"README.md", "blob"}, {"data", "tree"}},
+			status: http.StatusOK,
+			errmsg: "does not list files recursively",
+		},
+		{
+			name:   "nested directory not listed",
+			tree:   []entry{{"data", "tree"}, {"data/raw", "tree"}, {"data/b.nix", "blob"}},
+			status: http.StatusOK,
+			errmsg: "does not list files recursively",
+		},
+		{
+			name:      "truncated",
+			tree:      []entry{{"README.md", "blob"}},
+			truncated: true,
+			status:    http.StatusOK,
+			errmsg:    "incomplete",
+		},
+		{
+			name:   "not found",
+			status: http.StatusNotFound,
+			errmsg: "failed to retrieve the list of files",
+		},
+	}
+	for _, test := range tests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/api/v1/repos/alice/ephys/git/trees/master" || r.URL.Query().Get("recursive") != "1" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(test.status)
+			json.NewEncoder(w).Encode(map[string]interface{}{"tree": test.tree, "truncated": test.truncated})
+		}))
+		gincl := &Client{Client: web.New(srv.URL), ctx: context.Background()}
+		nfiles, err := gincl.RepoFileCount("alice/ephys", "master")
+		srv.Close()
+		if test.errmsg == "" {
+			if err != nil || nfiles != test.nfiles {
+				t.Errorf("[%s] Expected %d files, got %d (%v)", test.name, test.nfiles, nfiles, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.errmsg) {
+			t.Errorf("[%s] Expected error %q, got %d files (%v)", test.name, test.errmsg, nfiles, err)
+		}
+	}
+}
+
+// whereis builds the annex whereis result for a file from its locations.
+// The location "here" is the local clone; all other locations are annex UUIDs.
+func whereis(fname string, locations ...string) git.AnnexWhereisRes {
+	info := git.AnnexWhereisRes{File: fname}
+	for _, loc := range locations {
+		var wi struct {
+			Here        bool     `json:"here"`
+			UUID        string   `json:"uuid"`
+			URLs        []string `json:"urls"`
+			Description string   `json:"description"`
+		}
+		if loc == "here" {
+			wi.Here, wi.UUID = true, "local-uuid"
+		} else {
+			wi.UUID = loc
+		}
+		info.Whereis = append(info.Whereis, wi)
+	}
+	return info
+}
+
+func TestLocalContentCheck(t *testing.T) {
+	serveruuid := "server-uuid"
+	files := []git.AnnexWhereisRes{
+		whereis("server-only.nix", serveruuid),
+		whereis("local-and-server.nix", "here", serveruuid),
+		whereis("backed-up.nix", serveruuid, "backup-uuid"),
+		whereis("everywhere.nix", "here", serveruuid, "backup-uuid"),
+		whereis("local-only.nix", "here"),
+		whereis("elsewhere.nix", "backup-uuid"),
+		whereis("nowhere.nix"),
+	}
+	check := LocalContentCheck{Remote: "origin"}
+	for _, info := range files {
+		check.add(info, serveruuid)
+	}
+	if check.Files != len(files) {
+		t.Errorf("Expected %d checked files, got %d", len(files), check.Files)
+	}
+	if expected := []string{"server-only.nix"}; !reflect.DeepEqual(check.ServerOnly, expected) {
+		t.Errorf("Unexpected files with content only on the server: %v, expected %v", check.ServerOnly, expected)
+	}
+	if expected := []string{"local-and-server.nix"}; !reflect.DeepEqual(check.LocalOnly, expected) {
+		t.Errorf("Unexpected files with content only in the local clone: %v, expected %v", check.LocalOnly, expected)
+	}
+}
+
+func TestDeletionAllowed(t *testing.T) {
+	safe := LocalContentCheck{Remote: "origin", Files: 2, LocalOnly: []string{"a.nix"}}
+	unsafe := LocalContentCheck{Remote: "origin", Files: 2, ServerOnly: []string{"b.nix"}}
+	unchecked := LocalContentCheck{}
+	tests := []struct {
+		check   LocalContentCheck
+		archive bool
+		force   bool
+		allowed bool
+	}{
+		{safe, false, false, true},
+		{unsafe, false, false, false},
+		{unsafe, true, false, true},
+		{unsafe, false, true, true},
+		{unchecked, false, false, false},
+		{unchecked, true, false, true},
+		{unchecked, false, true, true},
+	}
+	for idx, test := range tests {
+		if allowed := test.check.DeletionAllowed(test.archive, test.force); allowed != test.allowed {
+			t.Errorf("[%d] DeletionAllowed(%t, %t) for %+v = %t, expected %t", idx, test.archive, test.force, test.check, allowed, test.allowed)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package ginclient
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/G-Node/gin-cli/ginclient/log"
+	"github.com/G-Node/gin-cli/git"
+)
+
+// States of files in a diff
+const (
+	DiffAdded      = "added"
+	DiffRemoved    = "removed"
+	DiffModified   = "modified"
+	DiffTypeChange = "typechange"
+)
+
+// FileVersion describes one side of a changed file in a diff.
+type FileVersion struct {
+	Hash string `json:"hash,omitempty"`
+	Mode string `json:"mode,omitempty"`
+	// Size of the file content (the size of the annexed content for annexed files).
+	// It is -1 if the size could not be determined.
+	Size     int64  `json:"size"`
+	Annexed  bool   `json:"annexed"`
+	AnnexKey string `json:"annexkey,omitempty"`
+}
+
+// FileDiff describes the difference of a single file between two versions.
+type FileDiff struct {
+	// FileName is relative to the root of the repository.
+	FileName string `json:"filename"`
+	State    string `json:"state"`
+	// Old and New are nil when the file does not exist in the respective version.
+	Old *FileVersion `json:"old"`
+	New *FileVersion `json:"new"`
+	// Text is the textual diff for files tracked by git (not annexed).
+	Text string `json:"text,omitempty"`
+}
+
+// Annexed returns true if either version of the file is annexed.
+func (fd FileDiff) Annexed() bool {
+	return (fd.Old != nil && fd.Old.Annexed) || (fd.New != nil && fd.New.Annexed)
+}
+
+// worktreeVersion describes the file in the working tree, which has not been
+// hashed by git. The annex key is determined from the file if it is a pointer
+// file or an annex symlink.
+func worktreeVersion(fname string, mode string) *FileVersion {
+	fv := &FileVersion{Mode: mode, Size: -1}
+	localname := filepath.FromSlash(fname)
+	finfo, err := os.Lstat(localname)
+	if err != nil {
+		log.Write("Failed to stat %s: %v", fname, err)
+		return fv
+	}
+	fv.Size = finfo.Size()
+	var content []byte
+	if finfo.Mode()&os.ModeSymlink != 0 {
+		target, rerr := os.Readlink(localname)
+		if rerr == nil {
+			content = []byte(target)
+		}
+	} else if finfo.Size() <= 1024 {
+		content, _ = ioutil.ReadFile(localname)
+	}
+	if key, ok := git.AnnexKeyFromPointer(content); ok {
+		fv.AnnexKey = key
+		fv.Annexed = true
+		if size, ok := git.AnnexKeySize(key); ok {
+			fv.Size = size
+		} else {
+			fv.Size = -1
+		}
+	}
+	return fv
+}
+
+// Diff compares two versions of the repository and returns the files that
+// differ, along with the sizes and annex keys of both versions of each file
+// and textual diffs for files tracked by git.
+// If rev2 is empty, rev1 is compared to the working tree.
+// If both are empty, the last commit is compared to the working tree.
+// Must be called from the root of the repository.
+func Diff(rev1, rev2 string, paths []string) ([]FileDiff, error) {
+	entries, err := git.DiffRaw(rev1, rev2, paths)
+	if err != nil {
+		return nil, err
+	}
+
+	var hashes []string
+	for _, entry := range entries {
+		for _, hash := range []string{entry.OldHash, entry.NewHash} {
+			if hash != git.NullHash {
+				hashes = append(hashes, hash)
+			}
+		}
+	}
+	blobinfo, err := git.CatFileBlobInfo(hashes)
+	if err != nil {
+		return nil, err
+	}
+	version := func(hash, mode string) *FileVersion {
+		info, ok := blobinfo[hash]
+		if !ok {
+			return &FileVersion{Hash: hash, Mode: mode, Size: -1}
+		}
+		return &FileVersion{Hash: hash, Mode: mode, Size: info.ContentSize(), Annexed: info.AnnexKey != "", AnnexKey: info.AnnexKey}
+	}
+
+	diffs := make([]FileDiff, 0, len(entries))
+	for _, entry := range entries {
+		fd := FileDiff{FileName: entry.Name}
+		switch entry.Status {
+		case "A":
+			fd.State = DiffAdded
+		case "D":
+			fd.State = DiffRemoved
+		case "T":
+			fd.State = DiffTypeChange
+		default:
+			fd.State = DiffModified
+		}
+		if fd.State != DiffAdded {
+			fd.Old = version(entry.OldHash, entry.OldMode)
+		}
+		if fd.State != DiffRemoved {
+			if entry.NewHash == git.NullHash {
+				// changed file in the working tree
+				fd.New = worktreeVersion(entry.Name, entry.NewMode)
+				if fd.Old != nil && fd.Old.Annexed {
+					// unlocked annexed files contain the content itself when modified
+					fd.New.Annexed = true
+				}
+			} else {
+				fd.New = version(entry.NewHash, entry.NewMode)
+			}
+		}
+		diffs = append(diffs, fd)
+	}
+
+	for idx := range diffs {
+		if diffs[idx].Annexed() {
+			continue
+		}
+		// one invocation per file keeps the text diffs separate
+		text, terr := git.DiffText(rev1, rev2, []string{diffs[idx].FileName})
+		if terr != nil {
+			log.Write("Failed to get text diff for %s: %v", diffs[idx].FileName, terr)
+			continue
+		}
+		diffs[idx].Text = text
+	}
+	return diffs, nil
+}
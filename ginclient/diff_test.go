@@ -0,0 +1,38 @@
+package ginclient
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWorktreeVersion(t *testing.T) {
+	tmpdir := t.TempDir()
+	cwd, _ := os.Getwd()
+	os.Chdir(tmpdir)
+	defer os.Chdir(cwd)
+
+	key := "SHA256E-s1048576--5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03.dat"
+	os.Mkdir("data", 0777)
+	ioutil.WriteFile(filepath.Join("data", "pointer.dat"), []byte("/annex/objects/"+key+"\n"), 0666)
+	ioutil.WriteFile("text.txt", []byte("some text\n"), 0666)
+	os.Symlink(".git/annex/objects/Xq/9P/"+key+"/"+key, "locked.dat")
+
+	tests := []struct {
+		fname    string
+		size     int64
+		annexkey string
+	}{
+		{"data/pointer.dat", 1048576, key},
+		{"locked.dat", 1048576, key},
+		{"text.txt", 10, ""},
+		{"missing.txt", -1, ""},
+	}
+	for _, test := range tests {
+		fv := worktreeVersion(test.fname, "100644")
+		if fv.Size != test.size || fv.AnnexKey != test.annexkey || fv.Annexed != (test.annexkey != "") || fv.Mode != "100644" {
+			t.Errorf("Unexpected version of %s in working tree: %+v", test.fname, fv)
+		}
+	}
+}
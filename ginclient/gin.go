@@ -1,18 +1,20 @@
 package ginclient
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"os"
-
 	"net/http"
+	"net/url"
 
 	"github.com/G-Node/gin-cli/ginclient/config"
+	"github.com/G-Node/gin-cli/ginclient/credentials"
 	"github.com/G-Node/gin-cli/ginclient/log"
 	"github.com/G-Node/gin-cli/git"
 	"github.com/G-Node/gin-cli/git/shell"
 	"github.com/G-Node/gin-cli/web"
+	"github.com/fatih/color"
 	gogs "github.com/gogits/go-gogs-client"
 )
 
@@ -31,16 +33,47 @@ type GINUser struct {
 	AvatarURL string `json:"avatar_url"`
 }
 
+// baseContext is the context of the requests made by new clients.
+var baseContext = context.Background()
+
+// SetContext sets the context for the requests made by clients created after the call.
+// Requests in progress are cancelled when the context is done (e.g., when the user interrupts the program).
+func SetContext(ctx context.Context) {
+	baseContext = ctx
+}
+
 // New returns a new client for the GIN server, configured with the server referred to by the alias in the argument.
 func New(alias string) *Client {
 	if alias == "" {
-		return &Client{Client: web.New(""), srvalias: ""}
+		return &Client{Client: web.New(""), srvalias: "", ctx: baseContext}
 	}
 	srvcfg, ok := config.Read().Servers[alias]
 	if !ok {
-		return &Client{Client: web.New(""), srvalias: ""}
+		return &Client{Client: web.New(""), srvalias: "", ctx: baseContext}
+	}
+	return &Client{Client: web.NewWithOptions(srvcfg.Web.AddressStr(), webOptions(alias, srvcfg.Web)), srvalias: alias, ctx: baseContext}
+}
+
+// insecureWarned records the servers for which the warning about disabled certificate verification has been printed.
+var insecureWarned = make(map[string]bool)
+
+// webOptions returns the connection options for the web server configuration and the global network settings.
+func webOptions(alias string, webconf config.WebCfg) web.Options {
+	netconf := config.Read().Network
+	if webconf.Insecure && !insecureWarned[alias] {
+		fmt.Fprintf(color.Error, "%s certificate verification is DISABLED for server '%s' (web.insecure): connections can be intercepted and credentials stolen\n", color.New(color.FgRed).Sprint("[WARNING]"), alias)
+		insecureWarned[alias] = true
+	}
+	return web.Options{
+		CABundle:   git.ExpandHome(webconf.CABundle),
+		Insecure:   webconf.Insecure,
+		ClientCert: git.ExpandHome(webconf.ClientCert),
+		ClientKey:  git.ExpandHome(webconf.ClientKey),
+		Proxy:      webconf.Proxy,
+		Timeout:    netconf.Timeout,
+		Retries:    netconf.Retries,
+		Backoff:    netconf.Backoff,
 	}
-	return &Client{Client: web.New(srvcfg.Web.AddressStr()), srvalias: alias}
 }
 
 // AccessToken represents a API access token.
@@ -53,6 +86,7 @@ type AccessToken struct {
 type Client struct {
 	*web.Client
 	srvalias string
+	ctx      context.Context
 }
 
 // GitAddress returns the full address string for the configured git server
@@ -60,7 +94,7 @@ func (gincl *Client) GitAddress() string {
 	if gincl.srvalias == "" {
 		return ""
 	}
-	return config.Read().Servers[gincl.srvalias].Git.AddressStr()
+	return config.Read().Servers[gincl.srvalias].GitAddressStr()
 }
 
 // WebAddress returns the full address string for the configured web server
@@ -69,48 +103,76 @@ func (gincl *Client) WebAddress() string {
 }
 
 // GetUserKeys fetches the public keys that the user has added to the auth server.
+// All pages of the list are retrieved.
 func (gincl *Client) GetUserKeys() ([]gogs.PublicKey, error) {
 	fn := "GetUserKeys()"
 	var keys []gogs.PublicKey
-	res, err := gincl.Get("/api/v1/user/keys")
+	handler := func(res *http.Response, b []byte) (int, error) {
+		switch code := res.StatusCode; {
+		case code == http.StatusUnauthorized:
+			return 0, ginerror{UError: res.Status, Origin: fn, Description: "authorisation failed"}
+		case code == http.StatusInternalServerError:
+			return 0, ginerror{UError: res.Status, Origin: fn, Description: "server error"}
+		case code != http.StatusOK:
+			return 0, ginerror{UError: res.Status, Origin: fn} // Unexpected error
+		}
+		var page []gogs.PublicKey
+		if err := json.Unmarshal(b, &page); err != nil {
+			return 0, ginerror{UError: err.Error(), Origin: fn, Description: "failed to parse response body"}
+		}
+		keys = append(keys, page...)
+		return len(page), nil
+	}
+	if err := gincl.GetPages(gincl.ctx, "/api/v1/user/keys", handler); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// RequestAccount requests a specific account by name.
+func (gincl *Client) RequestAccount(name string) (gogs.User, error) {
+	fn := fmt.Sprintf("RequestAccount(%s)", name)
+	var acc gogs.User
+	res, err := gincl.Get(gincl.ctx, fmt.Sprintf("/api/v1/users/%s", name))
 	if err != nil {
-		return nil, err // return error from Get() directly
+		return acc, err // return error from Get() directly
 	}
 	switch code := res.StatusCode; {
+	case code == http.StatusNotFound:
+		return acc, ginerror{UError: res.Status, Origin: fn, Description: fmt.Sprintf("requested user '%s' does not exist", name)}
 	case code == http.StatusUnauthorized:
-		return nil, ginerror{UError: res.Status, Origin: fn, Description: "authorisation failed"}
+		return acc, ginerror{UError: res.Status, Origin: fn, Description: "authorisation failed"}
 	case code == http.StatusInternalServerError:
-		return nil, ginerror{UError: res.Status, Origin: fn, Description: "server error"}
+		return acc, ginerror{UError: res.Status, Origin: fn, Description: "server error"}
 	case code != http.StatusOK:
-		return nil, ginerror{UError: res.Status, Origin: fn} // Unexpected error
+		return acc, ginerror{UError: res.Status, Origin: fn} // Unexpected error
 	}
 
 	defer web.CloseRes(res.Body)
 
 	b, err := ioutil.ReadAll(res.Body)
 	if err != nil {
-		return nil, ginerror{UError: err.Error(), Origin: fn, Description: "failed to read response body"}
+		return acc, ginerror{UError: err.Error(), Origin: fn, Description: "failed to read response body"}
 	}
-	err = json.Unmarshal(b, &keys)
+	err = json.Unmarshal(b, &acc)
 	if err != nil {
-		return nil, ginerror{UError: err.Error(), Origin: fn, Description: "failed to parse response body"}
+		err = ginerror{UError: err.Error(), Origin: fn, Description: "failed to parse response body"}
 	}
-	return keys, nil
+	return acc, err
 }
 
-// RequestAccount requests a specific account by name.
-func (gincl *Client) RequestAccount(name string) (gogs.User, error) {
-	fn := fmt.Sprintf("RequestAccount(%s)", name)
+// GetCurrentUser requests the account of the user the client's token belongs to.
+// It can be used to check the validity of a token.
+func (gincl *Client) GetCurrentUser() (gogs.User, error) {
+	fn := "GetCurrentUser()"
 	var acc gogs.User
-	res, err := gincl.Get(fmt.Sprintf("/api/v1/users/%s", name))
+	res, err := gincl.Get(gincl.ctx, "/api/v1/user")
 	if err != nil {
 		return acc, err // return error from Get() directly
 	}
 	switch code := res.StatusCode; {
-	case code == http.StatusNotFound:
-		return acc, ginerror{UError: res.Status, Origin: fn, Description: fmt.Sprintf("requested user '%s' does not exist", name)}
-	case code == http.StatusUnauthorized:
-		return acc, ginerror{UError: res.Status, Origin: fn, Description: "authorisation failed"}
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		return acc, ginerror{UError: res.Status, Origin: fn, Description: "authorisation failed: the access token is invalid or has been revoked"}
 	case code == http.StatusInternalServerError:
 		return acc, ginerror{UError: res.Status, Origin: fn, Description: "server error"}
 	case code != http.StatusOK:
@@ -140,7 +202,7 @@ func (gincl *Client) AddKey(key, description string, force bool) error {
 		// Attempting to delete potential existing key that matches the title
 		_ = gincl.DeletePubKeyByTitle(description)
 	}
-	res, err := gincl.Post("/api/v1/user/keys", newkey)
+	res, err := gincl.Post(gincl.ctx, "/api/v1/user/keys", newkey)
 	if err != nil {
 		return err // return error from Post() directly
 	}
@@ -163,11 +225,11 @@ func (gincl *Client) DeletePubKey(id int64) error {
 	fn := "DeletePubKey()"
 
 	address := fmt.Sprintf("/api/v1/user/keys/%d", id)
-	res, err := gincl.Delete(address)
-	defer web.CloseRes(res.Body)
+	res, err := gincl.Delete(gincl.ctx, address)
 	if err != nil {
 		return err // Return error from Delete() directly
 	}
+	defer web.CloseRes(res.Body)
 	switch code := res.StatusCode; {
 	case code == http.StatusInternalServerError:
 		return ginerror{UError: res.Status, Origin: fn, Description: "server error"}
@@ -222,7 +284,7 @@ func (gincl *Client) DeletePubKeyByIdx(idx int) (string, error) {
 
 // Login requests a token from the auth server and stores the username and
 // token to file and adds them to the Client.
-// It also generates a key pair for the user for use in git commands.
+// It also generates a key pair for the user for use in git commands, unless the server uses HTTPS for git operations.
 // (See also NewToken)
 func (gincl *Client) Login(username, password, clientID string) error {
 	// retrieve user's active tokens
@@ -257,35 +319,67 @@ func (gincl *Client) Login(username, password, clientID string) error {
 		return fmt.Errorf("Error while storing token: %s", err.Error())
 	}
 
-	// Make keys
+	return gincl.setUpGitAccess()
+}
+
+// LoginWithToken logs in using an existing access token instead of a username and password.
+// The token is validated with the server and the username is determined from the account it belongs to.
+// The token is stored and a key pair is generated for use in git commands, as with Login.
+func (gincl *Client) LoginWithToken(token string) (gogs.User, error) {
+	gincl.UserToken.Token = token
+	gincl.UserToken.Username = ""
+	user, err := gincl.GetCurrentUser()
+	if err != nil {
+		gincl.UserToken.Token = ""
+		return user, err
+	}
+	gincl.UserToken.Username = user.UserName
+	log.Write("Token login successful. Username: %s", user.UserName)
+
+	// Store token (to file)
+	err = gincl.StoreToken(gincl.srvalias)
+	if err != nil {
+		return user, fmt.Errorf("Error while storing token: %s", err.Error())
+	}
+
+	return user, gincl.setUpGitAccess()
+}
+
+// setUpGitAccess creates the session key used for git operations over SSH.
+// Servers that use HTTPS for git operations authenticate with the stored token instead and need no key.
+func (gincl *Client) setUpGitAccess() error {
+	if config.Read().Servers[gincl.srvalias].Git.UseHTTPS() {
+		log.Write("Server %s uses HTTPS for git operations: not creating session key", gincl.srvalias)
+		return nil
+	}
 	return gincl.MakeSessionKey()
 }
 
 // GetTokens returns all the user's active access tokens from the GIN server.
+// All pages of the list are retrieved.
 func (gincl *Client) GetTokens(username, password string) ([]AccessToken, error) {
 	fn := "GetTokens()"
 	address := fmt.Sprintf("/api/v1/users/%s/tokens", username)
-	res, err := gincl.GetBasicAuth(address, username, password)
-	if err != nil {
-		return nil, err // return error from GetBasicAuth directly
-	}
-	switch code := res.StatusCode; {
-	case code == http.StatusInternalServerError:
-		return nil, ginerror{UError: res.Status, Origin: fn, Description: "server error"}
-	case code == http.StatusUnauthorized:
-		return nil, ginerror{UError: res.Status, Origin: fn, Description: "authorisation failed"}
-	case code != http.StatusOK:
-		return nil, ginerror{UError: res.Status, Origin: fn} // Unexpected error
-	}
-	data, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		return nil, err
-	}
-	log.Write("Got response: %s", res.Status)
 	tokens := []AccessToken{}
-	err = json.Unmarshal(data, &tokens)
-	if err != nil {
-		return nil, ginerror{UError: err.Error(), Origin: fn, Description: "failed to parse response body"}
+	handler := func(res *http.Response, data []byte) (int, error) {
+		switch code := res.StatusCode; {
+		case code == http.StatusInternalServerError:
+			return 0, ginerror{UError: res.Status, Origin: fn, Description: "server error"}
+		case code == http.StatusUnauthorized:
+			return 0, ginerror{UError: res.Status, Origin: fn, Description: "authorisation failed"}
+		case code != http.StatusOK:
+			return 0, ginerror{UError: res.Status, Origin: fn} // Unexpected error
+		}
+		log.Write("Got response: %s", res.Status)
+		var page []AccessToken
+		if err := json.Unmarshal(data, &page); err != nil {
+			return 0, ginerror{UError: err.Error(), Origin: fn, Description: "failed to parse response body"}
+		}
+		tokens = append(tokens, page...)
+		return len(page), nil
+	}
+	if err := gincl.GetPagesBasicAuth(gincl.ctx, address, username, password, handler); err != nil {
+		return nil, err // return error from GetPagesBasicAuth directly
 	}
 	return tokens, nil
 }
@@ -296,7 +390,7 @@ func (gincl *Client) NewToken(username, password, clientID string) error {
 	fn := "NewToken()"
 	tokenCreate := &gogs.CreateAccessTokenOption{Name: clientID}
 	address := fmt.Sprintf("/api/v1/users/%s/tokens", username)
-	res, err := gincl.PostBasicAuth(address, username, password, tokenCreate)
+	res, err := gincl.PostBasicAuth(gincl.ctx, address, username, password, tokenCreate)
 	if err != nil {
 		return err // return error from PostBasicAuth directly
 	}
@@ -323,32 +417,80 @@ func (gincl *Client) NewToken(username, password, clientID string) error {
 	return nil
 }
 
+// DeleteAccessToken deletes the user's access token with the given name from the GIN server.
+// Any client using the token will no longer be able to access the server.
+// The Gogs API only provides listing and creating tokens, so servers without
+// support for deleting tokens respond with 404 or 405, which is reported as unsupported.
+func (gincl *Client) DeleteAccessToken(username, password, name string) error {
+	fn := fmt.Sprintf("DeleteAccessToken(%s)", name)
+	address := fmt.Sprintf("/api/v1/users/%s/tokens/%s", url.PathEscape(username), url.PathEscape(name))
+	res, err := gincl.DeleteBasicAuth(gincl.ctx, address, username, password)
+	if err != nil {
+		return err // return error from DeleteBasicAuth directly
+	}
+	defer web.CloseRes(res.Body)
+	switch code := res.StatusCode; {
+	case code == http.StatusInternalServerError:
+		return ginerror{UError: res.Status, Origin: fn, Description: "server error"}
+	case code == http.StatusUnauthorized:
+		return ginerror{UError: res.Status, Origin: fn, Description: "authorisation failed"}
+	case code == http.StatusNotFound, code == http.StatusMethodNotAllowed:
+		return ginerror{UError: res.Status, Origin: fn, Description: "server does not support revoking tokens"}
+	case code != http.StatusNoContent && code != http.StatusOK:
+		return ginerror{UError: res.Status, Origin: fn} // Unexpected error
+	}
+	log.Write("Deleted access token %s", name)
+	return nil
+}
+
+// CurrentTokenName returns the name of the token the client is logged in with,
+// by matching the loaded token against the user's tokens on the server.
+func (gincl *Client) CurrentTokenName(tokens []AccessToken) (string, bool) {
+	if gincl.Token == "" {
+		return "", false
+	}
+	for _, token := range tokens {
+		if token.Sha1 == gincl.Token {
+			return token.Name, true
+		}
+	}
+	return "", false
+}
+
 // LoadToken calls the embedded UserToken.LoadToken function with the configured server alias.
+// If the token was provided through the environment without a username, the
+// username is retrieved from the server.
 func (gincl *Client) LoadToken() error {
-	return gincl.UserToken.LoadToken(gincl.srvalias)
+	err := gincl.UserToken.LoadToken(gincl.srvalias)
+	if err != nil {
+		return err
+	}
+	if gincl.Username == "" && gincl.Token != "" {
+		user, uerr := gincl.GetCurrentUser()
+		if uerr != nil {
+			return uerr
+		}
+		gincl.Username = user.UserName
+	}
+	return nil
 }
 
 // Logout logs out the currently logged in user in 3 steps:
-// 1. Remove the public key matching the current hostname from the server.
-// 2. Delete the private key file from the local machine.
+// 1. Remove the public key of the session key (or matching the current hostname) from the server.
+// 2. Delete the private key from the local machine.
 // 3. Delete the user token.
 func (gincl *Client) Logout() {
 	// 1. Delete public key
-	hostname, err := os.Hostname()
-	if err != nil {
-		log.Write("Could not retrieve hostname")
-		hostname = unknownhostname
+	key, err := gincl.findSessionKey()
+	if err == nil {
+		err = gincl.DeletePubKey(key.ID)
 	}
-
-	currentkeyname := fmt.Sprintf("GIN Client: %s@%s", gincl.Username, hostname)
-	err = gincl.DeletePubKeyByTitle(currentkeyname)
 	if err != nil {
 		log.Write(err.Error())
 	}
 
 	// 2. Delete private key
-	privKeyFiles := git.PrivKeyPath()
-	err = os.Remove(privKeyFiles[gincl.srvalias])
+	err = credentials.Delete(git.KeyName(gincl.srvalias))
 	if err != nil {
 		log.Write("Error deleting key file")
 	} else {
@@ -374,8 +516,7 @@ func SetDefaultServer(alias string) error {
 	if _, ok := conf.Servers[alias]; !ok {
 		return fmt.Errorf("server with alias '%s' does not exist", alias)
 	}
-	config.SetDefaultServer(alias)
-	return nil
+	return config.SetDefaultServer(alias)
 }
 
 // RemoveServer removes a server from the user configuration.
@@ -385,6 +526,5 @@ func RemoveServer(alias string) error {
 	if _, ok := conf.Servers[alias]; !ok {
 		return fmt.Errorf("server with alias '%s' does not exist", alias)
 	}
-	config.RmServerConf(alias)
-	return nil
+	return config.RmServerConf(alias)
 }
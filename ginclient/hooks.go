@@ -0,0 +1,169 @@
+package ginclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/G-Node/gin-cli/ginclient/log"
+	"github.com/G-Node/gin-cli/web"
+	gogs "github.com/gogits/go-gogs-client"
+)
+
+// Functions for managing the webhooks of repositories.
+
+// HookEvents are the repository events that can trigger a webhook.
+var HookEvents = []string{"create", "delete", "fork", "push", "issues", "issue_comment", "pull_request", "release"}
+
+// Content types of webhook payloads.
+const (
+	HookContentJSON = "json"
+	HookContentForm = "form"
+)
+
+// HookOptions holds the settings of a new webhook.
+type HookOptions struct {
+	// URL is the address that receives the payload when the hook is triggered.
+	URL string
+	// ContentType is the encoding of the payload (HookContentJSON or HookContentForm).
+	ContentType string
+	// Secret is used by the server to sign the payload (sent in the X-Gogs-Signature header).
+	Secret string
+	// Events are the events that trigger the hook (see HookEvents).
+	Events []string
+	// Inactive creates the hook disabled.
+	Inactive bool
+}
+
+// hookStatusError returns the error for an unsuccessful response of a hook request.
+func hookStatusError(res *http.Response, fn, repopath string) error {
+	switch code := res.StatusCode; {
+	case code == http.StatusNotFound:
+		return ginerror{UError: res.Status, Origin: fn, Description: fmt.Sprintf("repository '%s' or webhook does not exist", repopath)}
+	case code == http.StatusForbidden:
+		return ginerror{UError: res.Status, Origin: fn, Description: fmt.Sprintf("webhooks can only be managed by administrators of repository '%s'", repopath)}
+	case code == http.StatusUnprocessableEntity:
+		return ginerror{UError: res.Status, Origin: fn, Description: "invalid webhook settings"}
+	case code == http.StatusUnauthorized:
+		return ginerror{UError: res.Status, Origin: fn, Description: "authorisation failed"}
+	case code == http.StatusInternalServerError:
+		return ginerror{UError: res.Status, Origin: fn, Description: "server error"}
+	}
+	return ginerror{UError: res.Status, Origin: fn} // Unexpected error
+}
+
+// ListHooks gets the webhooks of the repository at repopath (owner/name).
+// All pages of the list are retrieved.
+func (gincl *Client) ListHooks(repopath string) ([]gogs.Hook, error) {
+	fn := fmt.Sprintf("ListHooks(%s)", repopath)
+	log.Write("Retrieving webhooks of %s", repopath)
+	var hooks []gogs.Hook
+	handler := func(res *http.Response, b []byte) (int, error) {
+		if res.StatusCode != http.StatusOK {
+			return 0, hookStatusError(res, fn, repopath)
+		}
+		var page []gogs.Hook
+		if err := json.Unmarshal(b, &page); err != nil {
+			return 0, ginerror{UError: err.Error(), Origin: fn, Description: "failed to parse response body"}
+		}
+		hooks = append(hooks, page...)
+		return len(page), nil
+	}
+	if err := gincl.GetPages(gincl.ctx, fmt.Sprintf("/api/v1/repos/%s/hooks", repopath), handler); err != nil {
+		return nil, err
+	}
+	return hooks, nil
+}
+
+// CreateHook adds a webhook to the repository at repopath (owner/name) and returns the new hook.
+// If no events are specified, the hook is triggered by pushes. The content type defaults to JSON.
+func (gincl *Client) CreateHook(repopath string, opts HookOptions) (gogs.Hook, error) {
+	fn := fmt.Sprintf("CreateHook(%s)", repopath)
+	log.Write("Creating webhook for %s: %s %v", repopath, opts.URL, opts.Events)
+	var hook gogs.Hook
+	if opts.URL == "" {
+		return hook, ginerror{UError: "empty URL", Origin: fn, Description: "a webhook requires a payload URL"}
+	}
+	contenttype := opts.ContentType
+	if contenttype == "" {
+		contenttype = HookContentJSON
+	}
+	if contenttype != HookContentJSON && contenttype != HookContentForm {
+		return hook, ginerror{UError: "invalid content type " + contenttype, Origin: fn, Description: fmt.Sprintf("invalid content type '%s': must be '%s' or '%s'", contenttype, HookContentJSON, HookContentForm)}
+	}
+	events := opts.Events
+	if len(events) == 0 {
+		events = []string{"push"}
+	}
+	newhook := gogs.CreateHookOption{
+		Type: "gogs",
+		Config: map[string]string{
+			"url":          opts.URL,
+			"content_type": contenttype,
+			"secret":       opts.Secret,
+		},
+		Events: events,
+		Active: !opts.Inactive,
+	}
+	res, err := gincl.Post(gincl.ctx, fmt.Sprintf("/api/v1/repos/%s/hooks", repopath), newhook)
+	if err != nil {
+		return hook, err // return error from Post() directly
+	}
+	defer web.CloseRes(res.Body)
+	if res.StatusCode != http.StatusCreated {
+		return hook, hookStatusError(res, fn, repopath)
+	}
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return hook, ginerror{UError: err.Error(), Origin: fn, Description: "failed to read response body"}
+	}
+	if err = json.Unmarshal(b, &hook); err != nil {
+		return hook, ginerror{UError: err.Error(), Origin: fn, Description: "failed to parse response body"}
+	}
+	return hook, nil
+}
+
+// DeleteHook removes the webhook with the given ID from the repository at repopath (owner/name).
+func (gincl *Client) DeleteHook(repopath string, id int64) error {
+	fn := fmt.Sprintf("DeleteHook(%s, %d)", repopath, id)
+	log.Write("Deleting webhook %d of %s", id, repopath)
+	res, err := gincl.Delete(gincl.ctx, fmt.Sprintf("/api/v1/repos/%s/hooks/%d", repopath, id))
+	if err != nil {
+		return err // return error from Delete() directly
+	}
+	defer web.CloseRes(res.Body)
+	if res.StatusCode != http.StatusNoContent && res.StatusCode != http.StatusOK {
+		return hookStatusError(res, fn, repopath)
+	}
+	return nil
+}
+
+// TestHook asks the server to deliver a test payload (for the latest commit) to the webhook with the given ID of the repository at repopath (owner/name).
+// This requires a server that provides POST /repos/:owner/:repo/hooks/:id/tests (e.g., Gitea); Gogs does not.
+func (gincl *Client) TestHook(repopath string, id int64) error {
+	fn := fmt.Sprintf("TestHook(%s, %d)", repopath, id)
+	log.Write("Testing webhook %d of %s", id, repopath)
+	res, err := gincl.Post(gincl.ctx, fmt.Sprintf("/api/v1/repos/%s/hooks/%d/tests", repopath, id), nil)
+	if err != nil {
+		return err // return error from Post() directly
+	}
+	defer web.CloseRes(res.Body)
+	switch res.StatusCode {
+	case http.StatusNoContent, http.StatusOK:
+		return nil
+	case http.StatusNotFound, http.StatusMethodNotAllowed:
+		// servers without the route respond with 404, like for a missing webhook: check whether the webhook exists
+		hooks, lerr := gincl.ListHooks(repopath)
+		if lerr != nil {
+			return lerr
+		}
+		for _, hook := range hooks {
+			if hook.ID == id {
+				return ginerror{UError: res.Status, Origin: fn, Description: "the server does not support testing webhooks"}
+			}
+		}
+		return ginerror{UError: res.Status, Origin: fn, Description: fmt.Sprintf("webhook %d of repository '%s' does not exist", id, repopath)}
+	}
+	return hookStatusError(res, fn, repopath)
+}
@@ -0,0 +1,172 @@
+package ginclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/G-Node/gin-cli/web"
+	gogs "github.com/gogits/go-gogs-client"
+)
+
+// fakeHookServer serves the webhook routes of the Gogs API (list, create, delete) for one repository.
+// Gogs has no route for testing webhooks: like Gogs, it responds to test requests with 404.
+type fakeHookServer struct {
+	sync.Mutex
+	repopath string
+	token    string
+	hooks    map[int64]gogs.Hook
+	nextID   int64
+}
+
+func (srv *fakeHookServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	srv.Lock()
+	defer srv.Unlock()
+	if r.Header.Get("Authorization") != "token "+srv.token {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	prefix := fmt.Sprintf("/api/v1/repos/%s/hooks", srv.repopath)
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/"), "/")
+	switch {
+	case parts[0] == "" && r.Method == http.MethodGet:
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		hooks := []gogs.Hook{}
+		if page <= 1 {
+			for id := int64(1); id < srv.nextID; id++ {
+				if hook, ok := srv.hooks[id]; ok {
+					hooks = append(hooks, hook)
+				}
+			}
+		}
+		json.NewEncoder(w).Encode(hooks)
+	case parts[0] == "" && r.Method == http.MethodPost:
+		var opt gogs.CreateHookOption
+		if err := json.NewDecoder(r.Body).Decode(&opt); err != nil || opt.Type != "gogs" || opt.Config["url"] == "" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		}
+		hook := gogs.Hook{ID: srv.nextID, Type: opt.Type, Config: opt.Config, Events: opt.Events, Active: opt.Active}
+		srv.hooks[hook.ID] = hook
+		srv.nextID++
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(hook)
+	default:
+		id, _ := strconv.ParseInt(parts[0], 10, 64)
+		if _, ok := srv.hooks[id]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch {
+		case len(parts) == 1 && r.Method == http.MethodDelete:
+			delete(srv.hooks, id)
+			w.WriteHeader(http.StatusNoContent)
+		case len(parts) == 1:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+}
+
+func TestHooks(t *testing.T) {
+	fake := &fakeHookServer{repopath: "alice/ephys", token: "hooktoken", hooks: make(map[int64]gogs.Hook), nextID: 1}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	gincl := &Client{Client: web.New(srv.URL), ctx: context.Background()}
+	gincl.Token = fake.token
+
+	hooks, err := gincl.ListHooks("alice/ephys")
+	if err != nil || len(hooks) != 0 {
+		t.Fatalf("Expected empty hook list, got %v (%v)", hooks, err)
+	}
+
+	hook, err := gincl.CreateHook("alice/ephys", HookOptions{URL: "https://ci.example.com/validate", Secret: "s3cret"})
+	if err != nil {
+		t.Fatalf("Failed to create hook: %s", err.Error())
+	}
+	if hook.ID != 1 || !hook.Active || hook.Config["content_type"] != HookContentJSON || hook.Config["secret"] != "s3cret" {
+		t.Fatalf("Unexpected hook settings: %+v", hook)
+	}
+	if len(hook.Events) != 1 || hook.Events[0] != "push" {
+		t.Fatalf("Expected default push event, got %v", hook.Events)
+	}
+	_, err = gincl.CreateHook("alice/ephys", HookOptions{URL: "https://ci.example.com/issues", ContentType: HookContentForm, Events: []string{"issues", "release"}, Inactive: true})
+	if err != nil {
+		t.Fatalf("Failed to create second hook: %s", err.Error())
+	}
+	if _, err = gincl.CreateHook("alice/ephys", HookOptions{URL: "https://ci.example.com", ContentType: "xml"}); err == nil {
+		t.Fatal("Expected error for invalid content type")
+	}
+	if _, err = gincl.CreateHook("alice/ephys", HookOptions{}); err == nil {
+		t.Fatal("Expected error for missing URL")
+	}
+
+	hooks, err = gincl.ListHooks("alice/ephys")
+	if err != nil || len(hooks) != 2 {
+		t.Fatalf("Expected 2 hooks, got %v (%v)", hooks, err)
+	}
+	if hooks[1].Active || hooks[1].Config["content_type"] != HookContentForm {
+		t.Fatalf("Unexpected settings of second hook: %+v", hooks[1])
+	}
+
+	// Gogs can not test webhooks
+	if err = gincl.TestHook("alice/ephys", 1); err == nil || !strings.Contains(err.Error(), "does not support testing webhooks") {
+		t.Fatalf("Expected unsupported error when testing hook, got %v", err)
+	}
+	if err = gincl.TestHook("alice/ephys", 5); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("Expected error when testing missing hook, got %v", err)
+	}
+
+	if err = gincl.DeleteHook("alice/ephys", 1); err != nil {
+		t.Fatalf("Failed to delete hook: %s", err.Error())
+	}
+	if err = gincl.DeleteHook("alice/ephys", 1); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("Expected error when deleting missing hook, got %v", err)
+	}
+	hooks, err = gincl.ListHooks("alice/ephys")
+	if err != nil || len(hooks) != 1 || hooks[0].ID != 2 {
+		t.Fatalf("Expected only hook 2 to remain, got %v (%v)", hooks, err)
+	}
+
+	if _, err = gincl.ListHooks("bob/other"); err == nil {
+		t.Fatal("Expected error for unknown repository")
+	}
+	gincl.Token = "invalid"
+	if _, err = gincl.ListHooks("alice/ephys"); err == nil || !strings.Contains(err.Error(), "authorisation failed") {
+		t.Fatalf("Expected authorisation error, got %v", err)
+	}
+}
+
+func TestTestHook(t *testing.T) {
+	// a server that provides the route for testing webhooks (e.g., Gitea)
+	var tested []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v1/repos/alice/ephys/hooks/3/tests" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		tested = append(tested, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+	gincl := &Client{Client: web.New(srv.URL), ctx: context.Background()}
+	gincl.Token = "hooktoken"
+
+	if err := gincl.TestHook("alice/ephys", 3); err != nil {
+		t.Fatalf("Failed to test hook: %s", err.Error())
+	}
+	if len(tested) != 1 {
+		t.Fatalf("Hook test was not delivered: %v", tested)
+	}
+}
@@ -0,0 +1,238 @@
+package ginclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/G-Node/gin-cli/ginclient/log"
+	"github.com/G-Node/gin-cli/git"
+	"github.com/G-Node/gin-cli/web"
+	gogs "github.com/gogits/go-gogs-client"
+)
+
+// Functions for working with the issues of repositories.
+
+// FileRef refers to a file in a repository at a specific commit.
+type FileRef struct {
+	// Path is the path of the file relative to the root of the repository (with forward slashes).
+	Path string `json:"path"`
+	// Commit is the full hash of the commit.
+	Commit string `json:"commit"`
+}
+
+// splitFileRev splits a file reference argument into a path and a revision.
+// Since paths may contain '@', the text after an '@' is only taken as the
+// revision if it names a commit. If no revision is found, the whole argument
+// is the path, unless it does not exist while the part before the last '@'
+// does, in which case the revision is returned as unknown.
+func splitFileRev(arg string) (fpath, rev string, unknownrev bool) {
+	for idx := strings.LastIndex(arg, "@"); idx > 0; idx = strings.LastIndex(arg[:idx], "@") {
+		candidate := arg[idx+1:]
+		if candidate == "" || strings.HasPrefix(candidate, "-") {
+			continue
+		}
+		if _, err := git.RevParse(candidate + "^{commit}"); err == nil {
+			return arg[:idx], candidate, false
+		}
+	}
+	if idx := strings.LastIndex(arg, "@"); idx > 0 {
+		if _, err := os.Lstat(arg); os.IsNotExist(err) {
+			if _, err := os.Lstat(arg[:idx]); err == nil {
+				return arg[:idx], arg[idx+1:], true
+			}
+		}
+	}
+	return arg, "", false
+}
+
+// ResolveFileRef creates a reference to a file in the local repository.
+// The argument is a path, optionally followed by '@' and a revision (e.g., 'data/session14.nix@HEAD~2').
+// If no revision is given, the reference points to the last commit that changed the file.
+func ResolveFileRef(arg string) (FileRef, error) {
+	fn := fmt.Sprintf("ResolveFileRef(%s)", arg)
+	var ref FileRef
+	root, err := git.FindRepoRoot(".")
+	if err != nil {
+		return ref, ginerror{UError: err.Error(), Origin: fn, Description: "files can only be referenced from within a local copy of the repository"}
+	}
+	fpath, rev, unknownrev := splitFileRev(arg)
+	if unknownrev {
+		return ref, ginerror{UError: fmt.Sprintf("%s does not name a commit", rev), Origin: fn, Description: fmt.Sprintf("unknown revision '%s'", rev)}
+	}
+	abspath, err := filepath.Abs(fpath)
+	if err != nil {
+		return ref, ginerror{UError: err.Error(), Origin: fn, Description: fmt.Sprintf("invalid path '%s'", fpath)}
+	}
+	relpath, err := filepath.Rel(root, abspath)
+	if err != nil || relpath == ".." || strings.HasPrefix(relpath, ".."+string(filepath.Separator)) {
+		return ref, ginerror{UError: fmt.Sprintf("%s is outside %s", abspath, root), Origin: fn, Description: fmt.Sprintf("'%s' is not in the repository", fpath)}
+	}
+	ref.Path = filepath.ToSlash(relpath)
+
+	if rev != "" {
+		hash, err := git.RevParse(rev + "^{commit}")
+		if err != nil {
+			return ref, ginerror{UError: err.Error(), Origin: fn, Description: fmt.Sprintf("unknown revision '%s'", rev)}
+		}
+		ref.Commit = strings.TrimSpace(hash)
+		return ref, nil
+	}
+	hash, err := git.LastCommit(fpath)
+	if err != nil {
+		return ref, err
+	}
+	if hash == "" {
+		return ref, ginerror{UError: fmt.Sprintf("no commits for %s", fpath), Origin: fn, Description: fmt.Sprintf("'%s' has not been committed", fpath)}
+	}
+	ref.Commit = hash
+	return ref, nil
+}
+
+// FileRefsText returns a Markdown list of links to the referenced files of the repository at repopath (owner/name) on the web server.
+// The text is meant to be appended to the body of an issue or comment.
+func (gincl *Client) FileRefsText(repopath string, refs []FileRef) string {
+	if len(refs) == 0 {
+		return ""
+	}
+	lines := []string{"Files:"}
+	for _, ref := range refs {
+		var escaped []string
+		for _, part := range strings.Split(ref.Path, "/") {
+			escaped = append(escaped, url.PathEscape(part))
+		}
+		link := fmt.Sprintf("%s/%s/src/%s/%s", gincl.WebAddress(), repopath, ref.Commit, path.Join(escaped...))
+		shorthash := ref.Commit
+		if len(shorthash) > 7 {
+			shorthash = shorthash[:7]
+		}
+		lines = append(lines, fmt.Sprintf("- [%s](%s) (commit %s)", ref.Path, link, shorthash))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// issueStatusError returns the error for an unsuccessful response of an issue request.
+func issueStatusError(res *http.Response, fn, repopath string) error {
+	switch code := res.StatusCode; {
+	case code == http.StatusNotFound:
+		return ginerror{UError: res.Status, Origin: fn, Description: fmt.Sprintf("repository '%s' or issue does not exist, or the repository has no issue tracker", repopath)}
+	case code == http.StatusForbidden:
+		return ginerror{UError: res.Status, Origin: fn, Description: fmt.Sprintf("not allowed to modify issues of repository '%s'", repopath)}
+	case code == http.StatusUnprocessableEntity:
+		return ginerror{UError: res.Status, Origin: fn, Description: "invalid issue"}
+	case code == http.StatusUnauthorized:
+		return ginerror{UError: res.Status, Origin: fn, Description: "authorisation failed"}
+	case code == http.StatusInternalServerError:
+		return ginerror{UError: res.Status, Origin: fn, Description: "server error"}
+	}
+	return ginerror{UError: res.Status, Origin: fn} // Unexpected error
+}
+
+// readJSON reads the body of a response and decodes it into v.
+func readJSON(res *http.Response, fn string, v interface{}) error {
+	defer web.CloseRes(res.Body)
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return ginerror{UError: err.Error(), Origin: fn, Description: "failed to read response body"}
+	}
+	if err = json.Unmarshal(b, v); err != nil {
+		return ginerror{UError: err.Error(), Origin: fn, Description: "failed to parse response body"}
+	}
+	return nil
+}
+
+// ListIssues gets the open (or closed) issues of the repository at repopath (owner/name).
+// All pages of the list are retrieved.
+func (gincl *Client) ListIssues(repopath string, closed bool) ([]gogs.Issue, error) {
+	fn := fmt.Sprintf("ListIssues(%s)", repopath)
+	state := string(gogs.STATE_OPEN)
+	if closed {
+		state = string(gogs.STATE_CLOSED)
+	}
+	log.Write("Retrieving %s issues of %s", state, repopath)
+	var issues []gogs.Issue
+	handler := func(res *http.Response, b []byte) (int, error) {
+		if res.StatusCode != http.StatusOK {
+			return 0, issueStatusError(res, fn, repopath)
+		}
+		var page []gogs.Issue
+		if err := json.Unmarshal(b, &page); err != nil {
+			return 0, ginerror{UError: err.Error(), Origin: fn, Description: "failed to parse response body"}
+		}
+		issues = append(issues, page...)
+		return len(page), nil
+	}
+	if err := gincl.GetPages(gincl.ctx, fmt.Sprintf("/api/v1/repos/%s/issues?state=%s", repopath, state), handler); err != nil {
+		return nil, err
+	}
+	return issues, nil
+}
+
+// CreateIssue opens a new issue in the repository at repopath (owner/name) and returns it.
+func (gincl *Client) CreateIssue(repopath, title, body string) (gogs.Issue, error) {
+	fn := fmt.Sprintf("CreateIssue(%s)", repopath)
+	log.Write("Creating issue in %s: %s", repopath, title)
+	var issue gogs.Issue
+	if strings.TrimSpace(title) == "" {
+		return issue, ginerror{UError: "empty title", Origin: fn, Description: "an issue requires a title"}
+	}
+	res, err := gincl.Post(gincl.ctx, fmt.Sprintf("/api/v1/repos/%s/issues", repopath), gogs.CreateIssueOption{Title: title, Body: body})
+	if err != nil {
+		return issue, err // return error from Post() directly
+	}
+	if res.StatusCode != http.StatusCreated {
+		web.CloseRes(res.Body)
+		return issue, issueStatusError(res, fn, repopath)
+	}
+	err = readJSON(res, fn, &issue)
+	return issue, err
+}
+
+// CommentIssue adds a comment to the issue with the given number in the repository at repopath (owner/name).
+func (gincl *Client) CommentIssue(repopath string, number int64, body string) (gogs.Comment, error) {
+	fn := fmt.Sprintf("CommentIssue(%s, %d)", repopath, number)
+	log.Write("Commenting on issue %d of %s", number, repopath)
+	var comment gogs.Comment
+	if strings.TrimSpace(body) == "" {
+		return comment, ginerror{UError: "empty comment", Origin: fn, Description: "a comment requires a message"}
+	}
+	res, err := gincl.Post(gincl.ctx, fmt.Sprintf("/api/v1/repos/%s/issues/%d/comments", repopath, number), gogs.CreateIssueCommentOption{Body: body})
+	if err != nil {
+		return comment, err // return error from Post() directly
+	}
+	if res.StatusCode != http.StatusCreated {
+		web.CloseRes(res.Body)
+		return comment, issueStatusError(res, fn, repopath)
+	}
+	err = readJSON(res, fn, &comment)
+	return comment, err
+}
+
+// issueState is the request body for changing the state of an issue.
+type issueState struct {
+	State gogs.StateType `json:"state"`
+}
+
+// CloseIssue closes the issue with the given number in the repository at repopath (owner/name) and returns the updated issue.
+func (gincl *Client) CloseIssue(repopath string, number int64) (gogs.Issue, error) {
+	fn := fmt.Sprintf("CloseIssue(%s, %d)", repopath, number)
+	log.Write("Closing issue %d of %s", number, repopath)
+	var issue gogs.Issue
+	res, err := gincl.Patch(gincl.ctx, fmt.Sprintf("/api/v1/repos/%s/issues/%d", repopath, number), issueState{State: gogs.STATE_CLOSED})
+	if err != nil {
+		return issue, err // return error from Patch() directly
+	}
+	// Gogs responds to issue edits with 201 Created
+	if res.StatusCode != http.StatusCreated && res.StatusCode != http.StatusOK {
+		web.CloseRes(res.Body)
+		return issue, issueStatusError(res, fn, repopath)
+	}
+	err = readJSON(res, fn, &issue)
+	return issue, err
+}
@@ -0,0 +1,49 @@
+package ginclient
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+func TestSplitFileRev(t *testing.T) {
+	tmpdir := t.TempDir()
+	cwd, _ := os.Getwd()
+	os.Chdir(tmpdir)
+	defer os.Chdir(cwd)
+
+	ioutil.WriteFile("user@host.csv", []byte("a,b\n"), 0666)
+	ioutil.WriteFile("data.csv", []byte("a,b\n"), 0666)
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "."},
+		{"-c", "user.name=gin", "-c", "user.email=gin@example.com", "commit", "-q", "-m", "initial"},
+	} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v: %s", args, err, out)
+		}
+	}
+
+	tests := []struct {
+		arg        string
+		fpath      string
+		rev        string
+		unknownrev bool
+	}{
+		{"data.csv", "data.csv", "", false},
+		{"data.csv@HEAD", "data.csv", "HEAD", false},
+		{"data.csv@HEAD@{0}", "data.csv", "HEAD@{0}", false},
+		{"user@host.csv", "user@host.csv", "", false},
+		{"user@host.csv@HEAD", "user@host.csv", "HEAD", false},
+		{"data.csv@nosuchrev", "data.csv", "nosuchrev", true},
+		{"data.csv@-n", "data.csv", "-n", true},
+		{"missing@file.csv", "missing@file.csv", "", false},
+	}
+	for _, test := range tests {
+		fpath, rev, unknownrev := splitFileRev(test.arg)
+		if fpath != test.fpath || rev != test.rev || unknownrev != test.unknownrev {
+			t.Errorf("splitFileRev(%q) = (%q, %q, %t), expected (%q, %q, %t)", test.arg, fpath, rev, unknownrev, test.fpath, test.rev, test.unknownrev)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package ginclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/G-Node/gin-cli/ginclient/log"
+	"github.com/G-Node/gin-cli/git"
+)
+
+// Functions for recording mutating operations in a journal and undoing them.
+
+// maxJournalSize is the number of operations kept in the journal.
+const maxJournalSize = 50
+
+// Journaled operations
+const (
+	OpCommit        = "commit"
+	OpVersion       = "version"
+	OpLock          = "lock"
+	OpUnlock        = "unlock"
+	OpRemoveContent = "remove-content"
+)
+
+// Operation describes a mutating operation recorded in the journal.
+type Operation struct {
+	Command string    `json:"command"`
+	Time    time.Time `json:"time"`
+	// Head is the commit that was checked out before the operation.
+	Head string `json:"head,omitempty"`
+	// NewHead is the commit created by the operation (commit and version only).
+	NewHead string `json:"newhead,omitempty"`
+	// Files affected by the operation, relative to the root of the repository (lock, unlock, and remove-content only).
+	Files []string `json:"files,omitempty"`
+}
+
+func journalPath(reporoot string) string {
+	return filepath.Join(reporoot, ".git", "gin", "journal.json")
+}
+
+func readJournal(reporoot string) ([]Operation, error) {
+	data, err := ioutil.ReadFile(journalPath(reporoot))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var ops []Operation
+	err = json.Unmarshal(data, &ops)
+	return ops, err
+}
+
+func writeJournal(reporoot string, ops []Operation) error {
+	if len(ops) > maxJournalSize {
+		ops = ops[len(ops)-maxJournalSize:]
+	}
+	data, err := json.MarshalIndent(ops, "", "  ")
+	if err != nil {
+		return err
+	}
+	jpath := journalPath(reporoot)
+	if err = os.MkdirAll(filepath.Dir(jpath), 0777); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(jpath, data, 0666)
+}
+
+// CurrentHead returns the hash of the commit currently checked out or an empty string if there are no commits.
+func CurrentHead() string {
+	head, err := git.RevParse("HEAD")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(head)
+}
+
+// RecordOperation appends an operation to the journal of the repository.
+// File names are interpreted relative to the working directory.
+func RecordOperation(op Operation) error {
+	fn := fmt.Sprintf("RecordOperation(%s)", op.Command)
+	reporoot, err := git.FindRepoRoot(".")
+	if err != nil {
+		return ginerror{UError: err.Error(), Origin: fn}
+	}
+	workingdir, _ := os.Getwd()
+	for idx, fname := range op.Files {
+		abspath := fname
+		if !filepath.IsAbs(fname) {
+			abspath = filepath.Join(workingdir, fname)
+		}
+		if relpath, rerr := filepath.Rel(reporoot, abspath); rerr == nil {
+			op.Files[idx] = filepath.ToSlash(relpath)
+		}
+	}
+	if op.Time.IsZero() {
+		op.Time = time.Now()
+	}
+	ops, err := readJournal(reporoot)
+	if err != nil {
+		// a broken journal should not prevent the operation from being recorded
+		log.Write("Failed to read operation journal; starting new journal: %v", err)
+		ops = nil
+	}
+	ops = append(ops, op)
+	if err = writeJournal(reporoot, ops); err != nil {
+		return ginerror{UError: err.Error(), Origin: fn, Description: "failed to write operation journal"}
+	}
+	log.Write("Recorded operation %s in journal", op.Command)
+	return nil
+}
+
+// LastOperation returns the most recent operation recorded in the journal.
+// The second return value is false if the journal is empty.
+func LastOperation() (Operation, bool, error) {
+	reporoot, err := git.FindRepoRoot(".")
+	if err != nil {
+		return Operation{}, false, ginerror{UError: err.Error(), Origin: "LastOperation()"}
+	}
+	ops, err := readJournal(reporoot)
+	if err != nil {
+		return Operation{}, false, ginerror{UError: err.Error(), Origin: "LastOperation()", Description: "failed to read operation journal"}
+	}
+	if len(ops) == 0 {
+		return Operation{}, false, nil
+	}
+	return ops[len(ops)-1], true, nil
+}
+
+// DropLastOperation removes the most recent operation from the journal.
+func DropLastOperation() error {
+	reporoot, err := git.FindRepoRoot(".")
+	if err != nil {
+		return ginerror{UError: err.Error(), Origin: "DropLastOperation()"}
+	}
+	ops, err := readJournal(reporoot)
+	if err != nil || len(ops) == 0 {
+		return err
+	}
+	return writeJournal(reporoot, ops[:len(ops)-1])
+}
+
+// UndoPlan checks whether an operation can be safely undone and returns a
+// description of each step that will be performed to undo it.
+func UndoPlan(op Operation) ([]string, error) {
+	fn := fmt.Sprintf("UndoPlan(%s)", op.Command)
+	switch op.Command {
+	case OpCommit, OpVersion:
+		if op.Head == "" {
+			return nil, ginerror{Origin: fn, Description: "the first version of a repository cannot be undone"}
+		}
+		if CurrentHead() != op.NewHead {
+			return nil, ginerror{Origin: fn, Description: "new versions have been recorded or downloaded since the operation; it can no longer be undone safely"}
+		}
+		branches, err := git.RemoteBranchesContaining(op.NewHead)
+		if err != nil {
+			return nil, err
+		}
+		if len(branches) > 0 {
+			return nil, ginerror{Origin: fn, Description: fmt.Sprintf("version %s has already been uploaded (%s) and cannot be undone", shortHash(op.NewHead), strings.Join(branches, ", "))}
+		}
+		if op.Command == OpCommit {
+			return []string{
+				fmt.Sprintf("Remove recorded version %s and return to version %s", shortHash(op.NewHead), shortHash(op.Head)),
+				"Keep all file changes from the removed version in the local files (marked as not yet recorded)",
+			}, nil
+		}
+		return []string{
+			fmt.Sprintf("Remove recorded version %s and return to version %s", shortHash(op.NewHead), shortHash(op.Head)),
+			"Restore the files that were rolled back to their state before the 'version' command",
+		}, nil
+	case OpLock:
+		return []string{fmt.Sprintf("Unlock %d file(s): %s", len(op.Files), strings.Join(op.Files, ", "))}, nil
+	case OpUnlock:
+		return []string{fmt.Sprintf("Lock %d file(s): %s", len(op.Files), strings.Join(op.Files, ", "))}, nil
+	case OpRemoveContent:
+		return []string{fmt.Sprintf("Download the content of %d file(s) again: %s", len(op.Files), strings.Join(op.Files, ", "))}, nil
+	}
+	return nil, ginerror{Origin: fn, Description: fmt.Sprintf("operation '%s' cannot be undone", op.Command)}
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
+
+// Undo reverts the given operation. UndoPlan should be called first to check
+// that the operation can be undone safely.
+// Must be called from the root of the repository.
+// The status channel 'undochan' is closed when this function returns.
+func (gincl *Client) Undo(op Operation, undochan chan<- git.RepoFileStatus) {
+	switch op.Command {
+	case OpCommit, OpVersion:
+		defer close(undochan)
+		status := git.RepoFileStatus{FileName: shortHash(op.NewHead), State: "Reverting version"}
+		mode := "soft"
+		if op.Command == OpVersion {
+			// restore files in the working tree; refuses to overwrite uncommitted changes
+			mode = "keep"
+		}
+		status.Err = git.Reset(op.Head, mode)
+		if status.Err == nil {
+			status.Progress = "100%"
+		}
+		undochan <- status
+	case OpLock:
+		gincl.UnlockContent(op.Files, undochan)
+	case OpUnlock:
+		gincl.LockContent(op.Files, undochan)
+	case OpRemoveContent:
+		gincl.GetContent(op.Files, undochan)
+	default:
+		undochan <- git.RepoFileStatus{Err: ginerror{Origin: "Undo()", Description: fmt.Sprintf("operation '%s' cannot be undone", op.Command)}}
+		close(undochan)
+	}
+}
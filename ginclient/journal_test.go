@@ -0,0 +1,97 @@
+package ginclient
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestJournal(t *testing.T) {
+	reporoot := t.TempDir()
+	os.Mkdir(filepath.Join(reporoot, ".git"), 0777)
+	subdir := filepath.Join(reporoot, "data")
+	os.Mkdir(subdir, 0777)
+	cwd, _ := os.Getwd()
+	os.Chdir(subdir)
+	defer os.Chdir(cwd)
+
+	if _, ok, err := LastOperation(); ok || err != nil {
+		t.Fatalf("Expected empty journal (ok: %v, err: %v)", ok, err)
+	}
+
+	// file names are stored relative to the repository root
+	if err := RecordOperation(Operation{Command: OpLock, Files: []string{"a.dat", filepath.Join(subdir, "b.dat"), "../c.dat"}}); err != nil {
+		t.Fatalf("Failed to record operation: %v", err)
+	}
+	op, ok, err := LastOperation()
+	if !ok || err != nil {
+		t.Fatalf("Recorded operation not found (ok: %v, err: %v)", ok, err)
+	}
+	if op.Command != OpLock || op.Time.IsZero() || !reflect.DeepEqual(op.Files, []string{"data/a.dat", "data/b.dat", "c.dat"}) {
+		t.Fatalf("Unexpected recorded operation: %+v", op)
+	}
+
+	RecordOperation(Operation{Command: OpCommit, Head: "1111111", NewHead: "2222222"})
+	if op, _, _ = LastOperation(); op.Command != OpCommit {
+		t.Fatalf("Last operation is %q, expected %q", op.Command, OpCommit)
+	}
+	if err := DropLastOperation(); err != nil {
+		t.Fatalf("Failed to drop last operation: %v", err)
+	}
+	if op, _, _ = LastOperation(); op.Command != OpLock {
+		t.Fatalf("Last operation after drop is %q, expected %q", op.Command, OpLock)
+	}
+
+	// only the most recent operations are kept
+	for idx := 0; idx < maxJournalSize+5; idx++ {
+		RecordOperation(Operation{Command: OpUnlock})
+	}
+	ops, err := readJournal(reporoot)
+	if err != nil || len(ops) != maxJournalSize {
+		t.Fatalf("Journal has %d operations (expected %d): %v", len(ops), maxJournalSize, err)
+	}
+
+	// a broken journal is replaced when the next operation is recorded
+	ioutil.WriteFile(journalPath(reporoot), []byte("{broken"), 0666)
+	if _, _, err := LastOperation(); err == nil {
+		t.Fatal("Expected error when reading broken journal")
+	}
+	if err := RecordOperation(Operation{Command: OpRemoveContent, Files: []string{"a.dat"}}); err != nil {
+		t.Fatalf("Failed to record operation after broken journal: %v", err)
+	}
+	if ops, err := readJournal(reporoot); err != nil || len(ops) != 1 {
+		t.Fatalf("Unexpected journal after recovery: %+v (%v)", ops, err)
+	}
+}
+
+func TestUndoPlan(t *testing.T) {
+	files := []string{"a.dat", "data/b.dat"}
+	tests := []struct {
+		op    Operation
+		steps []string
+	}{
+		{Operation{Command: OpLock, Files: files}, []string{"Unlock 2 file(s): a.dat, data/b.dat"}},
+		{Operation{Command: OpUnlock, Files: files}, []string{"Lock 2 file(s): a.dat, data/b.dat"}},
+		{Operation{Command: OpRemoveContent, Files: files}, []string{"Download the content of 2 file(s) again: a.dat, data/b.dat"}},
+		// errors
+		{Operation{Command: OpCommit, NewHead: "2222222222"}, nil},
+		{Operation{Command: OpVersion, NewHead: "2222222222"}, nil},
+		{Operation{Command: "upload"}, nil},
+	}
+	for _, test := range tests {
+		steps, err := UndoPlan(test.op)
+		if (err != nil) != (test.steps == nil) || !reflect.DeepEqual(steps, test.steps) {
+			t.Errorf("Unexpected plan for %+v: %q (%v)", test.op, steps, err)
+		}
+	}
+}
+
+func TestShortHash(t *testing.T) {
+	for hash, expected := range map[string]string{"0123456789abcdef": "0123456", "0123456": "0123456", "abc": "abc", "": ""} {
+		if short := shortHash(hash); short != expected {
+			t.Errorf("shortHash(%q) = %q; expected %q", hash, short, expected)
+		}
+	}
+}
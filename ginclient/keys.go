@@ -0,0 +1,132 @@
+package ginclient
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/G-Node/gin-cli/ginclient/config"
+	"github.com/G-Node/gin-cli/ginclient/credentials"
+	"github.com/G-Node/gin-cli/ginclient/log"
+	"github.com/G-Node/gin-cli/git"
+	gogs "github.com/gogits/go-gogs-client"
+)
+
+// Functions for managing the session key created on login.
+
+// sessionKeyTitle returns the title of the session key for the current user and machine.
+func (gincl *Client) sessionKeyTitle() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		log.Write("Could not retrieve hostname")
+		hostname = unknownhostname
+	}
+	return fmt.Sprintf("GIN Client: %s@%s", gincl.Username, hostname)
+}
+
+// sameKey compares two public keys in authorized_keys format, ignoring comments.
+func sameKey(a, b string) bool {
+	af := strings.Fields(a)
+	bf := strings.Fields(b)
+	return len(af) >= 2 && len(bf) >= 2 && af[0] == bf[0] && af[1] == bf[1]
+}
+
+// findSessionKey returns the public key on the server that belongs to the
+// local session key. If the local key cannot be read, the key is identified
+// by its title.
+func (gincl *Client) findSessionKey() (gogs.PublicKey, error) {
+	keys, err := gincl.GetUserKeys()
+	if err != nil {
+		return gogs.PublicKey{}, err
+	}
+	privkey, err := credentials.Load(git.KeyName(gincl.srvalias))
+	if err == nil {
+		pubkey, perr := git.PublicKeyString(privkey)
+		if perr == nil {
+			for _, key := range keys {
+				if sameKey(key.Key, pubkey) {
+					return key, nil
+				}
+			}
+		} else {
+			log.Write("Failed to read public key of session key: %v", perr)
+		}
+	}
+	title := gincl.sessionKeyTitle()
+	for _, key := range keys {
+		if key.Title == title {
+			return key, nil
+		}
+	}
+	return gogs.PublicKey{}, ginerror{Origin: "findSessionKey()", Description: "the session key was not found on the server"}
+}
+
+// KeyRotation reports the result of a session key rotation.
+type KeyRotation struct {
+	// NewKey is the title of the new key on the server.
+	NewKey string `json:"newkey"`
+	// OldKey is the title of the replaced key. It is empty if the old key was not found on the server.
+	OldKey string `json:"oldkey"`
+}
+
+// RotateSessionKey replaces the session key with a newly generated one.
+// The new public key is added to the server and SSH access with the new key is
+// verified before it replaces the local key and the old key is deleted from
+// the server. If any step before replacing the local key fails, the new key is
+// removed from the server and the old key remains in use.
+// Servers that use HTTPS for git operations have no session key and rotation is refused.
+func (gincl *Client) RotateSessionKey() (KeyRotation, error) {
+	fn := "RotateSessionKey()"
+	var result KeyRotation
+	srvcfg, ok := config.Read().Servers[gincl.srvalias]
+	if !ok {
+		return result, ginerror{Origin: fn, Description: fmt.Sprintf("unknown server alias '%s'", gincl.srvalias)}
+	}
+	if srvcfg.Git.UseHTTPS() {
+		// same as setUpGitAccess: git operations authenticate with the token and there is no session key
+		return result, ginerror{Origin: fn, Description: fmt.Sprintf("server '%s' uses HTTPS for git operations and has no session key to rotate", gincl.srvalias)}
+	}
+
+	oldkey, err := gincl.findSessionKey()
+	if err != nil {
+		// the old key may have been deleted from the server; rotation replaces the local key regardless
+		log.Write("Old session key not found: %v", err)
+	}
+
+	keyPair, err := git.MakeKeyPair(config.Read().SSH.KeyType)
+	if err != nil {
+		return result, ginerror{UError: err.Error(), Origin: fn, Description: "failed to generate new key"}
+	}
+	// key titles must be unique: the old key still exists while the new one is tested
+	result.NewKey = fmt.Sprintf("%s (%s)", gincl.sessionKeyTitle(), time.Now().Format("2006-01-02 15:04:05"))
+	pubkey := fmt.Sprintf("%s %s", strings.TrimSpace(keyPair.Public), result.NewKey)
+	if err = gincl.AddKey(pubkey, result.NewKey, false); err != nil {
+		return result, err
+	}
+
+	rollback := func(cause error) (KeyRotation, error) {
+		log.Write("Key rotation failed; removing new key from server: %v", cause)
+		if derr := gincl.DeletePubKeyByTitle(result.NewKey); derr != nil {
+			log.Write("Failed to remove new key: %v", derr)
+			return result, ginerror{UError: cause.Error(), Origin: fn, Description: fmt.Sprintf("%s; the new key '%s' could not be removed from the server and should be deleted manually", cause.Error(), result.NewKey)}
+		}
+		return result, cause
+	}
+
+	if err = git.CheckSSHAccess(srvcfg.Git, []byte(keyPair.Private)); err != nil {
+		return rollback(err)
+	}
+	if err = credentials.Save(git.KeyName(gincl.srvalias), []byte(keyPair.Private)); err != nil {
+		return rollback(err)
+	}
+	log.Write("New session key stored")
+
+	if oldkey.ID != 0 {
+		if err = gincl.DeletePubKey(oldkey.ID); err != nil {
+			return result, ginerror{UError: err.Error(), Origin: fn, Description: fmt.Sprintf("the new key is in use but the old key '%s' could not be deleted from the server: %s", oldkey.Title, err.Error())}
+		}
+		result.OldKey = oldkey.Title
+	}
+	return result, nil
+}
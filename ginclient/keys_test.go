@@ -0,0 +1,25 @@
+package ginclient
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/G-Node/gin-cli/ginclient/config"
+)
+
+func TestRotateSessionKeyHTTPS(t *testing.T) {
+	srvcfg := config.ServerCfg{
+		Web: config.WebCfg{Protocol: "https", Host: "gin.example.org", Port: 443},
+		Git: config.GitCfg{Protocol: config.GitProtocolHTTPS},
+	}
+	if err := config.AddServerConf("httpsgit", srvcfg); err != nil {
+		t.Fatalf("Failed to add server: %v", err)
+	}
+	defer config.RmServerConf("httpsgit")
+
+	// refused before any request is made to the (nonexistent) server
+	gincl := New("httpsgit")
+	if _, err := gincl.RotateSessionKey(); err == nil || !strings.Contains(err.Error(), "uses HTTPS for git operations") {
+		t.Fatalf("Expected key rotation to be refused for HTTPS server, got %v", err)
+	}
+}
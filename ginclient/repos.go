@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
 	"os/user"
 	"path"
@@ -13,6 +14,7 @@ import (
 	"strings"
 
 	"github.com/G-Node/gin-cli/ginclient/config"
+	"github.com/G-Node/gin-cli/ginclient/credentials"
 	"github.com/G-Node/gin-cli/ginclient/log"
 	"github.com/G-Node/gin-cli/git"
 	"github.com/G-Node/gin-cli/web"
@@ -86,30 +88,28 @@ func isAnnexPath(path string) bool {
 // The private key is saved in the user's configuration directory, to be used for git commands.
 // The public key is added to the GIN server for the current logged in user.
 func (gincl *Client) MakeSessionKey() error {
-	keyPair, err := git.MakeKeyPair()
+	keyPair, err := git.MakeKeyPair(config.Read().SSH.KeyType)
 	if err != nil {
 		return err
 	}
 
-	hostname, err := os.Hostname()
-	if err != nil {
-		log.Write("Could not retrieve hostname")
-		hostname = unknownhostname
+	// remove the previous key of this client from the server (it may have been renamed by a key rotation)
+	if oldkey, ferr := gincl.findSessionKey(); ferr == nil {
+		_ = gincl.DeletePubKey(oldkey.ID)
 	}
-	description := fmt.Sprintf("GIN Client: %s@%s", gincl.Username, hostname)
+
+	description := gincl.sessionKeyTitle()
 	pubkey := fmt.Sprintf("%s %s", strings.TrimSpace(keyPair.Public), description)
 	err = gincl.AddKey(pubkey, description, true)
 	if err != nil {
 		return err
 	}
 
-	configpath, err := config.Path(true)
+	err = credentials.Save(git.KeyName(gincl.srvalias), []byte(keyPair.Private))
 	if err != nil {
-		log.Write("Could not create config directory for private key")
+		log.Write("Could not store private key")
 		return err
 	}
-	keyfilepath := filepath.Join(configpath, fmt.Sprintf("%s.key", gincl.srvalias))
-	ioutil.WriteFile(keyfilepath, []byte(keyPair.Private), 0600)
 
 	return nil
 }
@@ -120,7 +120,7 @@ func (gincl *Client) GetRepo(repoPath string) (gogs.Repository, error) {
 	log.Write("GetRepo")
 	var repo gogs.Repository
 
-	res, err := gincl.Get(fmt.Sprintf("/api/v1/repos/%s", repoPath))
+	res, err := gincl.Get(gincl.ctx, fmt.Sprintf("/api/v1/repos/%s", repoPath))
 	if err != nil {
 		return repo, err // return error from Get() directly
 	}
@@ -147,34 +147,211 @@ func (gincl *Client) GetRepo(repoPath string) (gogs.Repository, error) {
 }
 
 // ListRepos gets a list of repositories (public or user specific)
+// All pages of the list are retrieved.
 func (gincl *Client) ListRepos(user string) ([]gogs.Repository, error) {
 	fn := fmt.Sprintf("ListRepos(%s)", user)
 	log.Write("Retrieving repo list")
 	var repoList []gogs.Repository
-	var res *http.Response
-	var err error
-	res, err = gincl.Get(fmt.Sprintf("/api/v1/users/%s/repos", user))
+	handler := func(res *http.Response, b []byte) (int, error) {
+		switch code := res.StatusCode; {
+		case code == http.StatusNotFound:
+			return 0, ginerror{UError: res.Status, Origin: fn, Description: fmt.Sprintf("user '%s' does not exist", user)}
+		case code == http.StatusUnauthorized:
+			return 0, ginerror{UError: res.Status, Origin: fn, Description: "authorisation failed"}
+		case code == http.StatusInternalServerError:
+			return 0, ginerror{UError: res.Status, Origin: fn, Description: "server error"}
+		case code != http.StatusOK:
+			return 0, ginerror{UError: res.Status, Origin: fn} // Unexpected error
+		}
+		var page []gogs.Repository
+		if err := json.Unmarshal(b, &page); err != nil {
+			return 0, ginerror{UError: err.Error(), Origin: fn, Description: "failed to parse response body"}
+		}
+		repoList = append(repoList, page...)
+		return len(page), nil
+	}
+	if err := gincl.GetPages(gincl.ctx, fmt.Sprintf("/api/v1/users/%s/repos", user), handler); err != nil {
+		return nil, err
+	}
+	return repoList, nil
+}
+
+// SearchOptions holds the filters for a repository search.
+type SearchOptions struct {
+	// Owner limits the results to repositories owned by the user (or organisation) with the given username.
+	Owner string
+	// Private and Public limit the results to private or public repositories respectively.
+	Private bool
+	Public  bool
+}
+
+// searchResults is the response of the repository search API.
+type searchResults struct {
+	OK    bool              `json:"ok"`
+	Data  []gogs.Repository `json:"data"`
+	Error string            `json:"error"`
+}
+
+// SearchRepos searches the server for repositories whose name matches the query and returns the results that match the options.
+// Only repositories that the logged in user can read are found.
+// All pages of the results are retrieved.
+func (gincl *Client) SearchRepos(query string, opts SearchOptions) ([]gogs.Repository, error) {
+	fn := fmt.Sprintf("SearchRepos(%s)", query)
+	log.Write("Searching repositories: %q %+v", query, opts)
+	params := url.Values{}
+	params.Set("q", query)
+	if opts.Owner != "" {
+		owner, err := gincl.RequestAccount(opts.Owner)
+		if err != nil {
+			return nil, err
+		}
+		params.Set("uid", fmt.Sprintf("%d", owner.ID))
+	}
+	var repoList []gogs.Repository
+	handler := func(res *http.Response, b []byte) (int, error) {
+		var results searchResults
+		jsonerr := json.Unmarshal(b, &results)
+		switch code := res.StatusCode; {
+		case code == http.StatusUnauthorized:
+			return 0, ginerror{UError: res.Status, Origin: fn, Description: "authorisation failed"}
+		case code == http.StatusUnprocessableEntity || code == http.StatusInternalServerError:
+			if jsonerr == nil && results.Error != "" {
+				return 0, ginerror{UError: res.Status, Origin: fn, Description: fmt.Sprintf("search failed: %s", results.Error)}
+			}
+			return 0, ginerror{UError: res.Status, Origin: fn, Description: "server error"}
+		case code != http.StatusOK:
+			return 0, ginerror{UError: res.Status, Origin: fn} // Unexpected error
+		}
+		if jsonerr != nil {
+			return 0, ginerror{UError: jsonerr.Error(), Origin: fn, Description: "failed to parse response body"}
+		}
+		for _, repo := range results.Data {
+			if (opts.Private && !repo.Private) || (opts.Public && repo.Private) {
+				continue
+			}
+			repoList = append(repoList, repo)
+		}
+		return len(results.Data), nil
+	}
+	if err := gincl.GetPages(gincl.ctx, "/api/v1/repos/search?"+params.Encode(), handler); err != nil {
+		return nil, err
+	}
+	return repoList, nil
+}
+
+// forkRepoOption is the request body for forking a repository.
+type forkRepoOption struct {
+	Organization string `json:"organization,omitempty"`
+}
+
+// ForkRepo creates a fork of the repository at repopath (owner/name) owned by the logged in user, or by the organisation org if it is not empty.
+// It returns the information of the new repository.
+// An error is returned if the server does not provide the API for creating forks (POST /repos/:owner/:repo/forks).
+func (gincl *Client) ForkRepo(repopath, org string) (gogs.Repository, error) {
+	fn := fmt.Sprintf("ForkRepo(%s, %s)", repopath, org)
+	log.Write("Forking repository %s (organisation %q)", repopath, org)
+	var fork gogs.Repository
+	res, err := gincl.Post(gincl.ctx, fmt.Sprintf("/api/v1/repos/%s/forks", repopath), forkRepoOption{Organization: org})
 	if err != nil {
-		return nil, err // return error from Get() directly
+		return fork, err // return error from Post() directly
 	}
+	defer web.CloseRes(res.Body)
 	switch code := res.StatusCode; {
-	case code == http.StatusNotFound:
-		return nil, ginerror{UError: res.Status, Origin: fn, Description: fmt.Sprintf("user '%s' does not exist", user)}
+	case code == http.StatusNotFound || code == http.StatusMethodNotAllowed:
+		// servers without support for creating forks (e.g., Gogs) only provide listing forks
+		if _, rerr := gincl.GetRepo(repopath); rerr != nil {
+			return fork, rerr
+		}
+		return fork, ginerror{UError: res.Status, Origin: fn, Description: "the server does not support forking repositories"}
+	case code == http.StatusConflict || code == http.StatusUnprocessableEntity:
+		return fork, ginerror{UError: res.Status, Origin: fn, Description: "a repository with the same name already exists or the repository has already been forked"}
+	case code == http.StatusForbidden:
+		if org != "" {
+			return fork, ginerror{UError: res.Status, Origin: fn, Description: fmt.Sprintf("not allowed to create repositories in organisation '%s'", org)}
+		}
+		return fork, ginerror{UError: res.Status, Origin: fn, Description: "not allowed to fork the repository"}
 	case code == http.StatusUnauthorized:
-		return nil, ginerror{UError: res.Status, Origin: fn, Description: "authorisation failed"}
+		return fork, ginerror{UError: res.Status, Origin: fn, Description: "authorisation failed"}
 	case code == http.StatusInternalServerError:
-		return nil, ginerror{UError: res.Status, Origin: fn, Description: "server error"}
-	case code != http.StatusOK:
-		return nil, ginerror{UError: res.Status, Origin: fn} // Unexpected error
+		return fork, ginerror{UError: res.Status, Origin: fn, Description: "server error"}
+	case code != http.StatusOK && code != http.StatusCreated && code != http.StatusAccepted:
+		return fork, ginerror{UError: res.Status, Origin: fn} // Unexpected error
 	}
-	defer web.CloseRes(res.Body)
 	b, err := ioutil.ReadAll(res.Body)
 	if err != nil {
-		return nil, ginerror{UError: err.Error(), Origin: fn, Description: "failed to read response body"}
+		return fork, ginerror{UError: err.Error(), Origin: fn, Description: "failed to read response body"}
+	}
+	if err = json.Unmarshal(b, &fork); err != nil {
+		return fork, ginerror{UError: err.Error(), Origin: fn, Description: "failed to parse response body"}
+	}
+	log.Write("Repository forked to %s", fork.FullName)
+	return fork, nil
+}
+
+// starRequest sends a request that stars (PUT) or unstars (DELETE) the repository at repopath.
+func (gincl *Client) starRequest(method, repopath string) error {
+	fn := fmt.Sprintf("%s star(%s)", method, repopath)
+	address := fmt.Sprintf("/api/v1/user/starred/%s", repopath)
+	var res *http.Response
+	var err error
+	if method == http.MethodPut {
+		res, err = gincl.Put(gincl.ctx, address, nil)
+	} else {
+		res, err = gincl.Delete(gincl.ctx, address)
 	}
-	err = json.Unmarshal(b, &repoList)
 	if err != nil {
-		return nil, ginerror{UError: err.Error(), Origin: fn, Description: "failed to parse response body"}
+		return err // return error from Put() or Delete() directly
+	}
+	defer web.CloseRes(res.Body)
+	switch code := res.StatusCode; {
+	case code == http.StatusNotFound:
+		return ginerror{UError: res.Status, Origin: fn, Description: fmt.Sprintf("repository '%s' does not exist", repopath)}
+	case code == http.StatusUnauthorized:
+		return ginerror{UError: res.Status, Origin: fn, Description: "authorisation failed"}
+	case code == http.StatusInternalServerError:
+		return ginerror{UError: res.Status, Origin: fn, Description: "server error"}
+	case code != http.StatusNoContent && code != http.StatusOK:
+		return ginerror{UError: res.Status, Origin: fn} // Unexpected error
+	}
+	return nil
+}
+
+// StarRepo adds the repository at repopath (owner/name) to the starred repositories of the logged in user.
+func (gincl *Client) StarRepo(repopath string) error {
+	log.Write("Starring repository %s", repopath)
+	return gincl.starRequest(http.MethodPut, repopath)
+}
+
+// UnstarRepo removes the repository at repopath (owner/name) from the starred repositories of the logged in user.
+func (gincl *Client) UnstarRepo(repopath string) error {
+	log.Write("Unstarring repository %s", repopath)
+	return gincl.starRequest(http.MethodDelete, repopath)
+}
+
+// ListStarred gets the list of repositories starred by the logged in user.
+// All pages of the list are retrieved.
+func (gincl *Client) ListStarred() ([]gogs.Repository, error) {
+	fn := "ListStarred()"
+	log.Write("Retrieving starred repositories")
+	var repoList []gogs.Repository
+	handler := func(res *http.Response, b []byte) (int, error) {
+		switch code := res.StatusCode; {
+		case code == http.StatusUnauthorized:
+			return 0, ginerror{UError: res.Status, Origin: fn, Description: "authorisation failed"}
+		case code == http.StatusInternalServerError:
+			return 0, ginerror{UError: res.Status, Origin: fn, Description: "server error"}
+		case code != http.StatusOK:
+			return 0, ginerror{UError: res.Status, Origin: fn} // Unexpected error
+		}
+		var page []gogs.Repository
+		if err := json.Unmarshal(b, &page); err != nil {
+			return 0, ginerror{UError: err.Error(), Origin: fn, Description: "failed to parse response body"}
+		}
+		repoList = append(repoList, page...)
+		return len(page), nil
+	}
+	if err := gincl.GetPages(gincl.ctx, "/api/v1/user/starred", handler); err != nil {
+		return nil, err
 	}
 	return repoList, nil
 }
@@ -185,7 +362,7 @@ func (gincl *Client) CreateRepo(name, description string) error {
 	log.Write("Creating repository")
 	newrepo := gogs.CreateRepoOption{Name: name, Description: description, Private: true}
 	log.Write("Name: %s :: Description: %s", name, description)
-	res, err := gincl.Post("/api/v1/user/repos", newrepo)
+	res, err := gincl.Post(gincl.ctx, "/api/v1/user/repos", newrepo)
 	if err != nil {
 		return err // return error from Post() directly
 	}
@@ -208,7 +385,7 @@ func (gincl *Client) CreateRepo(name, description string) error {
 func (gincl *Client) DelRepo(name string) error {
 	fn := fmt.Sprintf("DelRepo(%s)", name)
 	log.Write("Deleting repository")
-	res, err := gincl.Delete(fmt.Sprintf("/api/v1/repos/%s", name))
+	res, err := gincl.Delete(gincl.ctx, fmt.Sprintf("/api/v1/repos/%s", name))
 	if err != nil {
 		return err // return error from Post() directly
 	}
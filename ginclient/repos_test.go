@@ -0,0 +1,203 @@
+package ginclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/G-Node/gin-cli/web"
+	gogs "github.com/gogits/go-gogs-client"
+)
+
+// fakeRepoServer serves the repository and star routes of the Gogs API for one user:
+//
+//	GET /repos/:owner/:repo
+//	GET /user/starred
+//	GET, PUT, DELETE /user/starred/:owner/:repo
+//
+// Gogs can only list forks. If forking is set, creating forks (POST /repos/:owner/:repo/forks)
+// is also supported, as on servers that provide it; otherwise the server responds with 404 like Gogs.
+type fakeRepoServer struct {
+	sync.Mutex
+	username string
+	token    string
+	forking  bool
+	repos    map[string]gogs.Repository
+	starred  map[string]bool
+}
+
+func (srv *fakeRepoServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	srv.Lock()
+	defer srv.Unlock()
+	if r.Header.Get("Authorization") != "token "+srv.token {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/api/v1")
+	switch {
+	case path == "/user/starred" && r.Method == http.MethodGet:
+		repos := []gogs.Repository{}
+		if page := r.URL.Query().Get("page"); page == "" || page == "1" {
+			for fullname := range srv.starred {
+				repos = append(repos, srv.repos[fullname])
+			}
+		}
+		json.NewEncoder(w).Encode(repos)
+	case strings.HasPrefix(path, "/user/starred/"):
+		fullname := strings.TrimPrefix(path, "/user/starred/")
+		if _, ok := srv.repos[fullname]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			if !srv.starred[fullname] {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+		case http.MethodPut:
+			srv.starred[fullname] = true
+		case http.MethodDelete:
+			delete(srv.starred, fullname)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case strings.HasPrefix(path, "/repos/") && strings.HasSuffix(path, "/forks") && r.Method == http.MethodPost:
+		fullname := strings.TrimSuffix(strings.TrimPrefix(path, "/repos/"), "/forks")
+		source, ok := srv.repos[fullname]
+		if !ok || !srv.forking {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var opt forkRepoOption
+		json.NewDecoder(r.Body).Decode(&opt)
+		owner := srv.username
+		if opt.Organization != "" {
+			if opt.Organization != "lab" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			owner = opt.Organization
+		}
+		fork := gogs.Repository{Name: source.Name, FullName: owner + "/" + source.Name, Owner: &gogs.User{UserName: owner}, Fork: true, Parent: &source}
+		if _, exists := srv.repos[fork.FullName]; exists {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		}
+		srv.repos[fork.FullName] = fork
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(fork)
+	case strings.HasPrefix(path, "/repos/") && r.Method == http.MethodGet:
+		repo, ok := srv.repos[strings.TrimPrefix(path, "/repos/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(repo)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newFakeRepoServer() *fakeRepoServer {
+	return &fakeRepoServer{
+		username: "alice",
+		token:    "repotoken",
+		repos: map[string]gogs.Repository{
+			"peter/eegdata": {Name: "eegdata", FullName: "peter/eegdata", Owner: &gogs.User{UserName: "peter"}},
+			"peter/ephys":   {Name: "ephys", FullName: "peter/ephys", Owner: &gogs.User{UserName: "peter"}},
+		},
+		starred: make(map[string]bool),
+	}
+}
+
+func TestForkRepo(t *testing.T) {
+	fake := newFakeRepoServer()
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	gincl := &Client{Client: web.New(srv.URL), ctx: context.Background()}
+	gincl.Token = fake.token
+
+	// like Gogs, the server does not support creating forks
+	_, err := gincl.ForkRepo("peter/eegdata", "")
+	if err == nil || !strings.Contains(err.Error(), "does not support forking") {
+		t.Fatalf("Expected unsupported error, got %v", err)
+	}
+	if _, err = gincl.ForkRepo("peter/missing", ""); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("Expected error for missing repository, got %v", err)
+	}
+
+	fake.forking = true
+	fork, err := gincl.ForkRepo("peter/eegdata", "")
+	if err != nil {
+		t.Fatalf("Failed to fork repository: %v", err)
+	}
+	if fork.FullName != "alice/eegdata" || !fork.Fork || fork.Parent == nil || fork.Parent.FullName != "peter/eegdata" {
+		t.Fatalf("Unexpected fork: %+v", fork)
+	}
+	if _, err = gincl.ForkRepo("peter/eegdata", ""); err == nil || !strings.Contains(err.Error(), "already") {
+		t.Fatalf("Expected error for existing fork, got %v", err)
+	}
+	if fork, err = gincl.ForkRepo("peter/eegdata", "lab"); err != nil || fork.FullName != "lab/eegdata" {
+		t.Fatalf("Failed to fork repository into organisation: %+v (%v)", fork, err)
+	}
+	if _, err = gincl.ForkRepo("peter/eegdata", "other"); err == nil || !strings.Contains(err.Error(), "organisation 'other'") {
+		t.Fatalf("Expected permission error for organisation, got %v", err)
+	}
+	if _, err = gincl.ForkRepo("peter/missing", ""); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("Expected error for missing repository, got %v", err)
+	}
+
+	gincl.Token = "invalid"
+	if _, err = gincl.ForkRepo("peter/ephys", ""); err == nil || !strings.Contains(err.Error(), "authorisation failed") {
+		t.Fatalf("Expected authorisation error, got %v", err)
+	}
+}
+
+func TestStarRepo(t *testing.T) {
+	fake := newFakeRepoServer()
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	gincl := &Client{Client: web.New(srv.URL), ctx: context.Background()}
+	gincl.Token = fake.token
+
+	starred, err := gincl.ListStarred()
+	if err != nil || len(starred) != 0 {
+		t.Fatalf("Expected no starred repositories, got %v (%v)", starred, err)
+	}
+	if err = gincl.StarRepo("peter/eegdata"); err != nil {
+		t.Fatalf("Failed to star repository: %v", err)
+	}
+	// starring is idempotent
+	if err = gincl.StarRepo("peter/eegdata"); err != nil {
+		t.Fatalf("Failed to star repository again: %v", err)
+	}
+	if err = gincl.StarRepo("peter/missing"); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("Expected error for missing repository, got %v", err)
+	}
+	starred, err = gincl.ListStarred()
+	if err != nil || len(starred) != 1 || starred[0].FullName != "peter/eegdata" {
+		t.Fatalf("Expected peter/eegdata to be starred, got %v (%v)", starred, err)
+	}
+
+	if err = gincl.UnstarRepo("peter/eegdata"); err != nil {
+		t.Fatalf("Failed to unstar repository: %v", err)
+	}
+	if err = gincl.UnstarRepo("peter/missing"); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("Expected error for missing repository, got %v", err)
+	}
+	if starred, err = gincl.ListStarred(); err != nil || len(starred) != 0 {
+		t.Fatalf("Expected no starred repositories after unstarring, got %v (%v)", starred, err)
+	}
+
+	gincl.Token = "invalid"
+	if _, err = gincl.ListStarred(); err == nil || !strings.Contains(err.Error(), "authorisation failed") {
+		t.Fatalf("Expected authorisation error, got %v", err)
+	}
+	if err = gincl.StarRepo("peter/eegdata"); err == nil || !strings.Contains(err.Error(), "authorisation failed") {
+		t.Fatalf("Expected authorisation error, got %v", err)
+	}
+}
@@ -0,0 +1,358 @@
+package ginclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/G-Node/gin-cli/ginclient/log"
+	"github.com/G-Node/gin-cli/git"
+)
+
+// Functions for setting aside (shelving) uncommitted changes and restoring them later.
+// Shelved files are moved (not copied) into a directory inside the .git
+// directory of the repository, so the content of large (annexed) files is
+// preserved without being duplicated or added to the annex.
+
+const shelfManifest = "manifest.json"
+
+// States of shelved files
+const (
+	ShelvedModified  = "modified"
+	ShelvedAdded     = "added"
+	ShelvedDeleted   = "deleted"
+	ShelvedUntracked = "untracked"
+)
+
+// ShelvedFile describes a file that was set aside in a shelf.
+type ShelvedFile struct {
+	// Name of the file relative to the root of the repository.
+	Name string `json:"name"`
+	// State of the file when it was shelved.
+	State string `json:"state"`
+	// BaseHash is the hash of the committed version of the file at the time
+	// it was shelved. It is empty if the file did not exist in the last commit.
+	BaseHash string `json:"basehash,omitempty"`
+}
+
+// Shelf holds the information of a set of shelved changes.
+type Shelf struct {
+	ID      string        `json:"id"`
+	Created time.Time     `json:"created"`
+	Head    string        `json:"head"`
+	Files   []ShelvedFile `json:"files"`
+}
+
+// UnshelvedFile reports the result of restoring a single shelved file.
+type UnshelvedFile struct {
+	ShelvedFile
+	// Destination is the location the file was restored to. It differs from
+	// the original name when the file was changed since it was shelved.
+	Destination string `json:"destination"`
+	Conflict    bool   `json:"conflict"`
+	Err         error  `json:"-"`
+}
+
+// MarshalJSON overrides the default marshalling of UnshelvedFile to return the error string for the Err field.
+func (uf UnshelvedFile) MarshalJSON() ([]byte, error) {
+	type UFAlias UnshelvedFile
+	errmsg := ""
+	if uf.Err != nil {
+		errmsg = uf.Err.Error()
+	}
+	return json.Marshal(struct {
+		Err string `json:"err"`
+		UFAlias
+	}{
+		Err:     errmsg,
+		UFAlias: UFAlias(uf),
+	})
+}
+
+func shelvesDir() string {
+	return filepath.Join(".git", "gin", "shelves")
+}
+
+func (s Shelf) dir() string {
+	return filepath.Join(shelvesDir(), s.ID)
+}
+
+func (s Shelf) filePath(fname string) string {
+	return filepath.Join(s.dir(), "files", filepath.FromSlash(fname))
+}
+
+func (s Shelf) writeManifest() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(s.dir(), shelfManifest), data, 0666)
+}
+
+// headHash returns the hash of the object at the given path in HEAD or an
+// empty string if the path does not exist in HEAD.
+func headHash(fname string) string {
+	hash, err := git.RevParse(fmt.Sprintf("HEAD:%s", fname))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(hash)
+}
+
+// moveFile moves a file, creating any missing parent directories of the destination.
+func moveFile(src, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0777); err != nil {
+		return err
+	}
+	return os.Rename(src, dest)
+}
+
+// HasLocalChanges returns true if the repository has uncommitted changes to
+// tracked files or, if untracked is true, any untracked files.
+// Must be called from the root of the repository.
+func HasLocalChanges(untracked bool) (bool, error) {
+	entries, err := git.StatusPorcelain(untracked)
+	return len(entries) > 0, err
+}
+
+// Shelve sets aside all uncommitted changes in the repository (both staged and unstaged) and
+// restores the affected files to their state in the last commit.
+// Untracked files are only shelved if untracked is true.
+// Must be called from the root of the repository.
+func Shelve(untracked bool) (Shelf, error) {
+	fn := fmt.Sprintf("Shelve(%v)", untracked)
+	entries, err := git.StatusPorcelain(untracked)
+	if err != nil {
+		return Shelf{}, err
+	}
+	if len(entries) == 0 {
+		return Shelf{}, ginerror{Origin: fn, Description: "no local changes to shelve"}
+	}
+
+	shelf := Shelf{Created: time.Now()}
+	if head, err := git.RevParse("HEAD"); err == nil {
+		shelf.Head = strings.TrimSpace(head)
+	}
+	seen := make(map[string]bool)
+	addfile := func(name, state string) {
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+		shelf.Files = append(shelf.Files, ShelvedFile{Name: name, State: state, BaseHash: headHash(name)})
+	}
+	for _, entry := range entries {
+		switch {
+		case entry.Untracked():
+			addfile(entry.Name, ShelvedUntracked)
+		case entry.OrigName != "":
+			// renamed or copied: the new name is treated as added and, for renames, the old name as deleted
+			if entry.Index == 'R' {
+				addfile(entry.OrigName, ShelvedDeleted)
+			}
+			addfile(entry.Name, ShelvedAdded)
+		case entry.Index == 'A':
+			addfile(entry.Name, ShelvedAdded)
+		case entry.Index == 'D' || entry.WorkTree == 'D':
+			addfile(entry.Name, ShelvedDeleted)
+		default:
+			addfile(entry.Name, ShelvedModified)
+		}
+	}
+
+	shelf.ID = shelf.Created.Format("20060102-150405")
+	for n := 1; ; n++ {
+		if _, err := os.Stat(shelf.dir()); os.IsNotExist(err) {
+			break
+		}
+		shelf.ID = fmt.Sprintf("%s-%d", shelf.Created.Format("20060102-150405"), n)
+	}
+	if err := os.MkdirAll(shelf.dir(), 0777); err != nil {
+		return Shelf{}, ginerror{UError: err.Error(), Origin: fn, Description: "failed to create shelf directory"}
+	}
+	// write the manifest before touching any files so that moved files can always be found
+	if err := shelf.writeManifest(); err != nil {
+		os.RemoveAll(shelf.dir())
+		return Shelf{}, ginerror{UError: err.Error(), Origin: fn, Description: "failed to write shelf manifest"}
+	}
+
+	var tracked, restore []string
+	for _, sf := range shelf.Files {
+		if sf.State != ShelvedUntracked {
+			tracked = append(tracked, sf.Name)
+		}
+		if sf.BaseHash != "" {
+			restore = append(restore, sf.Name)
+		}
+	}
+	if len(tracked) > 0 && shelf.Head != "" {
+		if err := git.ResetPaths(tracked); err != nil {
+			os.RemoveAll(shelf.dir())
+			return Shelf{}, err
+		}
+	}
+
+	var moved []ShelvedFile
+	for _, sf := range shelf.Files {
+		if sf.State == ShelvedDeleted {
+			continue
+		}
+		log.Write("Shelving %s (%s)", sf.Name, sf.State)
+		if err := moveFile(filepath.FromSlash(sf.Name), shelf.filePath(sf.Name)); err != nil {
+			// put back what was moved so far
+			for _, mf := range moved {
+				moveFile(shelf.filePath(mf.Name), filepath.FromSlash(mf.Name))
+			}
+			os.RemoveAll(shelf.dir())
+			return Shelf{}, ginerror{UError: err.Error(), Origin: fn, Description: fmt.Sprintf("failed to shelve '%s'", sf.Name)}
+		}
+		moved = append(moved, sf)
+	}
+
+	if len(restore) > 0 {
+		if err := git.Checkout("HEAD", restore); err != nil {
+			return shelf, ginerror{UError: err.Error(), Origin: fn, Description: fmt.Sprintf("changes were shelved (%s) but some files could not be restored to their committed state", shelf.ID)}
+		}
+	}
+	return shelf, nil
+}
+
+// ListShelves returns all shelves in the repository, oldest first.
+// Must be called from the root of the repository.
+func ListShelves() ([]Shelf, error) {
+	dirs, err := ioutil.ReadDir(shelvesDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, ginerror{UError: err.Error(), Origin: "ListShelves()", Description: "failed to read shelves"}
+	}
+	var shelves []Shelf
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		data, rerr := ioutil.ReadFile(filepath.Join(shelvesDir(), d.Name(), shelfManifest))
+		if rerr != nil {
+			log.Write("Failed to read shelf manifest %s: %v", d.Name(), rerr)
+			continue
+		}
+		var shelf Shelf
+		if jerr := json.Unmarshal(data, &shelf); jerr != nil {
+			log.Write("Failed to parse shelf manifest %s: %v", d.Name(), jerr)
+			continue
+		}
+		shelves = append(shelves, shelf)
+	}
+	sort.Slice(shelves, func(i, j int) bool { return shelves[i].ID < shelves[j].ID })
+	return shelves, nil
+}
+
+// shelvedCopyName returns a free name for restoring a shelved file that
+// conflicts with the file currently in the working tree.
+func shelvedCopyName(fname string) string {
+	filext := filepath.Ext(fname)
+	base := strings.TrimSuffix(fname, filext)
+	copyname := fmt.Sprintf("%s-shelved%s", base, filext)
+	for n := 2; ; n++ {
+		if _, err := os.Lstat(filepath.FromSlash(copyname)); os.IsNotExist(err) {
+			return copyname
+		}
+		copyname = fmt.Sprintf("%s-shelved-%d%s", base, n, filext)
+	}
+}
+
+// Unshelve restores the changes from the shelf with the given ID, or the most recent shelf if id is empty.
+// Files that were changed since they were shelved (e.g., by a download) are
+// not overwritten; the shelved version is restored next to them with the
+// suffix '-shelved' and reported as a conflict.
+// Restored changes are not staged.
+// The shelf is deleted once all files have been restored.
+// Must be called from the root of the repository.
+func Unshelve(id string) ([]UnshelvedFile, error) {
+	fn := fmt.Sprintf("Unshelve(%s)", id)
+	shelves, err := ListShelves()
+	if err != nil {
+		return nil, err
+	}
+	if len(shelves) == 0 {
+		return nil, ginerror{Origin: fn, Description: "there are no shelved changes"}
+	}
+	shelf := shelves[len(shelves)-1]
+	if id != "" {
+		found := false
+		for _, s := range shelves {
+			if s.ID == id {
+				shelf, found = s, true
+				break
+			}
+		}
+		if !found {
+			return nil, ginerror{Origin: fn, Description: fmt.Sprintf("no shelf with ID '%s'", id)}
+		}
+	}
+
+	// files with uncommitted changes in the working tree must not be overwritten
+	entries, err := git.StatusPorcelain(true)
+	if err != nil {
+		return nil, err
+	}
+	changed := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		changed[entry.Name] = true
+	}
+
+	var results []UnshelvedFile
+	var failed []ShelvedFile
+	for _, sf := range shelf.Files {
+		res := UnshelvedFile{ShelvedFile: sf, Destination: sf.Name}
+		localname := filepath.FromSlash(sf.Name)
+		unchanged := headHash(sf.Name) == sf.BaseHash && !changed[sf.Name]
+		_, lerr := os.Lstat(localname)
+		exists := lerr == nil
+
+		if sf.State == ShelvedDeleted {
+			if exists && unchanged {
+				res.Err = os.Remove(localname)
+			} else if exists {
+				// changed since shelving: keep the new version
+				res.Conflict = true
+				res.Destination = ""
+			}
+		} else {
+			if exists && !unchanged {
+				res.Conflict = true
+				res.Destination = shelvedCopyName(sf.Name)
+			} else if exists {
+				if rerr := os.Remove(localname); rerr != nil {
+					res.Err = rerr
+				}
+			}
+			if res.Err == nil {
+				res.Err = moveFile(shelf.filePath(sf.Name), filepath.FromSlash(res.Destination))
+			}
+		}
+		if res.Err != nil {
+			log.Write("Failed to unshelve %s: %v", sf.Name, res.Err)
+			failed = append(failed, sf)
+		}
+		results = append(results, res)
+	}
+
+	if len(failed) > 0 {
+		// keep only the files that were not restored
+		shelf.Files = failed
+		if werr := shelf.writeManifest(); werr != nil {
+			log.Write("Failed to update shelf manifest: %v", werr)
+		}
+		return results, ginerror{Origin: fn, Description: fmt.Sprintf("%d file(s) could not be restored; the remaining files are kept in shelf %s", len(failed), shelf.ID)}
+	}
+	if err := os.RemoveAll(shelf.dir()); err != nil {
+		log.Write("Failed to remove shelf directory %s: %v", shelf.dir(), err)
+	}
+	return results, nil
+}
@@ -0,0 +1,108 @@
+package ginclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/G-Node/gin-cli/web"
+	gogs "github.com/gogits/go-gogs-client"
+)
+
+// fakeTokenServer serves the access token routes of the Gogs API (listing and creating tokens) for one user.
+// Like Gogs, it responds with 404 to requests for other routes, including deleting a token.
+type fakeTokenServer struct {
+	username string
+	password string
+	tokens   []AccessToken
+	// paths records the (escaped) paths of all requests
+	paths []string
+}
+
+func (srv *fakeTokenServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	srv.paths = append(srv.paths, r.URL.EscapedPath())
+	if r.URL.Path != "/api/v1/users/"+srv.username+"/tokens" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if username, password, ok := r.BasicAuth(); !ok || username != srv.username || password != srv.password {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		tokens := []AccessToken{}
+		if page := r.URL.Query().Get("page"); page == "" || page == "1" {
+			tokens = srv.tokens
+		}
+		json.NewEncoder(w).Encode(tokens)
+	case http.MethodPost:
+		var opt gogs.CreateAccessTokenOption
+		json.NewDecoder(r.Body).Decode(&opt)
+		token := AccessToken{Name: opt.Name, Sha1: fmt.Sprintf("%040d", len(srv.tokens)+1)}
+		srv.tokens = append(srv.tokens, token)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(token)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestAccessTokens(t *testing.T) {
+	fake := &fakeTokenServer{
+		username: "alice",
+		password: "pass",
+		tokens:   []AccessToken{{Name: "gin-cli", Sha1: "aaaa"}, {Name: "ci", Sha1: "bbbb"}},
+	}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	gincl := &Client{Client: web.New(srv.URL), ctx: context.Background()}
+
+	tokens, err := gincl.GetTokens("alice", "pass")
+	if err != nil || len(tokens) != 2 {
+		t.Fatalf("Expected 2 tokens, got %v (%v)", tokens, err)
+	}
+	if _, err = gincl.GetTokens("alice", "wrong"); err == nil || !strings.Contains(err.Error(), "authorisation failed") {
+		t.Fatalf("Expected authorisation error, got %v", err)
+	}
+
+	tests := []struct {
+		token string
+		name  string
+		ok    bool
+	}{
+		{"bbbb", "ci", true},
+		{"cccc", "", false},
+		{"", "", false},
+	}
+	for _, test := range tests {
+		gincl.Token = test.token
+		if name, ok := gincl.CurrentTokenName(tokens); name != test.name || ok != test.ok {
+			t.Errorf("CurrentTokenName() with token %q = (%q, %v); expected (%q, %v)", test.token, name, ok, test.name, test.ok)
+		}
+	}
+
+	if err = gincl.NewToken("alice", "pass", "gin-cli-2"); err != nil {
+		t.Fatalf("Failed to create token: %s", err.Error())
+	}
+	if gincl.Username != "alice" || gincl.Token != fake.tokens[2].Sha1 || fake.tokens[2].Name != "gin-cli-2" {
+		t.Fatalf("Unexpected token after creation: %+v (client token %q)", fake.tokens, gincl.Token)
+	}
+
+	// Gogs has no route for deleting tokens
+	for _, name := range []string{"ci", "name with/slash"} {
+		if err = gincl.DeleteAccessToken("alice", "pass", name); err == nil || !strings.Contains(err.Error(), "does not support revoking tokens") {
+			t.Errorf("Expected unsupported error when deleting token %q, got %v", name, err)
+		}
+	}
+	if path := fake.paths[len(fake.paths)-1]; path != "/api/v1/users/alice/tokens/name%20with%2Fslash" {
+		t.Errorf("Token name not escaped in request path: %s", path)
+	}
+	if len(fake.tokens) != 3 {
+		t.Fatalf("Unexpected tokens after failed deletion: %v", fake.tokens)
+	}
+}
@@ -65,7 +65,7 @@ func parseRemote(remotestr string) remote {
 
 	conf := config.Read()
 	if srvcfg, ok := conf.Servers[rmt.server]; ok {
-		rmt.url = fmt.Sprintf("%s/%s", srvcfg.Git.AddressStr(), rmt.path)
+		rmt.url = fmt.Sprintf("%s/%s", srvcfg.GitAddressStr(), rmt.path)
 		rmt.rt = ginrt
 		return rmt
 	}
@@ -28,6 +28,17 @@ func promptForWeb() (webconf config.WebCfg) {
 
 func promptForGit() (gitconf config.GitCfg) {
 	fmt.Println(":: Git server configuration")
+	fmt.Print("  Protocol (ssh, https) [ssh]: ")
+	fmt.Scanln(&gitconf.Protocol)
+	switch gitconf.Protocol {
+	case "", config.GitProtocolSSH:
+		gitconf.Protocol = config.GitProtocolSSH
+	case config.GitProtocolHTTPS:
+		// git operations go through the web server
+		return
+	default:
+		Die(fmt.Sprintf("invalid git protocol '%s'", gitconf.Protocol))
+	}
 	fmt.Print("  Username: ")
 	fmt.Scanln(&gitconf.User)
 	fmt.Print("  Host or address: ")
@@ -61,6 +72,11 @@ func parseWebstring(webstring string) (webconf config.WebCfg) {
 }
 
 func parseGitstring(gitstring string) (gitconf config.GitCfg) {
+	if gitstring == config.GitProtocolHTTPS {
+		// git operations go through the web server
+		gitconf.Protocol = config.GitProtocolHTTPS
+		return
+	}
 	errmsg := fmt.Sprintf("invalid git configuration line %s", gitstring)
 	split := strings.SplitN(gitstring, "@", 2)
 	if len(split) != 2 {
@@ -77,6 +93,7 @@ func parseGitstring(gitstring string) (gitconf config.GitCfg) {
 		Die(fmt.Sprintf("%s: %s", errmsg, ginerrors.BadPort))
 	}
 	gitconf.Host, gitconf.Port = split[0], uint16(port)
+	gitconf.Protocol = config.GitProtocolSSH
 	return
 }
 
@@ -109,6 +126,9 @@ func addServer(cmd *cobra.Command, args []string) {
 	if alias == "dir" {
 		Die(fmt.Sprintf("invalid server alias '%s': this word is reserved", alias))
 	}
+	if config.IsSystemServer(alias) {
+		Die(fmt.Sprintf("server '%s' is defined in the system configuration (%s) and can not be changed", alias, config.SystemFile()))
+	}
 
 	webstring, _ := cmd.Flags().GetString("web")
 	gitstring, _ := cmd.Flags().GetString("git")
@@ -127,13 +147,13 @@ func addServer(cmd *cobra.Command, args []string) {
 		serverConf.Git = parseGitstring(gitstring)
 	}
 
-	addHostKey(&serverConf.Git)
+	if !serverConf.Git.UseHTTPS() {
+		addHostKey(&serverConf.Git)
+	}
 
 	// Save to config
 	err := config.AddServerConf(alias, serverConf)
-	if err != nil {
-		Die("failed to update configuration file")
-	}
+	CheckError(err)
 
 	// Recreate known hosts file
 	err = git.WriteKnownHosts()
@@ -164,6 +184,8 @@ For the git server: the git user, hostname, and port
     The hostname for the git server (e.g., git.g-node.org).
     The git port for the server (e.g., 22, 2222).
 
+Alternatively, git operations can use HTTPS through the web server instead of SSH, which is useful on networks that block SSH connections. In this case, specify 'https' instead of the git server address. Authentication uses the token stored when logging in; no SSH keys or host keys are involved.
+
 See the Examples section for a full example.
 `
 	args := map[string]string{
@@ -171,9 +193,10 @@ See the Examples section for a full example.
 	}
 	examples := map[string]string{
 		"This is what configuring the built-in G-Node GIN server would look like (note: this is already configured)": "$ gin add-server --web https://web.gin.g-node.org:443 --git git@git.g-node.org:22 gin",
+		"Configure a server that uses HTTPS for git operations":                                                      "$ gin add-server --web https://web.gin.g-node.org:443 --git https gin-https",
 	}
 	var cmd = &cobra.Command{
-		Use:                   "add-server [--web http[s]://<hostname>[:<port>]] [--git [<gituser>@]<hostname>[:<port>]] <alias>",
+		Use:                   "add-server [--web http[s]://<hostname>[:<port>]] [--git [<gituser>@]<hostname>[:<port>] | --git https] <alias>",
 		Short:                 "Add a new GIN server configuration",
 		Long:                  formatdesc(description, args),
 		Args:                  cobra.ExactArgs(1),
@@ -182,6 +205,6 @@ See the Examples section for a full example.
 		DisableFlagsInUseLine: true,
 	}
 	cmd.Flags().String("web", "", "Set the address and port for the web server.")
-	cmd.Flags().String("git", "", "Set the user, address and port for the git server.")
+	cmd.Flags().String("git", "", "Set the user, address and port for the git server, or 'https' to use the web server for git operations.")
 	return cmd
 }
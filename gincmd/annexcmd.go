@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/G-Node/gin-cli/ginclient/credentials"
 	"github.com/G-Node/gin-cli/git"
 	"github.com/spf13/cobra"
 )
@@ -21,6 +22,7 @@ func annexrun(cmd *cobra.Command, args []string) {
 		fmt.Fprint(os.Stderr, line)
 	}
 	if annexcmd.Wait() != nil {
+		credentials.Cleanup()
 		os.Exit(1)
 	}
 }
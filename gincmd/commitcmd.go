@@ -37,6 +37,7 @@ func commit(cmd *cobra.Command, args []string) {
 	if commitmsg == "" {
 		commitmsg = makeCommitMessage("commit", paths)
 	}
+	head := ginclient.CurrentHead()
 	err := git.Commit(commitmsg)
 	var stat string
 	if err != nil {
@@ -47,6 +48,11 @@ func commit(cmd *cobra.Command, args []string) {
 		}
 	} else {
 		stat = green("OK")
+		op := ginclient.Operation{Command: ginclient.OpCommit, Head: head, NewHead: ginclient.CurrentHead()}
+		if cmd.Name() == "version" {
+			op.Command = ginclient.OpVersion
+		}
+		recordOperation(op)
 	}
 	if prStyle == psDefault {
 		fmt.Fprintln(color.Output, stat)
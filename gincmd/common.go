@@ -2,19 +2,26 @@ package gincmd
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"math"
 	"os"
+	"os/signal"
 	"runtime"
 	"strings"
+	"time"
 
 	ginclient "github.com/G-Node/gin-cli/ginclient"
+	"github.com/G-Node/gin-cli/ginclient/config"
+	"github.com/G-Node/gin-cli/ginclient/credentials"
 	"github.com/G-Node/gin-cli/ginclient/log"
 	"github.com/G-Node/gin-cli/git"
+	"github.com/G-Node/gin-cli/web"
 	"github.com/bbrks/wrap"
 	"github.com/docker/docker/pkg/term"
 	"github.com/fatih/color"
+	"github.com/howeyc/gopass"
 	"github.com/spf13/cobra"
 )
 
@@ -33,16 +40,23 @@ var (
 		"add-remote",
 		"commit",
 		"create",
+		"diff",
 		"download",
+		"fork",
 		"get",
 		"get-content",
 		"init",
 		"lock",
+		"log",
 		"ls",
 		"remotes",
 		"remove-content",
 		"remove-remote",
+		"resolve",
+		"shelve",
+		"undo",
 		"unlock",
+		"unshelve",
 		"upload",
 		"use-remote",
 		"version",
@@ -67,6 +81,7 @@ func Die(msg interface{}) {
 	} else {
 		log.Write("Exiting with ERROR (no message)")
 	}
+	credentials.Cleanup()
 	log.Close()
 	os.Exit(1)
 }
@@ -79,10 +94,39 @@ func Exit(msg string) {
 	} else {
 		log.Write("Exiting")
 	}
+	credentials.Cleanup()
 	log.Close()
 	os.Exit(0)
 }
 
+// interruptContext returns a context that is cancelled when the program is
+// interrupted (Ctrl-C), so that requests in progress are cancelled and the
+// command can exit cleanly with an error. If no request is in progress, or the
+// command does not exit shortly after the requests are cancelled, or the
+// program is interrupted a second time, the program exits immediately.
+func interruptContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	sigchan := make(chan os.Signal, 1)
+	signal.Notify(sigchan, os.Interrupt)
+	go func() {
+		<-sigchan
+		if web.Active() {
+			log.Write("Interrupted: cancelling requests")
+			cancel()
+			select {
+			case <-sigchan:
+			case <-time.After(2 * time.Second):
+			}
+		}
+		log.Write("Interrupted: exiting")
+		fmt.Fprintln(os.Stderr)
+		credentials.Cleanup()
+		log.Close()
+		os.Exit(130)
+	}()
+	return ctx
+}
+
 // CheckError exits the program if an error is passed to the function.
 // The error message is checked for known error messages and an informative message is printed.
 // Otherwise, the error message is printed to stderr.
@@ -112,6 +156,24 @@ func requirelogin(cmd *cobra.Command, gincl *ginclient.Client, prompt bool) {
 	gincl.LoadToken()
 }
 
+// trustOnFirstUse stores the host key presented by the git server of the given
+// server alias if it has no host key configured and prints its fingerprint.
+// Failures are only reported, since the host key can be trusted later with
+// 'gin hostkeys --refresh'.
+func trustOnFirstUse(srvalias string) {
+	fingerprint, err := git.TrustOnFirstUse(srvalias)
+	if err != nil {
+		log.Write("Failed to retrieve host key of %s for trust-on-first-use: %v", srvalias, err)
+		fmt.Fprintf(color.Error, "%s failed to retrieve the host key of server '%s': run 'gin hostkeys --refresh %s' to review and trust it\n", yellow("[warning]"), srvalias, srvalias)
+		return
+	}
+	if fingerprint == "" {
+		return
+	}
+	address := config.Read().Servers[srvalias].Git.AddressStr()
+	fmt.Fprintf(color.Error, "%s no host key was configured for server '%s': trusting the key presented by %s on first use\n  fingerprint: %s\nVerify the fingerprint with the server administrators. All host key changes are recorded in %s\n", yellow("[warning]"), srvalias, address, fingerprint, git.HostKeyLogPath())
+}
+
 func usageDie(cmd *cobra.Command) {
 	cmd.Help()
 	// exit without message
@@ -296,8 +358,12 @@ func determinePrintStyle(cmd *cobra.Command) printstyle {
 }
 
 func formatOutput(statuschan <-chan git.RepoFileStatus, pstyle printstyle, nitems int) {
-	// TODO: instead of a true/false success, add an error for every file and then group the errors by type and print a report
-	var filesuccess map[string]bool
+	filesuccess := printOutput(statuschan, pstyle, nitems)
+	dieOnFailures(filesuccess)
+}
+
+// printOutput prints the status messages received on the channel in the given style and returns the success state of each file.
+func printOutput(statuschan <-chan git.RepoFileStatus, pstyle printstyle, nitems int) (filesuccess map[string]bool) {
 	switch pstyle {
 	case psJSON:
 		filesuccess = printJSON(statuschan)
@@ -308,7 +374,12 @@ func formatOutput(statuschan <-chan git.RepoFileStatus, pstyle printstyle, nitem
 	case psDefault:
 		filesuccess = printProgressOutput(statuschan)
 	}
+	return filesuccess
+}
 
+// dieOnFailures exits with an error message if any of the files in the map failed.
+func dieOnFailures(filesuccess map[string]bool) {
+	// TODO: instead of a true/false success, add an error for every file and then group the errors by type and print a report
 	// count unique file errors
 	nerrors := 0
 	for _, stat := range filesuccess {
@@ -410,15 +481,45 @@ func disableCommands(cmds map[string]*cobra.Command, giterr, annexerr error) {
 
 }
 
+// promptPassphrase asks for the passphrase of the encrypted credential store on the terminal.
+// Prompts are printed to stderr to keep the output of commands (e.g., JSON) intact.
+func promptPassphrase(confirm bool) (string, error) {
+	if !term.IsTerminal(os.Stdin.Fd()) {
+		return "", fmt.Errorf("the encrypted credential store requires a passphrase: set the %s environment variable", credentials.PassphraseEnvVar)
+	}
+	prompt := "Credential store passphrase: "
+	if confirm {
+		prompt = "New credential store passphrase: "
+	}
+	pp, err := gopass.GetPasswdPrompt(prompt, true, os.Stdin, os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	if confirm {
+		ppconf, err := gopass.GetPasswdPrompt("Repeat passphrase: ", true, os.Stdin, os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		if !bytes.Equal(pp, ppconf) {
+			return "", fmt.Errorf("passphrases do not match")
+		}
+	}
+	return string(pp), nil
+}
+
 // SetUpCommands sets up all the subcommands for the client and returns the root command, ready to execute.
 func SetUpCommands(verinfo VersionInfo) *cobra.Command {
 	verstr := verinfo.String()
+	credentials.PassphraseFunc = promptPassphrase
+	ginclient.SetContext(interruptContext())
 	var rootCmd = &cobra.Command{
 		Use:                   "gin",
 		Long:                  "GIN Command Line Interface and client for the GIN services", // TODO: Add license and web info
 		Version:               fmt.Sprintln(verstr),
 		DisableFlagsInUseLine: true,
 	}
+	rootCmd.PersistentFlags().StringArrayP("config", "c", nil, "Set a configuration value for this command only, as `key=value` (can be specified multiple times). See 'gin help config'.")
+	rootCmd.PersistentPreRun = applyConfigFlags
 	cmds := make(map[string]*cobra.Command)
 
 	// Login
@@ -439,6 +540,9 @@ func SetUpCommands(verinfo VersionInfo) *cobra.Command {
 	// Servers
 	cmds["servers"] = ServersCmd()
 
+	// Configuration
+	cmds["config"] = ConfigCmd()
+
 	// Account info
 	cmds["info"] = InfoCmd()
 
@@ -448,12 +552,33 @@ func SetUpCommands(verinfo VersionInfo) *cobra.Command {
 	// Repo info
 	cmds["repoinfo"] = RepoInfoCmd()
 
+	// Search repos
+	cmds["search"] = SearchCmd()
+
+	// Star repos
+	cmds["star"] = StarCmd()
+
+	// Webhooks
+	cmds["hooks"] = HooksCmd()
+
+	// Issues
+	cmds["issues"] = IssuesCmd()
+
 	// Keys
 	cmds["keys"] = KeysCmd()
 
+	// Access tokens
+	cmds["tokens"] = TokensCmd()
+
+	// Host keys
+	cmds["hostkeys"] = HostKeysCmd()
+
 	// Init repo
 	cmds["init"] = InitCmd()
 
+	// Fork repo
+	cmds["fork"] = ForkCmd()
+
 	// Add remote
 	cmds["add-remote"] = AddRemoteCmd()
 
@@ -496,6 +621,13 @@ func SetUpCommands(verinfo VersionInfo) *cobra.Command {
 	// Sync
 	cmds["sync"] = SyncCmd()
 
+	// Resolve conflicts
+	cmds["resolve"] = ResolveCmd()
+
+	// Shelve and unshelve local changes
+	cmds["shelve"] = ShelveCmd()
+	cmds["unshelve"] = UnshelveCmd()
+
 	// Get content
 	cmds["get-content"] = GetContentCmd()
 
@@ -505,10 +637,25 @@ func SetUpCommands(verinfo VersionInfo) *cobra.Command {
 	// Version
 	cmds["version"] = VersionCmd()
 
+	// Log
+	cmds["log"] = LogCmd()
+
+	// Diff
+	cmds["diff"] = DiffCmd()
+
+	// Undo
+	cmds["undo"] = UndoCmd()
+
 	cmds["git"] = GitCmd()
 
 	cmds["annex"] = AnnexCmd()
 
+	// Git credential helper (unlisted)
+	cmds["git-credential"] = GitCredentialCmd()
+
+	// SSH proxy command (unlisted)
+	cmds["ssh-proxy"] = SSHProxyCmd()
+
 	// Currently treating git and git-annex dependency together: if one is broken, we assume both are
 	// This might change in the future (a command might work with git even if annex isn't found)
 	gitok, giterr := verinfo.GitOK()
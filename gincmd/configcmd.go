@@ -0,0 +1,190 @@
+package gincmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/G-Node/gin-cli/ginclient/config"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// configScope returns the configuration file selected with --system, --global, or --repo (empty if none is given).
+func configScope(cmd *cobra.Command) string {
+	system, _ := cmd.Flags().GetBool("system")
+	global, _ := cmd.Flags().GetBool("global")
+	repo, _ := cmd.Flags().GetBool("repo")
+	nscopes := 0
+	for _, flag := range []bool{system, global, repo} {
+		if flag {
+			nscopes++
+		}
+	}
+	switch {
+	case nscopes > 1:
+		usageDie(cmd)
+	case system:
+		return config.ScopeSystem
+	case global:
+		return config.ScopeGlobal
+	case repo:
+		return config.ScopeRepo
+	}
+	return ""
+}
+
+// applyConfigFlags sets the configuration values given with the global --config flag.
+func applyConfigFlags(cmd *cobra.Command, args []string) {
+	values, _ := cmd.Flags().GetStringArray("config")
+	for _, keyvalue := range values {
+		parts := strings.SplitN(keyvalue, "=", 2)
+		if len(parts) != 2 {
+			Die(fmt.Sprintf("invalid configuration value '%s': must be specified as key=value", keyvalue))
+		}
+		CheckError(config.SetOverride(parts[0], parts[1]))
+	}
+}
+
+// formatConfigValue returns the value of a setting as printed by 'gin config'.
+func formatConfigValue(value interface{}, sep string) string {
+	switch v := value.(type) {
+	case []string:
+		return strings.Join(v, sep)
+	case []interface{}:
+		items := make([]string, len(v))
+		for idx, item := range v {
+			items[idx] = fmt.Sprint(item)
+		}
+		return strings.Join(items, sep)
+	}
+	return fmt.Sprint(value)
+}
+
+func configGet(cmd *cobra.Command, args []string) {
+	jsonout, _ := cmd.Flags().GetBool("json")
+	showorigin, _ := cmd.Flags().GetBool("show-origin")
+	setting, err := config.GetValue(configScope(cmd), args[0])
+	CheckError(err)
+	if jsonout {
+		j, _ := json.Marshal(setting)
+		fmt.Println(string(j))
+		return
+	}
+	if showorigin {
+		fmt.Fprintf(color.Output, "%s %s\n", formatConfigValue(setting.Value, ", "), yellow("("+setting.OriginStr()+")"))
+		return
+	}
+	fmt.Println(formatConfigValue(setting.Value, "\n"))
+}
+
+func configList(cmd *cobra.Command, args []string) {
+	jsonout, _ := cmd.Flags().GetBool("json")
+	showorigin, _ := cmd.Flags().GetBool("show-origin")
+	settings, err := config.ListValues(configScope(cmd))
+	CheckError(err)
+	if jsonout {
+		j, _ := json.Marshal(settings)
+		fmt.Println(string(j))
+		return
+	}
+	for _, setting := range settings {
+		fmt.Printf("%s = %s", setting.Key, formatConfigValue(setting.Value, ", "))
+		if showorigin {
+			fmt.Fprintf(color.Output, " %s", yellow("("+setting.OriginStr()+")"))
+		}
+		fmt.Println()
+	}
+}
+
+func configSet(cmd *cobra.Command, args []string) {
+	scope := configScope(cmd)
+	if scope == "" {
+		scope = config.ScopeGlobal
+	}
+	CheckError(config.SetValue(scope, args[0], args[1:]...))
+}
+
+func configUnset(cmd *cobra.Command, args []string) {
+	scope := configScope(cmd)
+	if scope == "" {
+		scope = config.ScopeGlobal
+	}
+	CheckError(config.UnsetValue(scope, args[0]))
+}
+
+// configSubCmd adds the flags for selecting the configuration file to a 'config' subcommand.
+func configSubCmd(cmd *cobra.Command) *cobra.Command {
+	cmd.DisableFlagsInUseLine = true
+	cmd.Flags().Bool("global", false, "Use the user configuration file.")
+	cmd.Flags().Bool("repo", false, "Use the configuration file of the current repository.")
+	return cmd
+}
+
+// ConfigCmd sets up the 'config' subcommand
+func ConfigCmd() *cobra.Command {
+	description := "Read and write the configuration of the client. See the documentation of the configuration for a description of all options.\n\nValues are taken from the following layers, where each layer overrides the ones before it: the built-in defaults, the system configuration file, the user configuration file, the configuration file at the root of the current repository (annex, network, and ssh key type options only), GIN_* environment variables (e.g., GIN_ANNEX_MINSIZE), and values set for a single command with the global flag --config (-c).\n\nThe system configuration file (/etc/gin/config.yml on Linux, or the file specified in the GIN_SYSTEM_CONFIG environment variable) is managed by the administrators of the system. Options it lists under 'locked', and the options of the servers it defines (except git.hostkey, git.identityfile, and git.useagent), can not be changed by the user.\n\nValues are checked before they are written: sizes, durations, port numbers, and options with a fixed set of values must be valid, and programs (bin options) and files must exist.\n\nWithout --system, --global, or --repo, 'get' and 'list' show the values in effect. With --show-origin, they also show the layer each value was taken from. 'set' and 'unset' change the user configuration file unless --repo is specified."
+	var cmd = &cobra.Command{
+		Use:                   "config <command>",
+		Short:                 "Read and write the client configuration",
+		Long:                  formatdesc(description, nil),
+		DisableFlagsInUseLine: true,
+	}
+
+	keyarg := map[string]string{
+		"<key>": "The configuration option, with sections separated by '.' (e.g., annex.minsize or servers.gin.web.port).",
+	}
+	setargs := map[string]string{
+		"<key>":   keyarg["<key>"],
+		"<value>": "The new value. Multiple values can be given for list options (annex.exclude).",
+	}
+
+	getcmd := configSubCmd(&cobra.Command{
+		Use:     "get [--json] [--show-origin] [--system | --global | --repo] <key>",
+		Short:   "Print a configuration value",
+		Long:    formatdesc("Print the value of a configuration option. With --system, --global, or --repo, the value set in the respective configuration file is printed.", keyarg),
+		Example: formatexamples(map[string]string{"Print the size threshold for adding files to the annex": "$ gin config get annex.minsize"}),
+		Args:    cobra.ExactArgs(1),
+		Run:     configGet,
+	})
+	getcmd.Flags().Bool("system", false, "Use the system configuration file.")
+	getcmd.Flags().Bool("json", false, "Print the value and its origin in JSON format.")
+	getcmd.Flags().Bool("show-origin", false, "Show the layer (and the file or environment variable) the value was taken from.")
+
+	listcmd := configSubCmd(&cobra.Command{
+		Use:     "list [--json] [--show-origin] [--system | --global | --repo]",
+		Short:   "List configuration values",
+		Long:    formatdesc("List all configuration options with the values in effect. With --system, --global, or --repo, only the values set in the respective configuration file are listed.", nil),
+		Example: formatexamples(map[string]string{"Show where each value in effect was taken from": "$ gin config list --show-origin"}),
+		Args:    cobra.NoArgs,
+		Run:     configList,
+	})
+	listcmd.Flags().Bool("system", false, "Use the system configuration file.")
+	listcmd.Flags().Bool("json", false, "Print listing in JSON format.")
+	listcmd.Flags().Bool("show-origin", false, "Show the layer (and the file or environment variable) each value was taken from.")
+
+	setcmd := configSubCmd(&cobra.Command{
+		Use:   "set [--global | --repo] <key> <value>...",
+		Short: "Set a configuration value",
+		Long:  formatdesc("Check and set the value of a configuration option in the user configuration file, or in the configuration file of the current repository with --repo.", setargs),
+		Example: formatexamples(map[string]string{
+			"Add files of 1 MB and larger to the annex in the current repository": "$ gin config set --repo annex.minsize 1M",
+			"Exclude Python and Markdown files from the annex":                    "$ gin config set annex.exclude '*.py' '*.md'",
+			"Use a custom git-annex installation":                                 "$ gin config set bin.gitannex /opt/git-annex/bin/git-annex",
+		}),
+		Args: cobra.MinimumNArgs(2),
+		Run:  configSet,
+	})
+
+	unsetcmd := configSubCmd(&cobra.Command{
+		Use:     "unset [--global | --repo] <key>",
+		Short:   "Remove a configuration value",
+		Long:    formatdesc("Remove a configuration option from the user configuration file, or from the configuration file of the current repository with --repo. The default value (or the value from another configuration file) is used instead.", keyarg),
+		Example: formatexamples(map[string]string{"Use the default network timeout": "$ gin config unset network.timeout"}),
+		Args:    cobra.ExactArgs(1),
+		Run:     configUnset,
+	})
+
+	cmd.AddCommand(getcmd, listcmd, setcmd, unsetcmd)
+	return cmd
+}
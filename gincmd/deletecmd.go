@@ -2,16 +2,37 @@ package gincmd
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 
 	ginclient "github.com/G-Node/gin-cli/ginclient"
 	"github.com/G-Node/gin-cli/ginclient/config"
 	"github.com/G-Node/gin-cli/ginclient/log"
+	"github.com/G-Node/gin-cli/git"
+	humanize "github.com/dustin/go-humanize"
+	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 )
 
+// maxListedFiles is the number of files listed in warnings about content before the list is shortened.
+const maxListedFiles = 10
+
+func printFileList(files []string) {
+	for idx, fname := range files {
+		if idx == maxListedFiles {
+			fmt.Printf("    ... and %d more\n", len(files)-maxListedFiles)
+			break
+		}
+		fmt.Printf("    %s\n", fname)
+	}
+}
+
 func deleteRepo(cmd *cobra.Command, args []string) {
 	flags := cmd.Flags()
 	srvalias, _ := flags.GetString("server")
+	dryrun, _ := flags.GetBool("dry-run")
+	archivedir, _ := flags.GetString("archive")
+	force, _ := flags.GetBool("force")
 
 	conf := config.Read()
 	if srvalias == "" {
@@ -19,12 +40,9 @@ func deleteRepo(cmd *cobra.Command, args []string) {
 	}
 	gincl := ginclient.New(srvalias)
 	requirelogin(cmd, gincl, true)
-	var repostr, confirmation string
-
-	if len(args) == 0 {
-		usageDie(cmd)
-	} else {
-		repostr = args[0]
+	repostr := args[0]
+	if !isValidRepoPath(repostr) {
+		Die(fmt.Sprintf("Invalid repository path '%s'. Full repository name should be the owner's username followed by the repository name, separated by a '/'.\nType 'gin help delete' for information and examples.", repostr))
 	}
 
 	repoinfo, err := gincl.GetRepo(repostr)
@@ -35,36 +53,112 @@ func deleteRepo(cmd *cobra.Command, args []string) {
 		Die("An unexpected error occurred while communicating with the server.")
 	}
 
+	// Summary of the repository on the server
+	fmt.Printf("Repository: %s\n", repoinfo.FullName)
+	fmt.Printf("  Location: %s\n", repoinfo.HTMLURL)
+	fmt.Printf("  Size: %s\n", humanize.IBytes(uint64(repoinfo.Size)))
+	filecount := "0"
+	if !repoinfo.Empty {
+		if nfiles, ferr := gincl.RepoFileCount(repostr, repoinfo.DefaultBranch); ferr == nil {
+			filecount = fmt.Sprintf("%d", nfiles)
+		} else {
+			log.Write("Failed to count files: %v", ferr)
+			filecount = "unknown"
+		}
+	}
+	fmt.Printf("  Files: %s\n", filecount)
+	fmt.Printf("  Stars: %d, forks: %d, open issues: %d\n", repoinfo.Stars, repoinfo.Forks, repoinfo.OpenIssues)
+	fmt.Println()
+
+	// Content that would be lost
+	check, err := gincl.CheckLocalContent(repostr)
+	CheckError(err)
+	if check.Remote == "" {
+		fmt.Fprintf(color.Output, "%s The current directory is not a local copy of the repository: the content stored on the server can not be checked.\n", yellow("[warning]"))
+		fmt.Println("          Run the command in a local copy of the repository or use --archive to keep a copy of the repository.")
+	} else {
+		fmt.Printf("Checked %d annexed files of the local copy (remote '%s').\n", check.Files, check.Remote)
+		if len(check.ServerOnly) > 0 {
+			fmt.Fprintf(color.Output, "%s The content of %d file(s) is only stored on the server and would be lost:\n", red("[warning]"), len(check.ServerOnly))
+			printFileList(check.ServerOnly)
+		}
+		if len(check.LocalOnly) > 0 {
+			fmt.Fprintf(color.Output, "%s After deletion, the local copy will hold the only copy of the content of %d file(s):\n", yellow("[warning]"), len(check.LocalOnly))
+			printFileList(check.LocalOnly)
+		}
+		if len(check.ServerOnly) == 0 {
+			fmt.Println("All content of the current version is available outside the server.")
+		}
+	}
+	fmt.Println()
+
+	if dryrun {
+		if archivedir != "" {
+			fmt.Printf("The repository would be archived in %s before deletion.\n", filepath.Join(archivedir, repoinfo.Name))
+		}
+		fmt.Println("Dry run: the repository was not deleted.")
+		return
+	}
+	if !check.DeletionAllowed(archivedir != "", force) {
+		Die("Deletion cancelled: content stored on the server may be lost. Use --archive <directory> to download the repository first, or --force to delete it anyway.")
+	}
+
 	fmt.Println("--- WARNING ---")
 	fmt.Println("You are about to delete a remote repository, all its files, and history.")
 	fmt.Println("This action is irreversible.")
 	fmt.Printf("You are about to delete the repository at: %s\n", repoinfo.HTMLURL)
-
 	fmt.Println("If you are sure you want to delete this repository, type its full name (owner/name) below")
 	fmt.Print("> ")
+	var confirmation string
 	fmt.Scanln(&confirmation)
+	if repoinfo.FullName != confirmation || repostr != confirmation {
+		Die("Confirmation does not match repository name. Cancelling.")
+	}
 
-	if repoinfo.FullName == confirmation && repostr == confirmation {
-		err = gincl.DelRepo(repostr)
+	if archivedir != "" {
+		wd, _ := os.Getwd()
+		fmt.Printf(":: Archiving repository in %s\n", filepath.Join(archivedir, repoinfo.Name))
+		archivechan := make(chan git.RepoFileStatus)
+		go gincl.ArchiveRepo(repostr, archivedir, archivechan)
+		formatOutput(archivechan, psDefault, 0)
+		missing, err := ginclient.MissingContent()
 		CheckError(err)
-	} else {
-		Die("Confirmation does not match repository name. Cancelling.")
+		os.Chdir(wd)
+		if len(missing) > 0 {
+			fmt.Fprintf(color.Output, "%s The content of %d file(s) could not be archived:\n", red("[error]"), len(missing))
+			printFileList(missing)
+			Die("Deletion cancelled: the archive is incomplete.")
+		}
+		fmt.Fprintf(color.Output, ":: Archive complete %s\n", green("OK"))
 	}
 
+	err = gincl.DelRepo(repostr)
+	CheckError(err)
 	fmt.Printf("Repository %s has been deleted!\n", repostr)
 }
 
 // DeleteCmd sets up the 'delete' repository subcommand
 func DeleteCmd() *cobra.Command {
+	description := "Delete a repository from the server, including all its files and history. This action is irreversible.\n\nBefore anything is deleted, a summary of the repository is shown (size, number of files, stars, forks, and open issues) and the full repository path must be typed to confirm the deletion.\n\nWhen run in a local copy of the repository, the command checks which files of the current version have content that is only stored on the server (which would be lost) and which files would only remain in the local copy. If content would be lost, or if the command is not run in a local copy, the deletion is refused unless the repository is archived first (--archive) or --force is specified.\n\nWith --archive, the repository is downloaded into a new directory inside the given directory, including the content of all versions of all files, before it is deleted. The deletion is cancelled if the archive is incomplete.\n\nUse --dry-run to show the summary and checks without deleting anything."
+	args := map[string]string{
+		"<repopath>": "The path of the repository to delete. A repository path is the owner's username, followed by a \"/\" and the repository name.",
+	}
+	examples := map[string]string{
+		"Check what would be lost by deleting the repository 'scratch' of user 'alice'": "$ gin delete --dry-run alice/scratch",
+		"Download a full copy of the repository into 'backups' and delete it":           "$ gin delete --archive backups alice/scratch",
+	}
 	var cmd = &cobra.Command{
-		Use:                   "delete <repository>",
+		Use:                   "delete [--dry-run] [--archive <directory>] [--force] <repopath>",
 		Short:                 "Delete a repository from the GIN server",
-		Long:                  "Delete a repository from the GIN server.",
+		Long:                  formatdesc(description, args),
+		Example:               formatexamples(examples),
 		Args:                  cobra.ExactArgs(1),
 		Run:                   deleteRepo,
 		DisableFlagsInUseLine: true,
-		Hidden:                true,
 	}
+	cmd.Flags().Bool("dry-run", false, "Show the repository summary and content checks without deleting anything.")
+	cmd.Flags().String("archive", "", "Download the repository with the content of all versions into a new directory inside `directory` before deleting it.")
+	cmd.Flags().Bool("force", false, "Delete the repository even if content stored on the server would be lost.")
 	cmd.Flags().String("server", "", "Specify server `alias` on which the repository to be deleted resides. See also 'gin servers'.")
 	return cmd
 }
@@ -0,0 +1,173 @@
+package gincmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	ginclient "github.com/G-Node/gin-cli/ginclient"
+	"github.com/G-Node/gin-cli/gincmd/ginerrors"
+	"github.com/G-Node/gin-cli/git"
+	humanize "github.com/dustin/go-humanize"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+func formatVersionSize(fv *ginclient.FileVersion) string {
+	if fv == nil {
+		return "-"
+	}
+	if fv.Size < 0 {
+		return "?"
+	}
+	return humanize.IBytes(uint64(fv.Size))
+}
+
+func printDiffStat(diffs []ginclient.FileDiff) {
+	var nadded, nremoved, nmodified int
+	for _, fd := range diffs {
+		var statestr string
+		switch fd.State {
+		case ginclient.DiffAdded:
+			statestr = green(fd.State)
+			nadded++
+		case ginclient.DiffRemoved:
+			statestr = red(fd.State)
+			nremoved++
+		default:
+			statestr = yellow(fd.State)
+			nmodified++
+		}
+		ftype := "git"
+		if fd.Annexed() {
+			ftype = "annex"
+		}
+		fmt.Fprintf(color.Output, " %-10s %-5s %s (%s -> %s)\n", statestr, ftype, fd.FileName, formatVersionSize(fd.Old), formatVersionSize(fd.New))
+	}
+	fmt.Printf(" %d files changed: %d added, %d removed, %d modified\n", len(diffs), nadded, nremoved, nmodified)
+}
+
+func printDiff(diffs []ginclient.FileDiff) {
+	for _, fd := range diffs {
+		if !fd.Annexed() {
+			if fd.Text != "" {
+				fmt.Print(fd.Text)
+			}
+			continue
+		}
+		fmt.Fprintf(color.Output, "%s %s (annexed)\n", yellow(fd.State), fd.FileName)
+		if fd.Old != nil {
+			fmt.Printf("  old: %s %s\n", formatVersionSize(fd.Old), fd.Old.AnnexKey)
+		}
+		if fd.New != nil {
+			fmt.Printf("  new: %s %s\n", formatVersionSize(fd.New), fd.New.AnnexKey)
+		}
+	}
+}
+
+// isRevision returns true if the argument refers to a commit.
+func isRevision(arg string) bool {
+	_, err := git.RevParse(fmt.Sprintf("%s^{commit}", arg))
+	return err == nil
+}
+
+func diff(cmd *cobra.Command, args []string) {
+	prStyle := determinePrintStyle(cmd)
+	stat, _ := cmd.Flags().GetBool("stat")
+	if !git.IsRepo() {
+		Die(ginerrors.NotInRepo)
+	}
+
+	// up to two leading arguments are revisions, unless a '--' separates them from the paths
+	var revs, paths []string
+	dashidx := cmd.ArgsLenAtDash()
+	if dashidx >= 0 {
+		revs, paths = args[:dashidx], args[dashidx:]
+		if len(revs) > 2 {
+			usageDie(cmd)
+		}
+	} else {
+		for idx, arg := range args {
+			if idx < 2 && len(paths) == 0 && isRevision(arg) {
+				if _, err := os.Lstat(arg); err == nil {
+					Die(fmt.Sprintf("'%s' is both a version and a file name: use '--' to separate versions from file names", arg))
+				}
+				revs = append(revs, arg)
+				continue
+			}
+			paths = append(paths, arg)
+		}
+	}
+	var rev1, rev2 string
+	if len(revs) > 0 {
+		rev1 = revs[0]
+	}
+	if len(revs) > 1 {
+		rev2 = revs[1]
+	}
+
+	reporoot, _ := git.FindRepoRoot(".")
+	workingdir, _ := os.Getwd()
+	var rootpaths []string
+	for _, p := range paths {
+		abspath, _ := filepath.Abs(p)
+		relpath, err := filepath.Rel(reporoot, abspath)
+		if err != nil || strings.HasPrefix(relpath, "..") {
+			Die(fmt.Sprintf("path '%s' is outside the repository", p))
+		}
+		rootpaths = append(rootpaths, filepath.ToSlash(relpath))
+	}
+	os.Chdir(reporoot)
+	defer os.Chdir(workingdir)
+
+	diffs, err := ginclient.Diff(rev1, rev2, rootpaths)
+	CheckError(err)
+
+	if prStyle == psJSON {
+		if stat {
+			for idx := range diffs {
+				diffs[idx].Text = ""
+			}
+		}
+		j, _ := json.Marshal(diffs)
+		fmt.Println(string(j))
+		return
+	}
+	if len(diffs) == 0 {
+		return
+	}
+	if !stat {
+		printDiff(diffs)
+		fmt.Println()
+	}
+	printDiffStat(diffs)
+}
+
+// DiffCmd sets up the 'diff' subcommand
+func DiffCmd() *cobra.Command {
+	description := "Show the changes between two versions of the repository, or between a version and the current state of the files in the local repository. Added, removed, and modified files are listed with their old and new sizes. For annexed files, the annex keys of the old and new content are shown. For files tracked by git, the changes to the contents are printed.\n\nWith no versions specified, the last committed version is compared to the local files. With one version, that version is compared to the local files. With two versions, the two versions are compared."
+	args := map[string]string{
+		"<version1>":  "Version ID (commit hash) or name (e.g., HEAD~1, branch or tag name) to compare from.",
+		"<version2>":  "Version ID or name to compare to. If omitted, the local files are used.",
+		"<filenames>": "One or more directories or files to limit the comparison to. Use '--' to separate file names from versions if they are ambiguous.",
+	}
+	examples := map[string]string{
+		"Show local changes since the last commit":             "$ gin diff",
+		"Summarise the changes between two versions":           "$ gin diff --stat 4a8e2f5 HEAD",
+		"Show changes in the 'code' directory since a version": "$ gin diff 4a8e2f5 -- code",
+	}
+	var cmd = &cobra.Command{
+		Use:                   "diff [--json] [--stat] [<version1> [<version2>]] [[--] <filenames>...]",
+		Short:                 "Show changes between versions of the repository",
+		Long:                  formatdesc(description, args),
+		Example:               formatexamples(examples),
+		Args:                  cobra.ArbitraryArgs,
+		Run:                   diff,
+		DisableFlagsInUseLine: true,
+	}
+	cmd.Flags().Bool("json", false, jsonHelpMsg)
+	cmd.Flags().Bool("stat", false, "Show only a summary of changed files and their sizes.")
+	return cmd
+}
@@ -26,16 +26,59 @@ func download(cmd *cobra.Command, args []string) {
 	}
 
 	content, _ := cmd.Flags().GetBool("content")
+	autostash, _ := cmd.Flags().GetBool("autostash")
+	reporoot, _ := git.FindRepoRoot(".")
+
+	var shelf ginclient.Shelf
+	if autostash {
+		workingdir, _ := os.Getwd()
+		os.Chdir(reporoot)
+		changes, serr := ginclient.HasLocalChanges(true)
+		CheckError(serr)
+		if changes {
+			if prStyle == psDefault {
+				fmt.Print(":: Shelving local changes ")
+			}
+			shelf, serr = ginclient.Shelve(true)
+			CheckError(serr)
+			if prStyle == psDefault {
+				fmt.Fprintf(color.Output, "%s (%s)\n", green("OK"), shelf.ID)
+			}
+		}
+		os.Chdir(workingdir)
+	}
+
 	if prStyle == psDefault {
 		fmt.Print(":: Downloading changes ")
 	}
 	err = gincl.Download(remote)
+	if err != nil && shelf.ID != "" {
+		if git.MergeInProgress() {
+			// restoring the changes now would interfere with resolving the conflicts
+			Die(fmt.Sprintf("%s\nLocal changes were shelved (%s). Use 'gin unshelve' to restore them after resolving the conflicts.", err.Error(), shelf.ID))
+		}
+		os.Chdir(reporoot)
+		results, uerr := ginclient.Unshelve(shelf.ID)
+		printUnshelved(results, prStyle)
+		CheckErrorMsg(uerr, fmt.Sprintf("%s\nFailed to restore shelved local changes (%s). Use 'gin unshelve' to retry.", err.Error(), shelf.ID))
+	}
 	CheckError(err)
 	if prStyle == psDefault {
 		fmt.Fprintln(color.Output, green("OK"))
 	}
+	if shelf.ID != "" {
+		if prStyle == psDefault {
+			fmt.Println(":: Restoring shelved local changes")
+		}
+		os.Chdir(reporoot)
+		results, uerr := ginclient.Unshelve(shelf.ID)
+		nconflicts := printUnshelved(results, prStyle)
+		CheckErrorMsg(uerr, fmt.Sprintf("Failed to restore some shelved local changes (%s). Use 'gin unshelve' to retry.", shelf.ID))
+		if nconflicts > 0 && prStyle == psDefault {
+			fmt.Printf("%d shelved file(s) conflicted with downloaded changes and were restored under a new name.\n", nconflicts)
+		}
+	}
 	if content {
-		reporoot, _ := git.FindRepoRoot(".")
 		os.Chdir(reporoot)
 		getContent(cmd, nil)
 	}
@@ -43,9 +86,9 @@ func download(cmd *cobra.Command, args []string) {
 
 // DownloadCmd sets up the 'download' subcommand
 func DownloadCmd() *cobra.Command {
-	description := "Downloads changes from the remote repository to the local clone. This will create new files that were added remotely, delete files that were removed, and update files that were changed.\n\nOptionally downloads the content of all files in the repository. If 'content' is not specified, new files will be empty placeholders. Content of individual files can later be retrieved using the 'get-content' command."
+	description := "Downloads changes from the remote repository to the local clone. This will create new files that were added remotely, delete files that were removed, and update files that were changed.\n\nOptionally downloads the content of all files in the repository. If 'content' is not specified, new files will be empty placeholders. Content of individual files can later be retrieved using the 'get-content' command.\n\nIf local changes would be overwritten by the download, use --autostash to set aside all uncommitted changes before downloading and restore them afterwards (see also the 'shelve' and 'unshelve' commands)."
 	var cmd = &cobra.Command{
-		Use:                   "download [--json | --verbose] [--content]",
+		Use:                   "download [--json | --verbose] [--content] [--autostash]",
 		Short:                 "Download all new information from a remote repository",
 		Long:                  formatdesc(description, nil),
 		Args:                  cobra.NoArgs,
@@ -55,5 +98,6 @@ func DownloadCmd() *cobra.Command {
 	cmd.Flags().Bool("json", false, jsonHelpMsg)
 	// cmd.Flags().Bool("verbose", false, verboseHelpMsg)
 	cmd.Flags().Bool("content", false, "Download the content for all files in the repository.")
+	cmd.Flags().Bool("autostash", false, "Shelve uncommitted local changes (including untracked files) before downloading and restore them afterwards.")
 	return cmd
 }
@@ -0,0 +1,84 @@
+package gincmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	ginclient "github.com/G-Node/gin-cli/ginclient"
+	"github.com/G-Node/gin-cli/ginclient/config"
+	"github.com/G-Node/gin-cli/git"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// upstreamRemote is the name of the remote that is added for the source repository when a fork is cloned.
+const upstreamRemote = "upstream"
+
+func fork(cmd *cobra.Command, args []string) {
+	prStyle := determinePrintStyle(cmd)
+	flags := cmd.Flags()
+	srvalias, _ := flags.GetString("server")
+	org, _ := flags.GetString("org")
+	get, _ := flags.GetBool("get")
+	conf := config.Read()
+	if srvalias == "" {
+		srvalias = conf.DefaultServer
+	}
+	repostr := args[0]
+	if !isValidRepoPath(repostr) {
+		Die(fmt.Sprintf("Invalid repository path '%s'. Full repository name should be the owner's username followed by the repository name, separated by a '/'.\nType 'gin help fork' for information and examples.", repostr))
+	}
+	gincl := ginclient.New(srvalias)
+	requirelogin(cmd, gincl, prStyle != psJSON)
+
+	forkrepo, err := gincl.ForkRepo(repostr, org)
+	CheckError(err)
+	if !get {
+		if prStyle == psJSON {
+			j, _ := json.Marshal(forkrepo)
+			fmt.Println(string(j))
+			return
+		}
+		fmt.Fprintf(color.Output, "Repository %s forked to %s\n", repostr, green(forkrepo.FullName))
+		fmt.Printf("Use 'gin get %s' to retrieve a local copy.\n", forkrepo.FullName)
+		return
+	}
+	if prStyle != psJSON {
+		fmt.Fprintf(color.Output, "Repository %s forked to %s\n", repostr, green(forkrepo.FullName))
+	}
+
+	trustOnFirstUse(srvalias)
+	cloneRepo(gincl, forkrepo.FullName, prStyle)
+	upstream := fmt.Sprintf("%s/%s", gincl.GitAddress(), repostr)
+	CheckErrorMsg(git.RemoteAdd(upstreamRemote, upstream), fmt.Sprintf("failed to add remote '%s' for the source repository", upstreamRemote))
+	if prStyle != psJSON {
+		fmt.Printf(":: Added remote '%s' for the source repository %s\n", upstreamRemote, repostr)
+		fmt.Printf("To download changes from the source repository, run 'gin use-remote %s' and 'gin download'. Run 'gin use-remote origin' before uploading to the fork.\n", upstreamRemote)
+	}
+}
+
+// ForkCmd sets up the 'fork' repository subcommand
+func ForkCmd() *cobra.Command {
+	description := "Create a copy (fork) of a repository on the server. The fork is owned by the logged in user, or by an organisation that the user can create repositories in. The fork contains the full history of the source repository, but changes to it do not affect the source.\n\nWith --get, the fork is retrieved (cloned) immediately, like with 'gin get'. The fork is the default remote ('origin') of the clone and the source repository is added as the remote 'upstream'. Since 'gin download' downloads from the default remote, changes to the source are downloaded by switching the default remote with 'gin use-remote upstream' and running 'gin download'. Switch back with 'gin use-remote origin' before uploading changes to the fork.\n\nForking requires a server that supports creating forks through its API."
+	args := map[string]string{
+		"<repopath>": "The path of the repository to fork. A repository path is the owner's username, followed by a \"/\" and the repository name.",
+	}
+	examples := map[string]string{
+		"Fork the repository 'eegdata' owned by user 'peter'":                           "$ gin fork peter/eegdata",
+		"Fork the repository into the organisation 'mylab' and retrieve it immediately": "$ gin fork --org mylab --get peter/eegdata",
+	}
+	var cmd = &cobra.Command{
+		Use:                   "fork [--json] [--org <organisation>] [--get] <repopath>",
+		Short:                 "Fork a repository on the server",
+		Long:                  formatdesc(description, args),
+		Example:               formatexamples(examples),
+		Args:                  cobra.ExactArgs(1),
+		Run:                   fork,
+		DisableFlagsInUseLine: true,
+	}
+	cmd.Flags().String("org", "", "Create the fork in the `organisation` instead of the user's account.")
+	cmd.Flags().Bool("get", false, "Retrieve (clone) the fork after it is created.")
+	cmd.Flags().Bool("json", false, jsonHelpMsg)
+	cmd.Flags().String("server", "", "Specify server `alias` for the repository. See also 'gin servers'.")
+	return cmd
+}
@@ -29,6 +29,13 @@ func getRepo(cmd *cobra.Command, args []string) {
 		Die(fmt.Sprintf("Invalid repository path '%s'. Full repository name should be the owner's username followed by the repository name, separated by a '/'.\nType 'gin help get' for information and examples.", repostr))
 	}
 
+	trustOnFirstUse(srvalias)
+	cloneRepo(gincl, repostr, prStyle)
+}
+
+// cloneRepo clones the repository at repostr into a new directory, initialises it, and changes the working directory to the new clone.
+// If the repository is empty, an initial commit is created and pushed.
+func cloneRepo(gincl *ginclient.Client, repostr string, prStyle printstyle) {
 	clonechan := make(chan git.RepoFileStatus)
 	go gincl.CloneRepo(repostr, clonechan)
 	formatOutput(clonechan, prStyle, 0)
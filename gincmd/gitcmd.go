@@ -5,6 +5,7 @@ import (
 	"os"
 
 	ginclient "github.com/G-Node/gin-cli/ginclient"
+	"github.com/G-Node/gin-cli/ginclient/credentials"
 	"github.com/G-Node/gin-cli/git"
 	"github.com/spf13/cobra"
 )
@@ -25,6 +26,7 @@ func gitrun(cmd *cobra.Command, args []string) {
 		fmt.Fprint(os.Stderr, line)
 	}
 	if gitcmd.Wait() != nil {
+		credentials.Cleanup()
 		os.Exit(1)
 	}
 }
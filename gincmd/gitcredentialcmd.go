@@ -0,0 +1,95 @@
+package gincmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	ginclient "github.com/G-Node/gin-cli/ginclient"
+	"github.com/G-Node/gin-cli/ginclient/config"
+	"github.com/G-Node/gin-cli/git"
+	"github.com/spf13/cobra"
+)
+
+// readCredentialRequest reads the key=value attributes sent by git to a
+// credential helper. The request ends with a blank line or at end of input.
+func readCredentialRequest() map[string]string {
+	request := make(map[string]string)
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			break
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) == 2 {
+			request[parts[0]] = parts[1]
+		}
+	}
+	return request
+}
+
+// defaultPorts are the ports used for URLs without a port, by protocol.
+var defaultPorts = map[string]uint16{"http": 80, "https": 443}
+
+// credentialServer returns the alias of the server that uses HTTPS for git
+// operations and matches the protocol and host of a credential request.
+// If several servers match, the first alias in sorted order is returned.
+func credentialServer(request map[string]string) (string, bool) {
+	servers := config.Read().Servers
+	aliases := make([]string, 0, len(servers))
+	for alias := range servers {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	for _, alias := range aliases {
+		srvcfg := servers[alias]
+		if !srvcfg.Git.UseHTTPS() || srvcfg.Web.Protocol != request["protocol"] {
+			continue
+		}
+		// git includes the port in the host attribute if the URL specifies one;
+		// without a port, the request is for the default port of the protocol
+		host := request["host"]
+		if host == fmt.Sprintf("%s:%d", srvcfg.Web.Host, srvcfg.Web.Port) || (host == srvcfg.Web.Host && srvcfg.Web.Port == defaultPorts[srvcfg.Web.Protocol]) {
+			return alias, true
+		}
+	}
+	return "", false
+}
+
+func gitCredential(cmd *cobra.Command, args []string) {
+	request := readCredentialRequest()
+	// Only 'get' is supported: the token is managed by 'gin login' and 'gin logout', so 'store' and 'erase' are ignored.
+	if args[0] != "get" {
+		return
+	}
+	alias, ok := credentialServer(request)
+	if !ok {
+		return
+	}
+	gincl := ginclient.New(alias)
+	if err := gincl.LoadToken(); err != nil {
+		fmt.Fprintf(os.Stderr, "gin: not logged in to server '%s'; use 'gin login' to log in\n", alias)
+		return
+	}
+	// The server accepts an access token in place of the username (Gogs) or the password (Gitea), so it is provided as both.
+	fmt.Printf("username=%s\n", gincl.Token)
+	fmt.Printf("password=%s\n", gincl.Token)
+}
+
+// GitCredentialCmd sets up the (unlisted) git credential helper subcommand,
+// which provides the login token to git for servers that use HTTPS for git operations.
+func GitCredentialCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:                   git.CredentialHelperCmd + " <get|store|erase>",
+		Short:                 "Git credential helper for servers that use HTTPS for git operations",
+		Long:                  "",
+		Args:                  cobra.ExactArgs(1),
+		Run:                   gitCredential,
+		DisableFlagsInUseLine: true,
+		Hidden:                true,
+	}
+	return cmd
+}
@@ -0,0 +1,90 @@
+package gincmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/G-Node/gin-cli/ginclient/config"
+)
+
+func TestCredentialServer(t *testing.T) {
+	confdir := t.TempDir()
+	os.Setenv("GIN_CONFIG_DIR", confdir)
+	os.Setenv("GIN_SYSTEM_CONFIG", filepath.Join(confdir, "system.yml"))
+	cwd, _ := os.Getwd()
+	os.Chdir(confdir)
+	defer func() {
+		os.Unsetenv("GIN_CONFIG_DIR")
+		os.Unsetenv("GIN_SYSTEM_CONFIG")
+		os.Chdir(cwd)
+	}()
+	servers := map[string]config.ServerCfg{
+		"web": {
+			Web: config.WebCfg{Protocol: "https", Host: "gin.example.org", Port: 443},
+			Git: config.GitCfg{Protocol: config.GitProtocolHTTPS},
+		},
+		"lab": {
+			Web: config.WebCfg{Protocol: "https", Host: "lab.example.org", Port: 3000},
+			Git: config.GitCfg{Protocol: config.GitProtocolHTTPS},
+		},
+		"plain": {
+			Web: config.WebCfg{Protocol: "http", Host: "gin.example.org", Port: 8080},
+			Git: config.GitCfg{Protocol: config.GitProtocolHTTPS},
+		},
+		"local": {
+			Web: config.WebCfg{Protocol: "http", Host: "localhost", Port: 80},
+			Git: config.GitCfg{Protocol: config.GitProtocolHTTPS},
+		},
+		// git over SSH: never provides credentials, even for the web address of an HTTPS server
+		"ssh": {
+			Web: config.WebCfg{Protocol: "https", Host: "ssh.example.org", Port: 443},
+			Git: config.GitCfg{Protocol: config.GitProtocolSSH, User: "git", Host: "ssh.example.org", Port: 22},
+		},
+	}
+	if err := config.SetConfig("servers", servers); err != nil {
+		t.Fatalf("Failed to write server configuration: %v", err)
+	}
+
+	tests := []struct {
+		protocol string
+		host     string
+		alias    string
+	}{
+		{"https", "gin.example.org", "web"},
+		{"https", "gin.example.org:443", "web"},
+		{"https", "lab.example.org:3000", "lab"},
+		{"https", "lab.example.org", ""},
+		{"https", "lab.example.org:3001", ""},
+		{"http", "gin.example.org:8080", "plain"},
+		{"http", "gin.example.org", ""},
+		{"http", "localhost", "local"},
+		{"http", "localhost:80", "local"},
+		{"https", "localhost", ""},
+		{"http", "lab.example.org:3000", ""},
+		{"https", "ssh.example.org", ""},
+		{"ssh", "ssh.example.org", ""},
+		{"https", "other.example.org", ""},
+		{"", "", ""},
+	}
+	for _, test := range tests {
+		alias, ok := credentialServer(map[string]string{"protocol": test.protocol, "host": test.host})
+		if alias != test.alias || ok != (test.alias != "") {
+			t.Errorf("credentialServer(%s, %s) = (%q, %v); expected %q", test.protocol, test.host, alias, ok, test.alias)
+		}
+	}
+}
+
+func TestParseGitstring(t *testing.T) {
+	tests := map[string]config.GitCfg{
+		"https":                   {Protocol: config.GitProtocolHTTPS},
+		"git@gin.example.org:22":  {Protocol: config.GitProtocolSSH, User: "git", Host: "gin.example.org", Port: 22},
+		"user@10.0.0.1:2222":      {Protocol: config.GitProtocolSSH, User: "user", Host: "10.0.0.1", Port: 2222},
+		"git@https.example.org:1": {Protocol: config.GitProtocolSSH, User: "git", Host: "https.example.org", Port: 1},
+	}
+	for gitstring, expected := range tests {
+		if gitconf := parseGitstring(gitstring); gitconf != expected {
+			t.Errorf("parseGitstring(%s) = %+v; expected %+v", gitstring, gitconf, expected)
+		}
+	}
+}
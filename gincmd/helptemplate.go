@@ -2,15 +2,15 @@ package gincmd
 
 import (
 	"github.com/docker/docker/pkg/term"
-	cobra "github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
-func wrappedFlagUsages(cmd *cobra.Command) string {
+func wrappedFlagUsages(flags *pflag.FlagSet) string {
 	width := 80
 	if ws, err := term.GetWinsize(0); err == nil {
 		width = int(ws.Width)
 	}
-	return cmd.Flags().FlagUsagesWrapped(width - 1)
+	return flags.FlagUsagesWrapped(width - 1)
 }
 
 var helpTemplate = `{{.UsageString}}`
@@ -29,11 +29,11 @@ Available Commands:{{range .Commands}}{{if (or .IsAvailableCommand (eq .Name "he
 
 Flags:
 
-{{ wrappedFlagUsages . | trimTrailingWhitespaces}}{{end}}{{if .HasAvailableInheritedFlags}}
+{{ wrappedFlagUsages .LocalFlags | trimTrailingWhitespaces}}{{end}}{{if .HasAvailableInheritedFlags}}
 
 Global Flags:
 
-{{.InheritedFlags.FlagUsages | trimTrailingWhitespaces}}{{end}}{{if .HasHelpSubCommands}}
+{{ wrappedFlagUsages .InheritedFlags | trimTrailingWhitespaces}}{{end}}{{if .HasHelpSubCommands}}
 
 Additional help topics:{{range .Commands}}{{if .IsAdditionalHelpTopicCommand}}
   {{rpad .CommandPath .CommandPathPadding}} {{.Short}}{{end}}{{end}}{{end}}{{if .HasExample}}
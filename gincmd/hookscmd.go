@@ -0,0 +1,188 @@
+package gincmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	ginclient "github.com/G-Node/gin-cli/ginclient"
+	"github.com/G-Node/gin-cli/ginclient/config"
+	"github.com/fatih/color"
+	gogs "github.com/gogits/go-gogs-client"
+	"github.com/spf13/cobra"
+)
+
+// hookInfo is the JSON output for a webhook. The secret is never printed.
+type hookInfo struct {
+	ID          int64    `json:"id"`
+	URL         string   `json:"url"`
+	ContentType string   `json:"content_type"`
+	Events      []string `json:"events"`
+	Active      bool     `json:"active"`
+}
+
+func newHookInfo(hook gogs.Hook) hookInfo {
+	return hookInfo{ID: hook.ID, URL: hook.Config["url"], ContentType: hook.Config["content_type"], Events: hook.Events, Active: hook.Active}
+}
+
+func printHookList(repopath string, hooks []gogs.Hook) {
+	if len(hooks) == 0 {
+		fmt.Printf("Repository %s has no webhooks\n", repopath)
+		return
+	}
+	fmt.Printf("Webhooks of repository %s:\n\n", repopath)
+	for _, hook := range hooks {
+		info := newHookInfo(hook)
+		status := green("active")
+		if !info.Active {
+			status = yellow("inactive")
+		}
+		fmt.Fprintf(color.Output, "[%d] %s (%s)\n", info.ID, info.URL, status)
+		fmt.Printf("\tEvents: %s\n", strings.Join(info.Events, ", "))
+		fmt.Printf("\tContent type: %s\n", info.ContentType)
+	}
+}
+
+// validHookEvent returns true if the event is one of the events that can trigger webhooks.
+func validHookEvent(event string) bool {
+	for _, valid := range ginclient.HookEvents {
+		if event == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// readSecretStdin reads the webhook secret from the first line of stdin.
+func readSecretStdin() string {
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		Die(fmt.Sprintf("failed to read secret from stdin: %s", err))
+	}
+	return strings.TrimSpace(line)
+}
+
+func hooks(cmd *cobra.Command, args []string) {
+	prStyle := determinePrintStyle(cmd)
+	flags := cmd.Flags()
+	srvalias, _ := flags.GetString("server")
+	addurl, _ := flags.GetString("add")
+	delid, _ := flags.GetInt64("delete")
+	testid, _ := flags.GetInt64("test")
+	secret, _ := flags.GetString("secret")
+	events, _ := flags.GetStringSlice("event")
+	contenttype, _ := flags.GetString("content-type")
+	inactive, _ := flags.GetBool("inactive")
+
+	nactions := 0
+	for _, action := range []string{"add", "delete", "test"} {
+		if flags.Changed(action) {
+			nactions++
+		}
+	}
+	if nactions > 1 {
+		usageDie(cmd)
+	}
+	for _, opt := range []string{"secret", "event", "content-type", "inactive"} {
+		if flags.Changed(opt) && !flags.Changed("add") {
+			Die(fmt.Sprintf("--%s can only be used with --add", opt))
+		}
+	}
+	repopath := args[0]
+	if !isValidRepoPath(repopath) {
+		Die(fmt.Sprintf("Invalid repository path '%s'. Full repository name should be the owner's username followed by the repository name, separated by a '/'.\nType 'gin help hooks' for information and examples.", repopath))
+	}
+	for _, event := range events {
+		if !validHookEvent(event) {
+			Die(fmt.Sprintf("invalid event '%s': must be one of %s", event, strings.Join(ginclient.HookEvents, ", ")))
+		}
+	}
+	if secret == "-" {
+		secret = readSecretStdin()
+	}
+
+	conf := config.Read()
+	if srvalias == "" {
+		srvalias = conf.DefaultServer
+	}
+	gincl := ginclient.New(srvalias)
+	requirelogin(cmd, gincl, prStyle != psJSON)
+
+	switch {
+	case flags.Changed("add"):
+		opts := ginclient.HookOptions{URL: addurl, ContentType: contenttype, Secret: secret, Events: events, Inactive: inactive}
+		hook, err := gincl.CreateHook(repopath, opts)
+		CheckError(err)
+		if prStyle == psJSON {
+			j, _ := json.Marshal(newHookInfo(hook))
+			fmt.Println(string(j))
+			return
+		}
+		fmt.Fprintf(color.Output, ":: Webhook [%d] for %s added %s\n", hook.ID, addurl, green("OK"))
+	case flags.Changed("delete"):
+		CheckError(gincl.DeleteHook(repopath, delid))
+		if prStyle == psJSON {
+			j, _ := json.Marshal(hookInfo{ID: delid})
+			fmt.Println(string(j))
+			return
+		}
+		fmt.Fprintf(color.Output, ":: Webhook [%d] deleted %s\n", delid, green("OK"))
+	case flags.Changed("test"):
+		CheckError(gincl.TestHook(repopath, testid))
+		if prStyle == psJSON {
+			j, _ := json.Marshal(hookInfo{ID: testid})
+			fmt.Println(string(j))
+			return
+		}
+		fmt.Fprintf(color.Output, ":: Test delivery of webhook [%d] triggered %s\n", testid, green("OK"))
+	default:
+		hooklist, err := gincl.ListHooks(repopath)
+		CheckError(err)
+		if prStyle == psJSON {
+			infos := make([]hookInfo, len(hooklist))
+			for idx, hook := range hooklist {
+				infos[idx] = newHookInfo(hook)
+			}
+			j, _ := json.Marshal(infos)
+			fmt.Println(string(j))
+			return
+		}
+		printHookList(repopath, hooklist)
+	}
+}
+
+// HooksCmd sets up the 'hooks' subcommand
+func HooksCmd() *cobra.Command {
+	description := fmt.Sprintf("List, add, delete, or test the webhooks of a repository. A webhook makes the server send a request (the payload) to a given URL when an event occurs in the repository, for instance to start a validation pipeline when new data is uploaded. Managing webhooks requires administrator access to the repository.\n\nWhen adding a webhook, the events that trigger it are selected with --event, which can be specified multiple times. By default, the webhook is triggered by uploads (push). The available events are: %s.\n\nIf a secret is specified, the server uses it to sign each payload, so that the receiver can verify that the request was sent by the server. Use '--secret -' to read the secret from standard input instead of the command line. Secrets are never printed.\n\nWebhooks are referred to by the number (ID) shown in the list. Delivering test payloads (--test) requires a server that supports it.", strings.Join(ginclient.HookEvents, ", "))
+	args := map[string]string{
+		"<repopath>": "The path of the repository. A repository path is the owner's username, followed by a \"/\" and the repository name.",
+	}
+	examples := map[string]string{
+		"List the webhooks of the repository 'ephys' owned by user 'alice'":        "$ gin hooks alice/ephys",
+		"Trigger a validation pipeline when data is uploaded, signing the payload": "$ gin hooks --add https://ci.example.com/validate --secret - alice/ephys",
+		"Add a webhook for new releases and issues":                                "$ gin hooks --add https://example.com/notify --event release --event issues alice/ephys",
+		"Deliver a test payload to webhook 3":                                      "$ gin hooks --test 3 alice/ephys",
+	}
+	var cmd = &cobra.Command{
+		Use:                   "hooks [--json] [--add <url> [--secret <secret>] [--event <event>]... [--content-type <type>] [--inactive] | --delete <id> | --test <id>] <repopath>",
+		Short:                 "Manage the webhooks of a repository",
+		Long:                  formatdesc(description, args),
+		Example:               formatexamples(examples),
+		Args:                  cobra.ExactArgs(1),
+		Run:                   hooks,
+		DisableFlagsInUseLine: true,
+	}
+	cmd.Flags().Bool("json", false, jsonHelpMsg)
+	cmd.Flags().String("add", "", "Add a webhook that delivers payloads to `url`.")
+	cmd.Flags().String("secret", "", "Sign the payloads of the new webhook with `secret` ('-' to read it from standard input).")
+	cmd.Flags().StringSlice("event", nil, "Trigger the new webhook on `event` (can be specified multiple times).")
+	cmd.Flags().String("content-type", ginclient.HookContentJSON, fmt.Sprintf("Encode the payloads of the new webhook as `type` (%s or %s).", ginclient.HookContentJSON, ginclient.HookContentForm))
+	cmd.Flags().Bool("inactive", false, "Add the webhook disabled.")
+	cmd.Flags().Int64("delete", 0, "Delete the webhook with the given `id`.")
+	cmd.Flags().Int64("test", 0, "Deliver a test payload to the webhook with the given `id`.")
+	cmd.Flags().String("server", "", "Specify server `alias` for the repository. See also 'gin servers'.")
+	return cmd
+}
@@ -0,0 +1,195 @@
+package gincmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/G-Node/gin-cli/ginclient/config"
+	"github.com/G-Node/gin-cli/git"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+type hostKeyEntry struct {
+	Alias   string `json:"alias"`
+	Address string `json:"address"`
+	git.HostKeyInfo
+}
+
+func listHostKeys(prStyle printstyle) {
+	servers := config.Read().Servers
+	aliases := make([]string, 0, len(servers))
+	for alias := range servers {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+
+	entries := make([]hostKeyEntry, 0, len(aliases))
+	for _, alias := range aliases {
+		gitconf := servers[alias].Git
+		entry := hostKeyEntry{Alias: alias, Address: servers[alias].GitAddressStr()}
+		if gitconf.UseHTTPS() {
+			entry.Type = "https"
+		} else if gitconf.HostKey != "" {
+			info, err := git.ParseHostKey(gitconf.HostKey)
+			if err != nil {
+				info.Type = "invalid"
+			}
+			entry.HostKeyInfo = info
+		}
+		entries = append(entries, entry)
+	}
+
+	if prStyle == psJSON {
+		j, _ := json.Marshal(entries)
+		fmt.Println(string(j))
+		return
+	}
+	fmt.Println(":: Configured host keys")
+	for _, entry := range entries {
+		fmt.Printf("* %s\n", entry.Alias)
+		fmt.Printf("  git: %s\n", entry.Address)
+		switch entry.Type {
+		case "":
+			fmt.Fprintf(color.Output, "  key: %s\n\n", yellow("not configured"))
+		case "https":
+			fmt.Printf("  key: not used (HTTPS)\n\n")
+		case "invalid":
+			fmt.Fprintf(color.Output, "  key: %s\n\n", red("invalid"))
+		default:
+			fmt.Printf("  key: %s %s\n\n", entry.Type, entry.Fingerprint)
+		}
+	}
+}
+
+// confirmTrust asks the user to confirm trusting a new host key.
+func confirmTrust() bool {
+	fmt.Print("Trust the presented key? [yes/no]: ")
+	var response string
+	fmt.Scanln(&response)
+	for {
+		switch strings.ToLower(response) {
+		case "no":
+			return false
+		case "yes":
+			return true
+		default:
+			fmt.Print("Please type 'yes' or 'no': ")
+			fmt.Scanln(&response)
+		}
+	}
+}
+
+func refreshHostKey(alias string, yes bool) {
+	srvcfg, ok := config.Read().Servers[alias]
+	if !ok {
+		Die(fmt.Sprintf("unknown server alias '%s'", alias))
+	}
+	if srvcfg.Git.UseHTTPS() {
+		Die(fmt.Sprintf("server '%s' uses HTTPS for git operations and has no host key", alias))
+	}
+	hostkeystr, fingerprint, err := git.PresentedHostKey(srvcfg.Git)
+	CheckError(err)
+	if hostkeystr == "" {
+		Die(fmt.Sprintf("server '%s' did not present a host key", alias))
+	}
+	current, cerr := git.ParseHostKey(srvcfg.Git.HostKey)
+	if cerr == nil && current.Fingerprint == fingerprint {
+		fmt.Printf(":: Host key for '%s' [%s] is unchanged: %s\n", alias, srvcfg.Git.AddressStr(), fingerprint)
+		// rewrite known_hosts in case it is out of date
+		CheckError(git.WriteKnownHosts())
+		return
+	}
+
+	fmt.Printf(":: Host key for '%s' [%s]\n", alias, srvcfg.Git.AddressStr())
+	if cerr == nil {
+		fmt.Printf("  configured: %s %s\n", current.Type, current.Fingerprint)
+	} else {
+		fmt.Println("  configured: none")
+	}
+	fmt.Fprintf(color.Output, "  presented:  %s\n", yellow(fingerprint))
+	if !yes && !confirmTrust() {
+		Exit("Aborted")
+	}
+	CheckError(git.TrustHostKey(alias, hostkeystr, "refresh"))
+	fmt.Fprintf(color.Output, ":: New host key for '%s' trusted %s\n", alias, green("OK"))
+}
+
+func pinHostKey(alias, fingerprint string) {
+	srvcfg, ok := config.Read().Servers[alias]
+	if !ok {
+		Die(fmt.Sprintf("unknown server alias '%s'", alias))
+	}
+	if srvcfg.Git.UseHTTPS() {
+		Die(fmt.Sprintf("server '%s' uses HTTPS for git operations and has no host key", alias))
+	}
+	hostkeystr, err := git.FindHostKey(srvcfg.Git, fingerprint)
+	CheckError(err)
+	CheckError(git.TrustHostKey(alias, hostkeystr, "pin"))
+	fmt.Fprintf(color.Output, ":: Host key %s pinned for '%s' %s\n", fingerprint, alias, green("OK"))
+}
+
+func hostkeys(cmd *cobra.Command, args []string) {
+	prStyle := determinePrintStyle(cmd)
+	flags := cmd.Flags()
+	refresh, _ := flags.GetBool("refresh")
+	pin, _ := flags.GetString("pin")
+	yes, _ := flags.GetBool("yes")
+
+	if refresh && pin != "" {
+		Die("--refresh and --pin can't be used at the same time")
+	}
+	if !refresh && pin == "" {
+		if len(args) > 0 {
+			usageDie(cmd)
+		}
+		listHostKeys(prStyle)
+		return
+	}
+	if prStyle == psJSON {
+		usageDie(cmd)
+	}
+
+	if len(args) == 0 {
+		args = []string{config.Read().DefaultServer}
+	}
+	if pin != "" {
+		if len(args) > 1 {
+			Die("--pin requires a single server alias")
+		}
+		pinHostKey(args[0], pin)
+		return
+	}
+	for _, alias := range args {
+		refreshHostKey(alias, yes)
+	}
+}
+
+// HostKeysCmd sets up the 'hostkeys' subcommand
+func HostKeysCmd() *cobra.Command {
+	description := "List, refresh, or pin the host keys of the configured git servers. The host key identifies the server and is checked on every connection to protect against connecting to a server impersonating the real one.\n\nWithout flags, the configured host key and its fingerprint are listed for each server.\n\nWith --refresh, the key presented by the server is retrieved and compared with the configured key. If it differs, both fingerprints are shown and the new key is only trusted after confirmation. Only trust a new key if you can verify its fingerprint with the server administrators.\n\nWith --pin, the key presented by the server is trusted only if it matches the given fingerprint (as published by the server administrators). No confirmation is requested.\n\nIf a server has no host key configured, the key presented by the server is trusted on 'gin login' and 'gin get' (trust-on-first-use) and its fingerprint is printed with a warning. Other commands do not connect to the server to retrieve its key: they fail until a key is trusted with --refresh.\n\nAll host key changes are recorded in the file 'hostkeys.log' in the configuration directory."
+	args := map[string]string{
+		"<alias>": "The alias of the server. Defaults to the default server when refreshing or pinning keys.",
+	}
+	examples := map[string]string{
+		"Show the host keys of all servers":                 "$ gin hostkeys",
+		"Check and update the host key of the 'gin' server": "$ gin hostkeys --refresh gin",
+		"Trust a key with a known fingerprint":              "$ gin hostkeys --pin SHA256:abc...xyz gin",
+	}
+	var cmd = &cobra.Command{
+		Use:                   "hostkeys [--json] [--refresh [--yes] | --pin <fingerprint>] [<alias>]...",
+		Short:                 "List, refresh, or pin the host keys of the configured servers",
+		Long:                  formatdesc(description, args),
+		Example:               formatexamples(examples),
+		Args:                  cobra.ArbitraryArgs,
+		Run:                   hostkeys,
+		DisableFlagsInUseLine: true,
+	}
+	cmd.Flags().Bool("json", false, jsonHelpMsg)
+	cmd.Flags().Bool("refresh", false, "Retrieve the host key presented by the server and trust it after confirmation if it changed.")
+	cmd.Flags().String("pin", "", "Trust the key presented by the server only if it matches the given `fingerprint`.")
+	cmd.Flags().BoolP("yes", "y", false, "Do not ask for confirmation when refreshing host keys.")
+	return cmd
+}
@@ -2,10 +2,14 @@ package gincmd
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"strings"
 
 	ginclient "github.com/G-Node/gin-cli/ginclient"
 	"github.com/G-Node/gin-cli/ginclient/config"
+	humanize "github.com/dustin/go-humanize"
+	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 )
 
@@ -14,6 +18,7 @@ func printAccountInfo(cmd *cobra.Command, args []string) {
 
 	flags := cmd.Flags()
 	srvalias, _ := flags.GetString("server")
+	jsonout := determinePrintStyle(cmd) == psJSON
 
 	conf := config.Read()
 	if srvalias == "" {
@@ -22,6 +27,11 @@ func printAccountInfo(cmd *cobra.Command, args []string) {
 	gincl := ginclient.New(srvalias)
 	gincl.LoadToken() // does not REQUIRE login
 
+	if flags.Changed("full-name") || flags.Changed("email") {
+		editProfile(cmd, gincl, args)
+		return
+	}
+
 	if len(args) == 0 {
 		username = gincl.Username
 	} else {
@@ -29,38 +39,92 @@ func printAccountInfo(cmd *cobra.Command, args []string) {
 	}
 
 	if username == "" {
+		if jsonout {
+			Die("no username specified and not logged in")
+		}
 		// prompt for username
 		fmt.Print("Specify username for info lookup: ")
 		username = ""
 		fmt.Scanln(&username)
 	}
 
-	info, err := gincl.RequestAccount(username)
+	info, err := gincl.GetAccountDetails(username)
 	CheckError(err)
 
+	if jsonout {
+		j, _ := json.Marshal(info)
+		fmt.Println(string(j))
+		return
+	}
+
 	var outBuffer bytes.Buffer
 	_, _ = outBuffer.WriteString(fmt.Sprintf("User %s\nName: %s\n", info.UserName, info.FullName))
 	if info.Email != "" {
 		_, _ = outBuffer.WriteString(fmt.Sprintf("Email: %s\n", info.Email))
 	}
+	if info.Repositories >= 0 {
+		_, _ = outBuffer.WriteString(fmt.Sprintf("Repositories: %d\n", info.Repositories))
+		_, _ = outBuffer.WriteString(fmt.Sprintf("Storage used: %s\n", humanize.IBytes(uint64(info.StorageUsed))))
+	}
+	if len(info.Organisations) > 0 {
+		_, _ = outBuffer.WriteString(fmt.Sprintf("Organisations: %s\n", strings.Join(info.Organisations, ", ")))
+	} else if info.Organisations != nil {
+		_, _ = outBuffer.WriteString("Organisations: none\n")
+	}
+	if info.Keys >= 0 {
+		_, _ = outBuffer.WriteString(fmt.Sprintf("Keys: %d\n", info.Keys))
+	}
 
 	fmt.Println(outBuffer.String())
 }
 
+// editProfile changes the profile of the logged in user with the values of the --full-name and --email flags.
+func editProfile(cmd *cobra.Command, gincl *ginclient.Client, args []string) {
+	flags := cmd.Flags()
+	jsonout := determinePrintStyle(cmd) == psJSON
+	if gincl.Username == "" {
+		Die("You are not logged in.")
+	}
+	if len(args) > 0 && args[0] != gincl.Username {
+		Die("Only the profile of the logged in user can be changed.")
+	}
+	fullname, _ := flags.GetString("full-name")
+	email, _ := flags.GetString("email")
+	if flags.Changed("email") && !strings.Contains(email, "@") {
+		Die(fmt.Sprintf("invalid email address '%s'", email))
+	}
+	user, err := gincl.EditProfile(ginclient.ProfileOptions{FullName: fullname, Email: email})
+	CheckError(err)
+	if jsonout {
+		j, _ := json.Marshal(user)
+		fmt.Println(string(j))
+		return
+	}
+	fmt.Fprintf(color.Output, ":: Profile of %s updated %s\n", gincl.Username, green("OK"))
+}
+
 // InfoCmd sets up the  user 'info' subcommand
 func InfoCmd() *cobra.Command {
-	description := "Print user information. If no argument is provided, it will print the information of the currently logged in user. Using this command with no argument can also be used to check if a user is currently logged in."
+	description := "Print user information. If no argument is provided, it will print the information of the currently logged in user. Using this command with no argument can also be used to check if a user is currently logged in.\n\nBesides the profile, the information includes the number of repositories owned by the user and the storage they use, the organisations the user is a member of, and the number of public keys. For other users, only repositories and organisations visible to the logged in user are counted. Parts of the summary that can not be retrieved (e.g., when not logged in) are omitted.\n\nThe full name and email address of the logged in user can be changed with --full-name and --email."
 	args := map[string]string{
 		"<username>": "The name of the user whose information should be printed. This can be the username of the currently logged in user (default), in which case the command will print all the profile information with indicators for which data is publicly visible. If it is the username of a different user, only the publicly visible information is printed.",
 	}
+	examples := map[string]string{
+		"Look up the email address of user 'alice' in a script": "$ gin info --json alice",
+		"Change your full name":                                 "$ gin info --full-name \"Alice Smith\"",
+	}
 	var cmd = &cobra.Command{
-		Use:                   "info [username]",
+		Use:                   "info [--json] [--full-name <name>] [--email <address>] [username]",
 		Short:                 "Print a user's information",
 		Long:                  formatdesc(description, args),
+		Example:               formatexamples(examples),
 		Args:                  cobra.MaximumNArgs(1),
 		Run:                   printAccountInfo,
 		DisableFlagsInUseLine: true,
 	}
+	cmd.Flags().Bool("json", false, jsonHelpMsg)
+	cmd.Flags().String("full-name", "", "Change the full name of the logged in user to `name`.")
+	cmd.Flags().String("email", "", "Change the email address of the logged in user to `address`.")
 	cmd.Flags().String("server", "", "Specify server `alias` for info lookup. See also 'gin servers'.")
 	return cmd
 }
@@ -0,0 +1,340 @@
+package gincmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+
+	ginclient "github.com/G-Node/gin-cli/ginclient"
+	"github.com/G-Node/gin-cli/ginclient/config"
+	"github.com/G-Node/gin-cli/git"
+	"github.com/docker/docker/pkg/term"
+	"github.com/fatih/color"
+	gogs "github.com/gogits/go-gogs-client"
+	"github.com/spf13/cobra"
+)
+
+// editorCommand returns the editor configured for git (core.editor, GIT_EDITOR, VISUAL, or EDITOR).
+func editorCommand() string {
+	gitcmd := git.Command("var", "GIT_EDITOR")
+	stdout, _, err := gitcmd.OutputError()
+	if editor := strings.TrimSpace(string(stdout)); err == nil && editor != "" {
+		return editor
+	}
+	for _, envvar := range []string{"VISUAL", "EDITOR"} {
+		if editor := os.Getenv(envvar); editor != "" {
+			return editor
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return "notepad"
+	}
+	return "vi"
+}
+
+// editMessage opens the editor with the given instructions (as comment lines) and returns the text entered by the user.
+// Only the instruction lines are removed, so lines starting with '#' (e.g., Markdown headings) are kept in the message.
+func editMessage(instructions string) string {
+	if !term.IsTerminal(os.Stdin.Fd()) {
+		Die("no message specified: use --message or run the command in a terminal to write the message in an editor")
+	}
+	tmpfile, err := ioutil.TempFile("", "gin-message-*.md")
+	CheckErrorMsg(err, "failed to create temporary file for message")
+	defer os.Remove(tmpfile.Name())
+	// the instructions are written as comment lines, which are removed from the message after editing
+	commentlines := make(map[string]bool)
+	var template strings.Builder
+	template.WriteString("\n")
+	for _, line := range strings.Split(instructions, "\n") {
+		line = strings.TrimSpace("# " + line)
+		commentlines[line] = true
+		template.WriteString(line + "\n")
+	}
+	tmpfile.WriteString(template.String())
+	tmpfile.Close()
+
+	editor := editorCommand()
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		args := strings.Fields(editor)
+		cmd = exec.Command(args[0], append(args[1:], tmpfile.Name())...)
+	} else {
+		// run through the shell like git, since the editor may include arguments
+		cmd = exec.Command("sh", "-c", editor+` "$@"`, editor, tmpfile.Name())
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err = cmd.Run(); err != nil {
+		Die(fmt.Sprintf("editor '%s' failed: %s", editor, err))
+	}
+	content, err := ioutil.ReadFile(tmpfile.Name())
+	CheckErrorMsg(err, "failed to read message")
+	var lines []string
+	scanner := bufio.NewScanner(strings.NewReader(string(content)))
+	for scanner.Scan() {
+		if line := strings.TrimRight(scanner.Text(), " \t\r"); !commentlines[line] {
+			lines = append(lines, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// issueMessage returns the message given with --message, or the message written in the editor if the flag is not set.
+// The references to the files given with --file are appended to the message.
+func issueMessage(cmd *cobra.Command, gincl *ginclient.Client, repopath, instructions string, required bool) string {
+	flags := cmd.Flags()
+	message, _ := flags.GetString("message")
+	if !flags.Changed("message") && required {
+		if determinePrintStyle(cmd) == psJSON {
+			Die("--message is required with --json")
+		}
+		message = editMessage(instructions)
+		if message == "" {
+			Die("Aborting due to empty message.")
+		}
+	}
+	filerefs := resolveFileRefs(cmd)
+	if refstext := gincl.FileRefsText(repopath, filerefs); refstext != "" {
+		if message != "" {
+			message += "\n\n"
+		}
+		message += refstext
+	}
+	return message
+}
+
+// resolveFileRefs resolves the file references given with --file.
+func resolveFileRefs(cmd *cobra.Command) []ginclient.FileRef {
+	files, _ := cmd.Flags().GetStringSlice("file")
+	var refs []ginclient.FileRef
+	for _, arg := range files {
+		ref, err := ginclient.ResolveFileRef(arg)
+		CheckError(err)
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+// issueClient validates the repository path and returns a logged in client for the server selected with --server.
+func issueClient(cmd *cobra.Command, repopath string) *ginclient.Client {
+	if !isValidRepoPath(repopath) {
+		Die(fmt.Sprintf("Invalid repository path '%s'. Full repository name should be the owner's username followed by the repository name, separated by a '/'.\nType 'gin help issues' for information and examples.", repopath))
+	}
+	srvalias, _ := cmd.Flags().GetString("server")
+	if srvalias == "" {
+		srvalias = config.Read().DefaultServer
+	}
+	gincl := ginclient.New(srvalias)
+	requirelogin(cmd, gincl, determinePrintStyle(cmd) != psJSON)
+	return gincl
+}
+
+// parseIssueNumber parses an issue number argument, with or without a leading '#'.
+func parseIssueNumber(arg string) int64 {
+	number, err := strconv.ParseInt(strings.TrimPrefix(arg, "#"), 10, 64)
+	if err != nil || number <= 0 {
+		Die(fmt.Sprintf("invalid issue number '%s'", arg))
+	}
+	return number
+}
+
+func printIssue(issue gogs.Issue) {
+	state := green(string(issue.State))
+	if issue.State == gogs.STATE_CLOSED {
+		state = red(string(issue.State))
+	}
+	fmt.Fprintf(color.Output, "#%d %s [%s]\n", issue.Index, issue.Title, state)
+	var poster string
+	if issue.Poster != nil {
+		poster = issue.Poster.UserName
+	}
+	fmt.Printf("\tOpened by %s on %s, %d comment(s)\n", poster, issue.Created.Format("2006-01-02"), issue.Comments)
+}
+
+func issuesList(cmd *cobra.Command, args []string) {
+	prStyle := determinePrintStyle(cmd)
+	closed, _ := cmd.Flags().GetBool("closed")
+	repopath := args[0]
+	gincl := issueClient(cmd, repopath)
+	issues, err := gincl.ListIssues(repopath, closed)
+	CheckError(err)
+	if prStyle == psJSON {
+		if issues == nil {
+			issues = []gogs.Issue{}
+		}
+		j, _ := json.Marshal(issues)
+		fmt.Println(string(j))
+		return
+	}
+	if len(issues) == 0 {
+		state := "open"
+		if closed {
+			state = "closed"
+		}
+		fmt.Printf("Repository %s has no %s issues\n", repopath, state)
+		return
+	}
+	for _, issue := range issues {
+		printIssue(issue)
+	}
+}
+
+func issuesCreate(cmd *cobra.Command, args []string) {
+	prStyle := determinePrintStyle(cmd)
+	flags := cmd.Flags()
+	title, _ := flags.GetString("title")
+	repopath := args[0]
+	gincl := issueClient(cmd, repopath)
+
+	var body string
+	if title == "" {
+		if prStyle == psJSON || flags.Changed("message") {
+			Die("--title is required with --json or --message")
+		}
+		instructions := fmt.Sprintf("Describe the new issue of %s.\nThe first line is the title; the remaining lines are the description.\nThese lines are removed from the message. An empty message aborts the issue.", repopath)
+		message := issueMessage(cmd, gincl, repopath, instructions, true)
+		parts := strings.SplitN(message, "\n", 2)
+		title = strings.TrimSpace(parts[0])
+		if len(parts) > 1 {
+			body = strings.TrimSpace(parts[1])
+		}
+	} else {
+		body = issueMessage(cmd, gincl, repopath, "", false)
+	}
+
+	issue, err := gincl.CreateIssue(repopath, title, body)
+	CheckError(err)
+	if prStyle == psJSON {
+		j, _ := json.Marshal(issue)
+		fmt.Println(string(j))
+		return
+	}
+	fmt.Fprintf(color.Output, ":: Issue #%d created in %s %s\n", issue.Index, repopath, green("OK"))
+}
+
+func issuesComment(cmd *cobra.Command, args []string) {
+	prStyle := determinePrintStyle(cmd)
+	repopath := args[0]
+	number := parseIssueNumber(args[1])
+	gincl := issueClient(cmd, repopath)
+
+	instructions := fmt.Sprintf("Write your comment on issue #%d of %s.\nThese lines are removed from the comment. An empty message aborts the comment.", number, repopath)
+	body := issueMessage(cmd, gincl, repopath, instructions, true)
+	comment, err := gincl.CommentIssue(repopath, number, body)
+	CheckError(err)
+	if prStyle == psJSON {
+		j, _ := json.Marshal(comment)
+		fmt.Println(string(j))
+		return
+	}
+	fmt.Fprintf(color.Output, ":: Comment added to issue #%d %s\n", number, green("OK"))
+}
+
+func issuesClose(cmd *cobra.Command, args []string) {
+	prStyle := determinePrintStyle(cmd)
+	flags := cmd.Flags()
+	repopath := args[0]
+	number := parseIssueNumber(args[1])
+	gincl := issueClient(cmd, repopath)
+
+	// a closing comment is only added if a message or files are given
+	if body := issueMessage(cmd, gincl, repopath, "", false); body != "" || flags.Changed("message") {
+		_, err := gincl.CommentIssue(repopath, number, body)
+		CheckError(err)
+	}
+	issue, err := gincl.CloseIssue(repopath, number)
+	CheckError(err)
+	if prStyle == psJSON {
+		j, _ := json.Marshal(issue)
+		fmt.Println(string(j))
+		return
+	}
+	fmt.Fprintf(color.Output, ":: Issue #%d closed %s\n", number, green("OK"))
+}
+
+// issueSubCmd sets up an issues subcommand with the flags shared by all of them.
+func issueSubCmd(cmd *cobra.Command) *cobra.Command {
+	cmd.DisableFlagsInUseLine = true
+	cmd.Flags().Bool("json", false, jsonHelpMsg)
+	cmd.Flags().String("server", "", "Specify server `alias` for the repository. See also 'gin servers'.")
+	return cmd
+}
+
+// fileFlag adds the --file flag for referencing files in messages.
+func fileFlag(cmd *cobra.Command) {
+	cmd.Flags().StringSlice("file", nil, "Reference the file at `path[@revision]` of the local repository in the message (can be specified multiple times). Without a revision, the last commit that changed the file is referenced.")
+}
+
+// IssuesCmd sets up the 'issues' subcommand and its subcommands
+func IssuesCmd() *cobra.Command {
+	description := "List, create, comment on, and close the issues of a repository. Issues can be used to keep track of problems with the data of a repository (e.g., missing or corrupted files) and their resolution.\n\nMessages are written in an editor, like git commit messages, unless they are specified with --message. The editor is determined like in git (core.editor, GIT_EDITOR, VISUAL, or EDITOR).\n\nFiles of the repository can be referenced with --file, when the command is run in a local copy of the repository. The references are appended to the message as links to the file at a specific commit, so they remain valid when the file changes later."
+	var cmd = &cobra.Command{
+		Use:                   "issues <command>",
+		Short:                 "Manage the issues of a repository",
+		Long:                  formatdesc(description, nil),
+		DisableFlagsInUseLine: true,
+	}
+
+	repoarg := map[string]string{
+		"<repopath>": "The path of the repository. A repository path is the owner's username, followed by a \"/\" and the repository name.",
+	}
+	issuearg := map[string]string{
+		"<repopath>": repoarg["<repopath>"],
+		"<number>":   "The number of the issue (as shown by 'gin issues list').",
+	}
+
+	listcmd := issueSubCmd(&cobra.Command{
+		Use:     "list [--json] [--closed] <repopath>",
+		Short:   "List the issues of a repository",
+		Long:    formatdesc("List the open issues of a repository, or the closed issues with --closed.", repoarg),
+		Example: formatexamples(map[string]string{"List the open issues of the repository 'ephys' owned by user 'alice'": "$ gin issues list alice/ephys"}),
+		Args:    cobra.ExactArgs(1),
+		Run:     issuesList,
+	})
+	listcmd.Flags().Bool("closed", false, "List closed issues instead of open issues.")
+
+	createcmd := issueSubCmd(&cobra.Command{
+		Use:   "create [--json] [--title <title> [--message <message>]] [--file <path>]... <repopath>",
+		Short: "Create an issue",
+		Long:  formatdesc("Create a new issue in a repository. If no title is specified, an editor is opened for writing the issue: the first line is used as the title and the remaining lines as the description.", repoarg),
+		Example: formatexamples(map[string]string{
+			"Report a problem with a file of the current repository": "$ gin issues create --title \"Session 14 has dropped frames\" --file session14/video.avi alice/ephys",
+			"Write the issue in an editor":                           "$ gin issues create alice/ephys",
+		}),
+		Args: cobra.ExactArgs(1),
+		Run:  issuesCreate,
+	})
+	createcmd.Flags().String("title", "", "The `title` of the new issue.")
+	createcmd.Flags().StringP("message", "m", "", "The description of the new issue (requires --title).")
+	fileFlag(createcmd)
+
+	commentcmd := issueSubCmd(&cobra.Command{
+		Use:     "comment [--json] [--message <message>] [--file <path>]... <repopath> <number>",
+		Short:   "Comment on an issue",
+		Long:    formatdesc("Add a comment to an issue. If no message is specified, an editor is opened for writing the comment.", issuearg),
+		Example: formatexamples(map[string]string{"Comment on issue 12 of the repository 'ephys' owned by user 'alice'": "$ gin issues comment -m \"Frames recovered from the backup\" alice/ephys 12"}),
+		Args:    cobra.ExactArgs(2),
+		Run:     issuesComment,
+	})
+	commentcmd.Flags().StringP("message", "m", "", "The text of the comment.")
+	fileFlag(commentcmd)
+
+	closecmd := issueSubCmd(&cobra.Command{
+		Use:     "close [--json] [--message <message>] [--file <path>]... <repopath> <number>",
+		Short:   "Close an issue",
+		Long:    formatdesc("Close an issue. If a message or files are specified, they are added as a comment before the issue is closed.", issuearg),
+		Example: formatexamples(map[string]string{"Close issue 12 with a comment referencing the fixed file": "$ gin issues close -m \"Fixed\" --file session14/video.avi alice/ephys 12"}),
+		Args:    cobra.ExactArgs(2),
+		Run:     issuesClose,
+	})
+	closecmd.Flags().StringP("message", "m", "", "Add a closing comment with the given text.")
+	fileFlag(closecmd)
+
+	cmd.AddCommand(listcmd, createcmd, commentcmd, closecmd)
+	return cmd
+}
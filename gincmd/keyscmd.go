@@ -3,12 +3,16 @@ package gincmd
 import (
 	"fmt"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
 	ginclient "github.com/G-Node/gin-cli/ginclient"
 	"github.com/G-Node/gin-cli/ginclient/config"
+	"github.com/G-Node/gin-cli/git"
+	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 )
 
@@ -26,13 +30,35 @@ func keys(cmd *cobra.Command, args []string) {
 	keyfilename, _ := flags.GetString("add")
 	keyidx, _ := flags.GetInt("delete")
 	verbose, _ := flags.GetBool("verbose")
+	rotate, _ := flags.GetBool("rotate")
+	useforgit, _ := flags.GetBool("use-for-git")
 
 	if keyfilename != "" && keyidx > 0 {
 		Die("can't add and delete key at the same time")
 	}
+	if rotate && (keyfilename != "" || keyidx > 0) {
+		Die("--rotate can't be combined with --add or --delete")
+	}
+
+	if useforgit && keyfilename == "" {
+		Die("--use-for-git can only be used with --add")
+	}
+
+	if rotate {
+		rotateKey(gincl)
+		return
+	}
 
 	if keyfilename != "" {
+		var identityfile string
+		if useforgit {
+			// check the private key before adding the public key to the account
+			identityfile, _ = gitKeySettings(keyfilename)
+		}
 		addKey(gincl, keyfilename)
+		if useforgit {
+			useKeyForGit(srvalias, identityfile)
+		}
 		return
 	}
 	if keyidx > 0 {
@@ -86,20 +112,74 @@ func addKey(gincl *ginclient.Client, filename string) {
 	fmt.Printf("New key added '%s'\n", description)
 }
 
+// gitKeySettings determines how the key in the given public key file can be
+// used for git operations: with the private key file next to the public key
+// or, if there is none, with the SSH agent (which must hold the key).
+// It exits with an error if the private key is not available either way.
+func gitKeySettings(pubkeyfile string) (identityfile string, useagent bool) {
+	privkeyfile := strings.TrimSuffix(pubkeyfile, ".pub")
+	if _, err := os.Stat(privkeyfile); privkeyfile != pubkeyfile && err == nil {
+		privkeyfile, _ = filepath.Abs(privkeyfile)
+		return privkeyfile, false
+	}
+	pubkey, err := ioutil.ReadFile(pubkeyfile)
+	CheckError(err)
+	inagent, err := git.AgentHasKey(pubkey)
+	if err != nil {
+		Die(fmt.Sprintf("private key for '%s' not found and the SSH agent could not be checked for the key: %v", pubkeyfile, err))
+	}
+	if !inagent {
+		Die(fmt.Sprintf("private key for '%s' not found: the private key must be next to the public key (without the .pub extension) or added to the SSH agent", pubkeyfile))
+	}
+	return "", true
+}
+
+// useKeyForGit configures the server to use the given private key file, or
+// the SSH agent if identityfile is empty, for git operations.
+func useKeyForGit(srvalias, identityfile string) {
+	if _, ok := config.Read().Servers[srvalias]; !ok {
+		Die(fmt.Sprintf("unknown server alias '%s'", srvalias))
+	}
+	if identityfile != "" {
+		CheckError(config.SetConfig(fmt.Sprintf("servers.%s.git.identityfile", srvalias), identityfile))
+		fmt.Printf("Key '%s' will be used for git operations with server '%s'\n", identityfile, srvalias)
+		return
+	}
+	CheckError(config.SetConfig(fmt.Sprintf("servers.%s.git.useagent", srvalias), true))
+	fmt.Printf("Keys from the SSH agent will be used for git operations with server '%s'\n", srvalias)
+}
+
 func delKey(gincl *ginclient.Client, idx int) {
 	name, err := gincl.DeletePubKeyByIdx(idx)
 	CheckError(err)
 	fmt.Printf("Deleted key with name '%s'\n", name)
 }
 
+func rotateKey(gincl *ginclient.Client) {
+	fmt.Print(":: Rotating session key ")
+	result, err := gincl.RotateSessionKey()
+	if err != nil {
+		fmt.Fprintln(color.Output, red("FAILED"))
+		CheckError(err)
+	}
+	fmt.Fprintln(color.Output, green("OK"))
+	fmt.Printf("New key added '%s'\n", result.NewKey)
+	if result.OldKey != "" {
+		fmt.Printf("Deleted key with name '%s'\n", result.OldKey)
+	} else {
+		fmt.Fprintf(color.Error, "%s the previous session key was not found on the server\n", yellow("[warning]"))
+	}
+}
+
 // KeysCmd sets up the 'keys' list, add, delete subcommand(s)
 func KeysCmd() *cobra.Command {
-	description := "List, add, or delete SSH keys. If no argument is provided, a numbered list of key names is printed. The key number can be used with the '--delete' flag to remove a key from the server.\n\nThe command can also be used to add a public key to your account from an existing filename (see '--add' flag)."
+	description := "List, add, or delete SSH keys. If no argument is provided, a numbered list of key names is printed. The key number can be used with the '--delete' flag to remove a key from the server.\n\nThe command can also be used to add a public key to your account from an existing filename (see '--add' flag).\n\nThe key created by the client on login (the session key) can be replaced with a new one using the '--rotate' flag. The new key is uploaded and tested before the old key is deleted from the server. If anything fails, the old key remains in use. Servers that use HTTPS for git operations have no session key, so rotation is not available for them. The type of generated keys (ed25519 or rsa) is set by the 'ssh.keytype' configuration option.\n\nBy default, git operations use the key created on login. To use your own key instead (e.g., a key held by the SSH agent or a hardware token), add it with '--add' and '--use-for-git'. This sets the 'git.identityfile' option of the server to the private key next to the public key file or, if there is none and the key is held by the SSH agent, enables the 'git.useagent' option. The key is only offered to the git server it is configured for. Once set up, git operations work without logging in."
 	examples := map[string]string{
 		"Add a public key to your account, as generated from the default ssh-keygen command": "$ gin keys --add ~/.ssh/id_rsa.pub",
+		"Replace the session key of this client with a new key":                              "$ gin keys --rotate",
 	}
 	var cmd = &cobra.Command{
-		Use:                   "keys [--add <filename> | --delete <keynum> | --verbose | -v]",
+		Use:                   "keys [--add <filename> [--use-for-git] | --delete <keynum> | --rotate | --verbose | -v]",
 		Short:                 "List, add, or delete public keys on the GIN services",
 		Long:                  formatdesc(description, nil),
 		Example:               formatexamples(examples),
@@ -109,6 +189,8 @@ func KeysCmd() *cobra.Command {
 	}
 	cmd.Flags().String("add", "", "Specify a `filename` which contains a public key to be added to the GIN server.")
 	cmd.Flags().Int("delete", 0, "Specify a `number` to delete the corresponding key from the server. Use 'gin keys' to get the numbered listing of keys.")
+	cmd.Flags().Bool("use-for-git", false, "Use the added key for git operations with the server instead of the key created on login (requires --add).")
+	cmd.Flags().Bool("rotate", false, "Replace the session key of this client with a newly generated key.")
 	cmd.Flags().BoolP("verbose", "v", false, "Verbose printing. Prints the entire public key.")
 	cmd.Flags().String("server", "", "Specify server `alias` to query, add, or remove keys. See also 'gin servers'.")
 	return cmd
@@ -32,7 +32,9 @@ func lock(cmd *cobra.Command, args []string) {
 	lockchan := make(chan git.RepoFileStatus)
 
 	go gincl.LockContent(args, lockchan)
-	formatOutput(lockchan, prStyle, nitems)
+	filesuccess := printOutput(lockchan, prStyle, nitems)
+	recordFileOperation(ginclient.OpLock, filesuccess)
+	dieOnFailures(filesuccess)
 }
 
 // LockCmd sets up the file 'lock' subcommand
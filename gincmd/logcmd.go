@@ -0,0 +1,133 @@
+package gincmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/G-Node/gin-cli/gincmd/ginerrors"
+	"github.com/G-Node/gin-cli/git"
+	humanize "github.com/dustin/go-humanize"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+func formatAnnexStat(stat git.AnnexDiffStat) string {
+	if stat.Added == 0 && stat.Removed == 0 {
+		return ""
+	}
+	return fmt.Sprintf("+%s / -%s", humanize.IBytes(uint64(stat.Added)), humanize.IBytes(uint64(stat.Removed)))
+}
+
+func printLogOneline(commits []git.GinCommit) {
+	for _, commit := range commits {
+		annexstr := formatAnnexStat(commit.AnnexStats)
+		if annexstr != "" {
+			annexstr = fmt.Sprintf(" [%s annexed]", annexstr)
+		}
+		fmt.Fprintf(color.Output, "%s %s %s %s%s\n", green(commit.AbbreviatedHash), commit.Date.Format("2006-01-02"), yellow(commit.AuthorName), commit.Subject, annexstr)
+	}
+}
+
+func printLog(commits []git.GinCommit) {
+	width := termwidth()
+	for _, commit := range commits {
+		fmt.Fprintf(color.Output, "%s * %s\n", green(commit.AbbreviatedHash), commit.Date.Format("Mon Jan 2 15:04:05 2006 (-0700)"))
+		fmt.Printf("Author: %s <%s>\n\n", commit.AuthorName, commit.AuthorEmail)
+		fmt.Printf("%s\n", winner.Wrap(commit.Subject, width))
+		if len(commit.Body) > 0 {
+			fmt.Printf("%s\n", winner.Wrap(commit.Body, width))
+		}
+		fstats := commit.FileStats
+		if len(fstats.NewFiles) > 0 {
+			fmt.Printf("  Added\n%s\n", winner.Wrap(strings.Join(fstats.NewFiles, ", "), width))
+		}
+		if len(fstats.ModifiedFiles) > 0 {
+			fmt.Printf("  Modified\n%s\n", winner.Wrap(strings.Join(fstats.ModifiedFiles, ", "), width))
+		}
+		if len(fstats.DeletedFiles) > 0 {
+			fmt.Printf("  Deleted\n%s\n", winner.Wrap(strings.Join(fstats.DeletedFiles, ", "), width))
+		}
+		if annexstr := formatAnnexStat(commit.AnnexStats); annexstr != "" {
+			fmt.Printf("  Annexed data: %s\n", annexstr)
+		}
+		fmt.Println()
+	}
+}
+
+func printHistory(cmd *cobra.Command, args []string) {
+	prStyle := determinePrintStyle(cmd)
+	if !git.IsRepo() {
+		Die(ginerrors.NotInRepo)
+	}
+	flags := cmd.Flags()
+	count, _ := flags.GetUint("max-count")
+	oneline, _ := flags.GetBool("oneline")
+	var filter git.LogFilter
+	filter.Author, _ = flags.GetString("author")
+	filter.Since, _ = flags.GetString("since")
+	filter.Until, _ = flags.GetString("until")
+	filter.Grep, _ = flags.GetString("grep")
+	if oneline && prStyle == psJSON {
+		usageDie(cmd)
+	}
+
+	commits, err := git.LogFiltered(count, "", args, true, filter)
+	CheckError(err)
+
+	annexstats, err := git.LogAnnexStat(count, "", args, true, filter)
+	if err != nil {
+		// annexed data sizes are informational; print the log without them
+		fmt.Fprintf(color.Error, "%s failed to determine annexed data sizes: %s\n", yellow("[warning]"), err)
+	}
+	for idx, commit := range commits {
+		commits[idx].AnnexStats = annexstats[commit.Hash]
+	}
+
+	if prStyle == psJSON {
+		if commits == nil {
+			commits = []git.GinCommit{}
+		}
+		j, _ := json.Marshal(commits)
+		fmt.Println(string(j))
+		return
+	}
+	if len(commits) == 0 {
+		Exit("No versions matched the request")
+	}
+	if oneline {
+		printLogOneline(commits)
+		return
+	}
+	printLog(commits)
+}
+
+// LogCmd sets up the 'log' subcommand
+func LogCmd() *cobra.Command {
+	description := "Show the history of the repository: the versions (commits) recorded in the local repository, newest first. For each version, the ID, date, author, message, and the names of the files that were added, modified, or deleted are printed, along with the amount of annexed data added and removed.\n\nThe history can be limited to versions that affect specific files or directories, or filtered by author, date, and message."
+	args := map[string]string{
+		"<filenames>": "One or more directories or files. Only versions that affect these files are shown.",
+	}
+	examples := map[string]string{
+		"Show the last 10 versions in compact format":                "$ gin log --oneline -n 10",
+		"Show versions by a specific author from the last two weeks": "$ gin log --author alice --since \"2 weeks ago\"",
+		"Show versions that changed files in the 'data' directory":   "$ gin log data",
+	}
+	var cmd = &cobra.Command{
+		Use:                   "log [--json | --oneline] [--max-count n] [--author <author>] [--since <date>] [--until <date>] [--grep <pattern>] [<filenames>]...",
+		Short:                 "Show the history of the repository",
+		Long:                  formatdesc(description, args),
+		Example:               formatexamples(examples),
+		Args:                  cobra.ArbitraryArgs,
+		Run:                   printHistory,
+		DisableFlagsInUseLine: true,
+	}
+	cmd.Flags().Bool("json", false, jsonHelpMsg)
+	cmd.Flags().Bool("oneline", false, "Print each version on a single line.")
+	cmd.Flags().UintP("max-count", "n", 0, "Maximum `number` of versions to show (0 for no limit).")
+	cmd.Flags().String("author", "", "Show only versions by authors whose name or email matches `pattern`.")
+	cmd.Flags().String("since", "", "Show only versions more recent than `date`.")
+	cmd.Flags().String("until", "", "Show only versions older than `date`.")
+	cmd.Flags().String("grep", "", "Show only versions whose message matches `pattern` (case insensitive).")
+	return cmd
+}
@@ -1,10 +1,16 @@
 package gincmd
 
 import (
+	"bufio"
 	"fmt"
+	"io"
+	"os"
+	"strings"
 
 	ginclient "github.com/G-Node/gin-cli/ginclient"
 	"github.com/G-Node/gin-cli/ginclient/config"
+	"github.com/G-Node/gin-cli/web"
+	"github.com/fatih/color"
 	"github.com/howeyc/gopass"
 	"github.com/spf13/cobra"
 )
@@ -22,6 +28,23 @@ func login(cmd *cobra.Command, args []string) {
 	}
 	fmt.Printf("Logging into %s\n", srvalias)
 
+	token, _ := flags.GetString("token")
+	if token == "-" {
+		token = readTokenStdin()
+	} else if !flags.Changed("token") && len(args) == 0 {
+		// non-interactive login with token from environment
+		token, _ = web.EnvToken(srvalias)
+		if token != "" {
+			fmt.Printf("Using access token from %s\n", web.TokenEnvVar)
+		}
+	}
+	if token != "" {
+		tokenLogin(srvalias, token, args)
+		return
+	} else if flags.Changed("token") {
+		Die("No token provided. Aborting.")
+	}
+
 	if len(args) == 0 {
 		// prompt for login
 		fmt.Print("Login: ")
@@ -61,13 +84,40 @@ func login(cmd *cobra.Command, args []string) {
 	}
 	fmt.Printf(":: Welcome %s\n", name)
 	fmt.Printf(":: Successfully logged into %s [%s]\n", srvalias, gincl.WebAddress())
+	trustOnFirstUse(srvalias)
+}
+
+// readTokenStdin reads an access token from the first line of stdin.
+func readTokenStdin() string {
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		Die(fmt.Sprintf("failed to read token from stdin: %s", err))
+	}
+	return strings.TrimSpace(line)
+}
+
+// tokenLogin performs login with an existing access token.
+func tokenLogin(srvalias, token string, args []string) {
+	gincl := ginclient.New(srvalias)
+	info, err := gincl.LoginWithToken(token)
+	CheckError(err)
+	if len(args) > 0 && args[0] != info.UserName {
+		fmt.Fprintf(color.Error, "%s the token belongs to user '%s', not '%s'\n", yellow("[warning]"), info.UserName, args[0])
+	}
+	name := info.FullName
+	if name == "" {
+		name = info.UserName
+	}
+	fmt.Printf(":: Welcome %s\n", name)
+	fmt.Printf(":: Successfully logged into %s [%s]\n", srvalias, gincl.WebAddress())
+	trustOnFirstUse(srvalias)
 }
 
 // LoginCmd sets up the 'login' subcommand
 func LoginCmd() *cobra.Command {
-	description := "Login to the GIN services.\n\nIf no username is specified on the command line, you will be prompted for it. The login command always prompts for a password."
+	description := "Login to the GIN services.\n\nIf no username is specified on the command line, you will be prompted for it. The login command prompts for a password, unless an existing access token is provided.\n\nFor non-interactive use (e.g., in scripts or on compute clusters), an access token can be provided with --token, or read from standard input with '--token -'. If the GIN_TOKEN environment variable is set for the server and no username or token is specified, the token from the environment is used. The token is validated with the server and the username is determined from the account it belongs to.\n\nThe GIN_TOKEN (and optionally GIN_USERNAME) environment variables can also be used without logging in: when set, they are used for all commands that communicate with the server instead of the stored login token. The token is only used for the server named in the GIN_TOKEN_SERVER environment variable (the server 'gin' if it is not set) and is ignored for all other servers. Note that commands that transfer data (e.g., upload, download) also require the key that is created when logging in."
 	var cmd = &cobra.Command{
-		Use:                   "login [<username>]",
+		Use:                   "login [--token <token> | --token -] [<username>]",
 		Short:                 "Login to the GIN services",
 		Long:                  formatdesc(description, nil),
 		Args:                  cobra.MaximumNArgs(1),
@@ -75,5 +125,6 @@ func LoginCmd() *cobra.Command {
 		DisableFlagsInUseLine: true,
 	}
 	cmd.Flags().String("server", "", "Specify server `alias` to log into. See also 'gin servers'.")
+	cmd.Flags().String("token", "", "Log in using an existing access `token` instead of a password. Use '-' to read the token from standard input.")
 	return cmd
 }
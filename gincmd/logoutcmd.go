@@ -25,6 +25,23 @@ func logout(cmd *cobra.Command, args []string) {
 		Die("You are not logged in.")
 	}
 
+	revoke, _ := flags.GetBool("revoke")
+	var password, tokenname string
+	if revoke {
+		// find the token on the server; deleting it requires the account password
+		password = promptPassword(gincl.Username)
+		tokenlist, err := gincl.GetTokens(gincl.Username, password)
+		CheckError(err)
+		var ok bool
+		if tokenname, ok = gincl.CurrentTokenName(tokenlist); !ok {
+			Die("the token used by this client was not found on the server; it may have already been deleted (see 'gin tokens')")
+		}
+		// delete the token before logging out, so that a failed revoke can be retried
+		if err := gincl.DeleteAccessToken(gincl.Username, password, tokenname); err != nil {
+			Die(fmt.Sprintf("the token could not be deleted from the server: %s\nYou are still logged in.", err.Error()))
+		}
+		fmt.Printf(":: Token '%s' deleted from the server.\n", tokenname)
+	}
 	gincl.Logout()
 	fmt.Println(":: You have been logged out.")
 }
@@ -32,13 +49,14 @@ func logout(cmd *cobra.Command, args []string) {
 // LogoutCmd sets up the 'logout' subcommand
 func LogoutCmd() *cobra.Command {
 	var cmd = &cobra.Command{
-		Use:                   "logout",
+		Use:                   "logout [--server alias] [--revoke]",
 		Short:                 "Logout of the GIN services",
-		Long:                  "Logout of the GIN services.\n\nThis command takes no arguments.",
+		Long:                  formatdesc("Logout of the GIN services. The login token and key are removed from this machine.\n\nWith --revoke, the token is also deleted from the server, so that it can no longer be used to access your account. This requires your password. Note that clients logging in with a password on different machines share the same token, so revoking it logs out all of them. If the token can not be deleted (e.g., because the server does not support revoking tokens), you remain logged in.\n\nThis command takes no arguments.", nil),
 		Args:                  cobra.NoArgs,
 		Run:                   logout,
 		DisableFlagsInUseLine: true,
 	}
 	cmd.Flags().String("server", "", "Specify server `alias` where the repository will be created. See also 'gin servers'.")
+	cmd.Flags().Bool("revoke", false, "Also delete the token from the server.")
 	return cmd
 }
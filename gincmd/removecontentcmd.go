@@ -33,7 +33,9 @@ func remove(cmd *cobra.Command, args []string) {
 		fmt.Println(":: Removing file content")
 	}
 	go gincl.RemoveContent(args, rmchan)
-	formatOutput(rmchan, prStyle, nitems)
+	filesuccess := printOutput(rmchan, prStyle, nitems)
+	recordFileOperation(ginclient.OpRemoveContent, filesuccess)
+	dieOnFailures(filesuccess)
 }
 
 // RemoveContentCmd sets up the 'remove-content' subcommand
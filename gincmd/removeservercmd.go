@@ -17,7 +17,7 @@ func rmServer(cmd *cobra.Command, args []string) {
 	if alias == "gin" {
 		fmt.Println(":: 'gin' alias now refers to the built-in G-Node GIN server.")
 	} else if defserver == alias {
-		config.SetDefaultServer("gin")
+		CheckError(config.SetDefaultServer("gin"))
 		fmt.Printf(":: %s was the default sever. Reverting to default server 'gin'.\n:: Use 'gin use-server' to set a new default.\n", alias)
 	}
 }
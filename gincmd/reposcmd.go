@@ -3,6 +3,8 @@ package gincmd
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 
 	ginclient "github.com/G-Node/gin-cli/ginclient"
 	"github.com/G-Node/gin-cli/ginclient/config"
@@ -22,6 +24,9 @@ func repos(cmd *cobra.Command, args []string) {
 	allrepos, _ := flags.GetBool("all")
 	sharedrepos, _ := flags.GetBool("shared")
 	srvalias, _ := flags.GetString("server")
+	limit, _ := flags.GetInt("limit")
+	sortkey, _ := flags.GetString("sort")
+	filter, _ := flags.GetString("filter")
 
 	conf := config.Read()
 	if srvalias == "" {
@@ -30,6 +35,12 @@ func repos(cmd *cobra.Command, args []string) {
 	if (allrepos && sharedrepos) || ((allrepos || sharedrepos) && len(args) > 0) {
 		usageDie(cmd)
 	}
+	if limit < 0 {
+		Die("--limit must be a positive number")
+	}
+	if _, ok := repoSortKeys[sortkey]; sortkey != "" && !ok {
+		Die(fmt.Sprintf("invalid sort key '%s': must be one of name, created, updated, size, stars", sortkey))
+	}
 
 	gincl := ginclient.New(srvalias)
 	requirelogin(cmd, gincl, !jsonout)
@@ -53,15 +64,21 @@ func repos(cmd *cobra.Command, args []string) {
 		}
 	}
 
+	var outlist []gogs.Repository
+	if allrepos {
+		outlist = append(userrepos, otherrepos...)
+	} else if sharedrepos {
+		outlist = otherrepos
+	} else {
+		outlist = userrepos
+	}
+	outlist = filterRepos(outlist, filter)
+	sortRepos(outlist, sortkey)
+	if limit > 0 && len(outlist) > limit {
+		outlist = outlist[:limit]
+	}
+
 	if jsonout {
-		var outlist []gogs.Repository
-		if allrepos {
-			outlist = append(userrepos, otherrepos...)
-		} else if sharedrepos {
-			outlist = otherrepos
-		} else {
-			outlist = userrepos
-		}
 		if len(outlist) > 0 {
 			j, _ := json.Marshal(outlist)
 			fmt.Println(string(j))
@@ -69,32 +86,62 @@ func repos(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	printedlines := 0
-	if len(userrepos) > 0 && !sharedrepos {
-		printedlines += len(userrepos)
-		printRepoList(userrepos)
+	if len(outlist) == 0 {
+		fmt.Println("No repositories found")
+		return
+	}
+	printRepoList(outlist)
+}
+
+// filterRepos returns the repositories whose full name or description contain the filter text (case insensitive).
+func filterRepos(repolist []gogs.Repository, filter string) []gogs.Repository {
+	if filter == "" {
+		return repolist
 	}
-	if len(otherrepos) > 0 && (sharedrepos || allrepos) {
-		printedlines += len(otherrepos)
-		printRepoList(otherrepos)
+	filter = strings.ToLower(filter)
+	var filtered []gogs.Repository
+	for _, repo := range repolist {
+		if strings.Contains(strings.ToLower(repo.FullName), filter) || strings.Contains(strings.ToLower(repo.Description), filter) {
+			filtered = append(filtered, repo)
+		}
 	}
+	return filtered
+}
 
-	if printedlines == 0 {
-		fmt.Println("No repositories found")
+// repoSortKeys maps the values of the --sort flag to functions that report whether repository a should be listed before b.
+var repoSortKeys = map[string]func(a, b gogs.Repository) bool{
+	"name":    func(a, b gogs.Repository) bool { return strings.ToLower(a.FullName) < strings.ToLower(b.FullName) },
+	"created": func(a, b gogs.Repository) bool { return a.Created.After(b.Created) },
+	"updated": func(a, b gogs.Repository) bool { return a.Updated.After(b.Updated) },
+	"size":    func(a, b gogs.Repository) bool { return a.Size > b.Size },
+	"stars":   func(a, b gogs.Repository) bool { return a.Stars > b.Stars },
+}
+
+// sortRepos sorts the repository list in place by the given key. The order is not changed if the key is empty.
+func sortRepos(repolist []gogs.Repository, key string) {
+	if key == "" {
+		return
 	}
+	less := repoSortKeys[key]
+	sort.SliceStable(repolist, func(i, j int) bool { return less(repolist[i], repolist[j]) })
 }
 
 // ReposCmd sets up the 'repos' listing subcommand
 func ReposCmd() *cobra.Command {
-	description := "List repositories on the server that provide read access. If no argument is provided, it will list the repositories owned by the logged in user.\n\nNote that only one of the options can be specified."
+	description := "List repositories on the server that provide read access. If no argument is provided, it will list the repositories owned by the logged in user.\n\nNote that only one of the options --shared, --all, or a username can be specified.\n\nThe full list of repositories is retrieved from the server before it is filtered, sorted, and limited, so the options apply to all repositories and not only to the first page of results. When sorting by creation date, modification date, size, or stars, the newest, largest, or most starred repositories are listed first."
 
 	args := map[string]string{
 		"<username>": "The name of the user whose repositories should be listed. The list consists of public repositories and repositories shared with the logged in user.",
 	}
+	examples := map[string]string{
+		"List your 10 most recently updated repositories":     "$ gin repos --sort updated --limit 10",
+		"List all accessible repositories related to 'ephys'": "$ gin repos --all --filter ephys",
+	}
 	var cmd = &cobra.Command{
-		Use:                   "repos [--shared | --all | <username>]",
+		Use:                   "repos [--json] [--limit <n>] [--sort <key>] [--filter <text>] [--shared | --all | <username>]",
 		Short:                 "List available remote repositories",
 		Long:                  formatdesc(description, args),
+		Example:               formatexamples(examples),
 		Args:                  cobra.MaximumNArgs(1),
 		Run:                   repos,
 		DisableFlagsInUseLine: true,
@@ -102,6 +149,9 @@ func ReposCmd() *cobra.Command {
 	cmd.Flags().Bool("all", false, "List all repositories accessible to the logged in user.")
 	cmd.Flags().Bool("shared", false, "List all repositories that the user is a member of (excluding own repositories).")
 	cmd.Flags().Bool("json", false, "Print listing in JSON format.")
+	cmd.Flags().Int("limit", 0, "List at most `n` repositories.")
+	cmd.Flags().String("sort", "", "Sort the list by `key`: name, created, updated, size, or stars.")
+	cmd.Flags().String("filter", "", "List only repositories whose name or description contains `text` (case insensitive).")
 	cmd.Flags().String("server", "", "Specify server `alias` where the repository will be created. See also 'gin servers'.")
 	return cmd
 }
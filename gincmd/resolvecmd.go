@@ -0,0 +1,227 @@
+package gincmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	ginclient "github.com/G-Node/gin-cli/ginclient"
+	"github.com/G-Node/gin-cli/gincmd/ginerrors"
+	"github.com/G-Node/gin-cli/git"
+	humanize "github.com/dustin/go-humanize"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+func printConflictVersion(label string, cv ginclient.ConflictVersion) {
+	if !cv.Exists {
+		fmt.Printf("  %-7s deleted\n", label)
+		return
+	}
+	ftype := "git"
+	if cv.Annexed {
+		ftype = "annex"
+	}
+	var commitstr string
+	if len(cv.Commit) >= 7 {
+		commitstr = fmt.Sprintf("%s, %s <%s>, %s", cv.Commit[:7], cv.AuthorName, cv.AuthorEmail, cv.Date.Format("Mon Jan 2 15:04:05 2006 (-0700)"))
+	}
+	fmt.Printf("  %-7s %s (%s) %s\n", label, humanize.IBytes(uint64(cv.Size)), ftype, commitstr)
+}
+
+func printConflict(c ginclient.Conflict) {
+	fmt.Fprintf(color.Output, "%s\n", yellow(c.FileName))
+	printConflictVersion("local", c.Local)
+	printConflictVersion("remote", c.Remote)
+}
+
+func promptResolve() (ginclient.ResolveStrategy, bool) {
+	var response string
+	for {
+		fmt.Print("Keep [l]ocal / [r]emote / [b]oth / [s]kip: ")
+		fmt.Scanln(&response)
+
+		switch strings.ToLower(response) {
+		case "l", "local":
+			return ginclient.KeepLocal, true
+		case "r", "remote":
+			return ginclient.KeepRemote, true
+		case "b", "both":
+			return ginclient.KeepBoth, true
+		case "s", "skip":
+			return 0, false
+		}
+	}
+}
+
+// filterConflicts returns the conflicts matching the given paths.
+// The paths must be relative to the root of the repository.
+func filterConflicts(conflicts []ginclient.Conflict, paths []string) []ginclient.Conflict {
+	if len(paths) == 0 {
+		return conflicts
+	}
+	var filtered []ginclient.Conflict
+	for _, c := range conflicts {
+		for _, p := range paths {
+			if p == "." || c.FileName == p || strings.HasPrefix(c.FileName, strings.TrimSuffix(p, "/")+"/") {
+				filtered = append(filtered, c)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+func resolve(cmd *cobra.Command, args []string) {
+	prStyle := determinePrintStyle(cmd)
+	flags := cmd.Flags()
+	keeplocal, _ := flags.GetBool("keep-local")
+	keepremote, _ := flags.GetBool("keep-remote")
+	keepboth, _ := flags.GetBool("keep-both")
+	listonly, _ := flags.GetBool("list")
+	abort, _ := flags.GetBool("abort")
+
+	nstrategies := 0
+	var strategy ginclient.ResolveStrategy
+	for _, s := range []struct {
+		set      bool
+		strategy ginclient.ResolveStrategy
+	}{{keeplocal, ginclient.KeepLocal}, {keepremote, ginclient.KeepRemote}, {keepboth, ginclient.KeepBoth}} {
+		if s.set {
+			nstrategies++
+			strategy = s.strategy
+		}
+	}
+	if nstrategies > 1 || (abort && (nstrategies > 0 || listonly)) {
+		usageDie(cmd)
+	}
+
+	if !git.IsRepo() {
+		Die(ginerrors.NotInRepo)
+	}
+	reporoot, _ := git.FindRepoRoot(".")
+	workingdir, _ := os.Getwd()
+	// paths are given relative to the working directory; conflicts are listed relative to the root
+	var paths []string
+	for _, p := range args {
+		abspath, _ := filepath.Abs(p)
+		relpath, err := filepath.Rel(reporoot, abspath)
+		if err != nil || strings.HasPrefix(relpath, "..") {
+			Die(fmt.Sprintf("path '%s' is outside the repository", p))
+		}
+		paths = append(paths, filepath.ToSlash(relpath))
+	}
+	os.Chdir(reporoot)
+	defer os.Chdir(workingdir)
+
+	if abort {
+		CheckError(ginclient.AbortMerge())
+		if prStyle == psDefault {
+			fmt.Println(":: Merge aborted. The repository has been restored to its state before the download.")
+		}
+		return
+	}
+
+	conflicts, err := ginclient.Conflicts()
+	CheckError(err)
+	conflicts = filterConflicts(conflicts, paths)
+
+	if listonly || (prStyle == psJSON && nstrategies == 0) {
+		if prStyle == psJSON {
+			if conflicts == nil {
+				conflicts = []ginclient.Conflict{}
+			}
+			j, _ := json.Marshal(conflicts)
+			fmt.Println(string(j))
+			return
+		}
+		if len(conflicts) == 0 {
+			fmt.Println("No unresolved conflicts")
+			return
+		}
+		for _, c := range conflicts {
+			printConflict(c)
+		}
+		return
+	}
+
+	if prStyle == psDefault && len(conflicts) > 0 {
+		fmt.Println(":: Resolving conflicts")
+	}
+	nerr := 0
+	for _, c := range conflicts {
+		s := strategy
+		if nstrategies == 0 {
+			printConflict(c)
+			var ok bool
+			s, ok = promptResolve()
+			if !ok {
+				continue
+			}
+		}
+		rerr := ginclient.ResolveConflict(c, s)
+		if prStyle == psJSON {
+			status := git.RepoFileStatus{FileName: c.FileName, State: fmt.Sprintf("Keeping %s", s), Progress: "100%", Err: rerr}
+			j, _ := json.Marshal(status)
+			fmt.Println(string(j))
+		} else if rerr != nil {
+			fmt.Fprintf(color.Output, "  %s: %s %s\n", c.FileName, red("failed"), rerr.Error())
+		} else if s == ginclient.KeepBoth && c.Local.Exists && c.Remote.Exists {
+			fmt.Fprintf(color.Output, "  %s: %s (remote version saved as '%s')\n", c.FileName, green("kept both"), c.RemoteCopyName())
+		} else {
+			fmt.Fprintf(color.Output, "  %s: %s\n", c.FileName, green(fmt.Sprintf("kept %s", s)))
+		}
+		if rerr != nil {
+			nerr++
+		}
+	}
+
+	remaining, err := ginclient.Conflicts()
+	CheckError(err)
+	if len(remaining) > 0 {
+		if prStyle == psDefault {
+			fmt.Printf("%d conflicted file(s) remaining. Run 'gin resolve' again to resolve them or 'gin resolve --abort' to cancel the download.\n", len(remaining))
+		}
+		if nerr > 0 {
+			Die(fmt.Sprintf("%d conflicts could not be resolved", nerr))
+		}
+		return
+	}
+
+	CheckError(ginclient.FinishMerge())
+	if prStyle == psDefault {
+		fmt.Fprintf(color.Output, ":: All conflicts resolved. Download completed %s\n", green("OK"))
+	}
+}
+
+// ResolveCmd sets up the 'resolve' subcommand
+func ResolveCmd() *cobra.Command {
+	description := "Review and resolve conflicts left behind by a download. A conflict occurs when a file was changed both locally and remotely and the changes could not be merged automatically. In this case, the download is left unfinished until all conflicts are resolved.\n\nWith no flags, each conflicted file is listed along with information about the local and remote versions (size, author, and date of last change) and you are asked which version to keep. Alternatively, a strategy can be chosen for all files (or the files specified) using one of the --keep flags. When keeping both versions, the local version keeps the original filename and the remote version is saved with the remote version ID appended to its name.\n\nWhen all conflicts have been resolved, the download is completed. Use --abort to cancel the download and restore the repository to its state before the download.\n\nRepositories in direct mode are not supported: in direct mode, git-annex keeps both versions of a conflicted file during the download."
+	args := map[string]string{
+		"<filenames>": "One or more directories or files to resolve. If omitted, all conflicted files are resolved.",
+	}
+	examples := map[string]string{
+		"List all conflicted files and the information about each version":     "$ gin resolve --list",
+		"Keep the remote version of all files in the 'analysis' directory":     "$ gin resolve --keep-remote analysis",
+		"Cancel the download that led to the conflicts":                        "$ gin resolve --abort",
+		"Keep both versions of a file, storing the remote copy under new name": "$ gin resolve --keep-both data/recording.csv",
+	}
+	var cmd = &cobra.Command{
+		Use:                   "resolve [--json] [--list | --abort | --keep-local | --keep-remote | --keep-both] [<filenames>]...",
+		Short:                 "Resolve conflicts left behind by a download",
+		Long:                  formatdesc(description, args),
+		Example:               formatexamples(examples),
+		Args:                  cobra.ArbitraryArgs,
+		Run:                   resolve,
+		DisableFlagsInUseLine: true,
+	}
+	cmd.Flags().Bool("json", false, jsonHelpMsg)
+	cmd.Flags().Bool("list", false, "List conflicted files and information about each version without resolving them.")
+	cmd.Flags().Bool("keep-local", false, "Keep the local version of conflicted files and discard the remote changes.")
+	cmd.Flags().Bool("keep-remote", false, "Keep the remote version of conflicted files and discard the local changes.")
+	cmd.Flags().Bool("keep-both", false, "Keep both versions of conflicted files. The remote version is saved under a new name.")
+	cmd.Flags().Bool("abort", false, "Cancel the download and restore the repository to its state before the download.")
+	return cmd
+}
@@ -0,0 +1,75 @@
+package gincmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	ginclient "github.com/G-Node/gin-cli/ginclient"
+	"github.com/G-Node/gin-cli/ginclient/config"
+	"github.com/spf13/cobra"
+)
+
+func search(cmd *cobra.Command, args []string) {
+	flags := cmd.Flags()
+	jsonout, _ := flags.GetBool("json")
+	srvalias, _ := flags.GetString("server")
+	owner, _ := flags.GetString("owner")
+	private, _ := flags.GetBool("private")
+	public, _ := flags.GetBool("public")
+
+	if private && public {
+		usageDie(cmd)
+	}
+	conf := config.Read()
+	if srvalias == "" {
+		srvalias = conf.DefaultServer
+	}
+
+	gincl := ginclient.New(srvalias)
+	requirelogin(cmd, gincl, !jsonout)
+	opts := ginclient.SearchOptions{Owner: owner, Private: private, Public: public}
+	results, err := gincl.SearchRepos(strings.Join(args, " "), opts)
+	CheckError(err)
+
+	if jsonout {
+		if len(results) > 0 {
+			j, _ := json.Marshal(results)
+			fmt.Println(string(j))
+		}
+		return
+	}
+	if len(results) == 0 {
+		fmt.Println("No repositories found")
+		return
+	}
+	printRepoList(results)
+}
+
+// SearchCmd sets up the 'search' repository search subcommand
+func SearchCmd() *cobra.Command {
+	description := "Search the server for repositories by name. The search includes all public repositories and the repositories that the logged in user has access to.\n\nThe search terms are combined into a single phrase, which is matched (case insensitive) against any part of the repository names. The results can be limited to the repositories of a specific owner and to private or public repositories."
+
+	args := map[string]string{
+		"<terms>": "One or more words to search for in repository names.",
+	}
+	examples := map[string]string{
+		"Find repositories with 'ephys' in their name":            "$ gin search ephys",
+		"Find public repositories of the user 'alice' about mice": "$ gin search --owner alice --public mouse",
+	}
+	var cmd = &cobra.Command{
+		Use:                   "search [--json] [--owner <username>] [--private | --public] <terms>...",
+		Short:                 "Search the server for repositories",
+		Long:                  formatdesc(description, args),
+		Example:               formatexamples(examples),
+		Args:                  cobra.MinimumNArgs(1),
+		Run:                   search,
+		DisableFlagsInUseLine: true,
+	}
+	cmd.Flags().Bool("json", false, "Print results in JSON format.")
+	cmd.Flags().String("owner", "", "Only find repositories owned by the user or organisation with the given `username`.")
+	cmd.Flags().Bool("private", false, "Only find private repositories.")
+	cmd.Flags().Bool("public", false, "Only find public repositories.")
+	cmd.Flags().String("server", "", "Specify server `alias` to search. See also 'gin servers'.")
+	return cmd
+}
@@ -19,15 +19,27 @@ func servers(cmd *cobra.Command, args []string) {
 		if alias == defserver {
 			fmt.Fprintf(color.Output, green(" [default]"))
 		}
+		if config.IsSystemServer(alias) {
+			fmt.Fprint(color.Output, yellow(" [system, read-only]"))
+		}
 		fmt.Println()
 		fmt.Printf("  web: %s\n", srvcfg.Web.AddressStr())
-		fmt.Printf("  git: %s\n\n", srvcfg.Git.AddressStr())
+		fmt.Printf("  git: %s\n", srvcfg.GitAddressStr())
+		if srvcfg.Git.IdentityFile != "" {
+			fmt.Printf("  identity file: %s\n", srvcfg.Git.IdentityFile)
+		}
+		if srvcfg.Git.UseAgent {
+			fmt.Println("  using SSH agent")
+		}
+		fmt.Println()
 	}
 }
 
 // ServersCmd sets up the 'servers' subcommand
 func ServersCmd() *cobra.Command {
-	description := `List globally configured servers and their information.`
+	description := `List globally configured servers and their information.
+
+Servers defined in the system configuration file are marked as read-only. They can not be changed or removed, except for their git.hostkey, git.identityfile, and git.useagent options (see 'gin help config').`
 	var cmd = &cobra.Command{
 		Use:                   "servers",
 		Short:                 "List the globally configured servers",
@@ -0,0 +1,87 @@
+package gincmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	ginclient "github.com/G-Node/gin-cli/ginclient"
+	"github.com/G-Node/gin-cli/gincmd/ginerrors"
+	"github.com/G-Node/gin-cli/git"
+	"github.com/spf13/cobra"
+)
+
+func printShelf(shelf ginclient.Shelf) {
+	fmt.Printf("%s  %s  (%d files)\n", green(shelf.ID), shelf.Created.Format("Mon Jan 2 15:04:05 2006 (-0700)"), len(shelf.Files))
+	for _, sf := range shelf.Files {
+		fmt.Printf("  %-9s %s\n", sf.State, sf.Name)
+	}
+}
+
+func shelve(cmd *cobra.Command, args []string) {
+	prStyle := determinePrintStyle(cmd)
+	flags := cmd.Flags()
+	untracked, _ := flags.GetBool("untracked")
+	list, _ := flags.GetBool("list")
+	if !git.IsRepo() {
+		Die(ginerrors.NotInRepo)
+	}
+	reporoot, _ := git.FindRepoRoot(".")
+	workingdir, _ := os.Getwd()
+	os.Chdir(reporoot)
+	defer os.Chdir(workingdir)
+
+	if list {
+		shelves, err := ginclient.ListShelves()
+		CheckError(err)
+		if prStyle == psJSON {
+			if shelves == nil {
+				shelves = []ginclient.Shelf{}
+			}
+			j, _ := json.Marshal(shelves)
+			fmt.Println(string(j))
+			return
+		}
+		if len(shelves) == 0 {
+			fmt.Println("There are no shelved changes")
+			return
+		}
+		for _, shelf := range shelves {
+			printShelf(shelf)
+		}
+		return
+	}
+
+	shelf, err := ginclient.Shelve(untracked)
+	CheckError(err)
+	if prStyle == psJSON {
+		j, _ := json.Marshal(shelf)
+		fmt.Println(string(j))
+		return
+	}
+	fmt.Println(":: Shelved local changes")
+	printShelf(shelf)
+	fmt.Println("Use 'gin unshelve' to restore the changes.")
+}
+
+// ShelveCmd sets up the 'shelve' subcommand
+func ShelveCmd() *cobra.Command {
+	description := "Set aside all uncommitted changes in the local repository and restore the changed files to their state in the last commit. This is useful when local changes would be overwritten by a download.\n\nChanged files are moved into a shelf inside the repository's .git directory, so the content of large (annexed) files is preserved and not duplicated. Untracked files are only shelved when --untracked is specified. Shelved changes can be restored using the 'unshelve' command."
+	examples := map[string]string{
+		"Set aside local changes, download remote changes, and restore the local changes": "$ gin shelve\n$ gin download\n$ gin unshelve",
+		"List all shelved changes": "$ gin shelve --list",
+	}
+	var cmd = &cobra.Command{
+		Use:                   "shelve [--json] [--untracked | --list]",
+		Short:                 "Set aside uncommitted local changes",
+		Long:                  formatdesc(description, nil),
+		Example:               formatexamples(examples),
+		Args:                  cobra.NoArgs,
+		Run:                   shelve,
+		DisableFlagsInUseLine: true,
+	}
+	cmd.Flags().Bool("json", false, jsonHelpMsg)
+	cmd.Flags().Bool("untracked", false, "Also shelve files that are not tracked by the repository.")
+	cmd.Flags().Bool("list", false, "List shelved changes.")
+	return cmd
+}
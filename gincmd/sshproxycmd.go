@@ -0,0 +1,32 @@
+package gincmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/G-Node/gin-cli/git"
+	"github.com/spf13/cobra"
+)
+
+func sshProxy(cmd *cobra.Command, args []string) {
+	port, err := strconv.ParseUint(args[1], 10, 16)
+	if err != nil {
+		Die(fmt.Sprintf("invalid port '%s'", args[1]))
+	}
+	CheckError(git.ProxyCommand(args[0], uint16(port)))
+}
+
+// SSHProxyCmd sets up the (unlisted) ssh ProxyCommand subcommand, which
+// connects to git servers through the proxy configured for the server.
+func SSHProxyCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:                   git.ProxyCommandCmd + " <host> <port>",
+		Short:                 "Connect to a git server through the configured proxy (ssh ProxyCommand)",
+		Long:                  "",
+		Args:                  cobra.ExactArgs(2),
+		Run:                   sshProxy,
+		DisableFlagsInUseLine: true,
+		Hidden:                true,
+	}
+	return cmd
+}
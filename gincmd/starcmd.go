@@ -0,0 +1,85 @@
+package gincmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	ginclient "github.com/G-Node/gin-cli/ginclient"
+	"github.com/G-Node/gin-cli/ginclient/config"
+	"github.com/spf13/cobra"
+)
+
+func star(cmd *cobra.Command, args []string) {
+	flags := cmd.Flags()
+	jsonout, _ := flags.GetBool("json")
+	remove, _ := flags.GetBool("remove")
+	srvalias, _ := flags.GetString("server")
+	if remove && len(args) == 0 {
+		usageDie(cmd)
+	}
+	conf := config.Read()
+	if srvalias == "" {
+		srvalias = conf.DefaultServer
+	}
+	gincl := ginclient.New(srvalias)
+	requirelogin(cmd, gincl, !jsonout)
+
+	if len(args) == 0 {
+		starred, err := gincl.ListStarred()
+		CheckError(err)
+		if jsonout {
+			if len(starred) > 0 {
+				j, _ := json.Marshal(starred)
+				fmt.Println(string(j))
+			}
+			return
+		}
+		if len(starred) == 0 {
+			fmt.Println("No starred repositories")
+			return
+		}
+		printRepoList(starred)
+		return
+	}
+
+	repostr := args[0]
+	if !isValidRepoPath(repostr) {
+		Die(fmt.Sprintf("Invalid repository path '%s'. Full repository name should be the owner's username followed by the repository name, separated by a '/'.\nType 'gin help star' for information and examples.", repostr))
+	}
+	if remove {
+		CheckError(gincl.UnstarRepo(repostr))
+		if !jsonout {
+			fmt.Printf("Removed star from %s\n", repostr)
+		}
+		return
+	}
+	CheckError(gincl.StarRepo(repostr))
+	if !jsonout {
+		fmt.Printf("Starred %s\n", repostr)
+	}
+}
+
+// StarCmd sets up the 'star' repository subcommand
+func StarCmd() *cobra.Command {
+	description := "Star a repository on the server, or remove the star with --remove. Starred repositories are listed on the user's profile and can be used to keep track of interesting repositories.\n\nIf no argument is provided, the repositories starred by the logged in user are listed."
+	args := map[string]string{
+		"<repopath>": "The path of the repository to star. A repository path is the owner's username, followed by a \"/\" and the repository name.",
+	}
+	examples := map[string]string{
+		"Star the repository 'eegdata' owned by user 'peter'": "$ gin star peter/eegdata",
+		"List your starred repositories":                      "$ gin star",
+	}
+	var cmd = &cobra.Command{
+		Use:                   "star [--json] [--remove <repopath> | <repopath>]",
+		Short:                 "Star repositories or list starred repositories",
+		Long:                  formatdesc(description, args),
+		Example:               formatexamples(examples),
+		Args:                  cobra.MaximumNArgs(1),
+		Run:                   star,
+		DisableFlagsInUseLine: true,
+	}
+	cmd.Flags().Bool("remove", false, "Remove the star from the repository.")
+	cmd.Flags().Bool("json", false, "Print listing in JSON format.")
+	cmd.Flags().String("server", "", "Specify server `alias` for the repository. See also 'gin servers'.")
+	return cmd
+}
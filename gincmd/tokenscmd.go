@@ -0,0 +1,136 @@
+package gincmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	ginclient "github.com/G-Node/gin-cli/ginclient"
+	"github.com/G-Node/gin-cli/ginclient/config"
+	"github.com/fatih/color"
+	"github.com/howeyc/gopass"
+	"github.com/spf13/cobra"
+)
+
+// promptPassword asks for the password of the given user.
+// The prompt is printed to stderr so that it does not interfere with JSON output.
+func promptPassword(username string) string {
+	pwbytes, err := gopass.GetPasswdPrompt(fmt.Sprintf("Password for %s: ", username), true, os.Stdin, os.Stderr)
+	if err != nil {
+		if err == gopass.ErrInterrupted {
+			Die("Cancelled.")
+		}
+		if err == gopass.ErrMaxLengthExceeded {
+			Die("Input too long")
+		}
+		Die(err)
+	}
+	password := string(pwbytes)
+	if password == "" {
+		Die("No password provided. Aborting.")
+	}
+	return password
+}
+
+type tokenInfo struct {
+	Name    string `json:"name"`
+	Current bool   `json:"current"`
+}
+
+func tokens(cmd *cobra.Command, args []string) {
+	prStyle := determinePrintStyle(cmd)
+	flags := cmd.Flags()
+	srvalias, _ := flags.GetString("server")
+	delname, _ := flags.GetString("delete")
+
+	conf := config.Read()
+	if srvalias == "" {
+		srvalias = conf.DefaultServer
+	}
+	gincl := ginclient.New(srvalias)
+	if err := gincl.LoadToken(); err != nil {
+		Die("You are not logged in.")
+	}
+
+	// managing tokens requires the account password
+	password := promptPassword(gincl.Username)
+	tokenlist, err := gincl.GetTokens(gincl.Username, password)
+	CheckError(err)
+	current, _ := gincl.CurrentTokenName(tokenlist)
+
+	if delname != "" {
+		found := false
+		for _, token := range tokenlist {
+			if token.Name == delname {
+				found = true
+				break
+			}
+		}
+		if !found {
+			Die(fmt.Sprintf("token '%s' does not exist", delname))
+		}
+		if delname == current {
+			Die(fmt.Sprintf("token '%s' is used by this client; use 'gin logout --revoke' to log out and delete it", delname))
+		}
+		CheckError(gincl.DeleteAccessToken(gincl.Username, password, delname))
+		if prStyle == psJSON {
+			j, _ := json.Marshal(tokenInfo{Name: delname})
+			fmt.Println(string(j))
+			return
+		}
+		fmt.Fprintf(color.Output, ":: Token '%s' deleted %s\n", delname, green("OK"))
+		return
+	}
+
+	infos := make([]tokenInfo, len(tokenlist))
+	for idx, token := range tokenlist {
+		infos[idx] = tokenInfo{Name: token.Name, Current: token.Name == current}
+	}
+	if prStyle == psJSON {
+		j, _ := json.Marshal(infos)
+		fmt.Println(string(j))
+		return
+	}
+
+	ntokens := len(infos)
+	var plural string
+	if ntokens != 1 {
+		plural = "s"
+	}
+	var ntokensStr string
+	if ntokens == 0 {
+		ntokensStr = "no"
+	} else {
+		ntokensStr = fmt.Sprintf("%d", ntokens)
+	}
+	fmt.Printf("You have %s access token%s associated with your account.\n\n", ntokensStr, plural)
+	for idx, info := range infos {
+		if info.Current {
+			fmt.Fprintf(color.Output, "[%v] \"%s\" %s\n", idx+1, info.Name, green("(this client)"))
+		} else {
+			fmt.Printf("[%v] \"%s\"\n", idx+1, info.Name)
+		}
+	}
+}
+
+// TokensCmd sets up the 'tokens' subcommand
+func TokensCmd() *cobra.Command {
+	description := "List or delete the access tokens associated with your account. The token used by this client is marked in the list. Since tokens grant full access to your account, tokens left behind on machines you no longer use (e.g., shared lab computers) should be deleted.\n\nManaging tokens requires your password, which is requested when the command is run. Note that clients logging in with a password on different machines share the same token ('gin-cli'). Deleting a token logs out all clients that use it.\n\nTo log out and delete the token used by this client, use 'gin logout --revoke'."
+	examples := map[string]string{
+		"List your access tokens":             "$ gin tokens",
+		"Delete the token named 'lab-laptop'": "$ gin tokens --delete lab-laptop",
+	}
+	var cmd = &cobra.Command{
+		Use:                   "tokens [--json] [--server alias] [--delete <name>]",
+		Short:                 "List or delete the access tokens associated with your account",
+		Long:                  formatdesc(description, nil),
+		Example:               formatexamples(examples),
+		Args:                  cobra.NoArgs,
+		Run:                   tokens,
+		DisableFlagsInUseLine: true,
+	}
+	cmd.Flags().Bool("json", false, jsonHelpMsg)
+	cmd.Flags().String("server", "", "Specify server `alias`. See also 'gin servers'.")
+	cmd.Flags().String("delete", "", "Delete the token with the given `name`.")
+	return cmd
+}
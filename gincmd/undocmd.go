@@ -0,0 +1,118 @@
+package gincmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	ginclient "github.com/G-Node/gin-cli/ginclient"
+	"github.com/G-Node/gin-cli/ginclient/config"
+	"github.com/G-Node/gin-cli/ginclient/log"
+	"github.com/G-Node/gin-cli/gincmd/ginerrors"
+	"github.com/G-Node/gin-cli/git"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// recordOperation adds an operation to the repository journal so that it can be undone later.
+// Failures are logged but do not affect the operation itself.
+func recordOperation(op ginclient.Operation) {
+	if err := ginclient.RecordOperation(op); err != nil {
+		log.Write("Failed to record operation %s: %v", op.Command, err)
+	}
+}
+
+// recordFileOperation records an operation for all files that were processed successfully.
+func recordFileOperation(command string, filesuccess map[string]bool) {
+	var files []string
+	for fname, success := range filesuccess {
+		if success && fname != "" {
+			files = append(files, fname)
+		}
+	}
+	if len(files) == 0 {
+		return
+	}
+	sort.Strings(files)
+	recordOperation(ginclient.Operation{Command: command, Files: files})
+}
+
+func undo(cmd *cobra.Command, args []string) {
+	prStyle := determinePrintStyle(cmd)
+	yes, _ := cmd.Flags().GetBool("yes")
+	if prStyle == psJSON && !yes {
+		Die("--json requires --yes: cannot prompt for confirmation")
+	}
+	if !git.IsRepo() {
+		Die(ginerrors.NotInRepo)
+	}
+	reporoot, _ := git.FindRepoRoot(".")
+	os.Chdir(reporoot)
+
+	op, ok, err := ginclient.LastOperation()
+	CheckError(err)
+	if !ok {
+		Die("there are no operations to undo")
+	}
+	plan, err := ginclient.UndoPlan(op)
+	if err != nil {
+		Die(fmt.Sprintf("cannot undo '%s' from %s: %s", op.Command, op.Time.Format("Mon Jan 2 15:04:05 2006"), err.Error()))
+	}
+
+	if prStyle != psJSON {
+		fmt.Printf(":: Last operation: '%s' at %s\n", op.Command, op.Time.Format("Mon Jan 2 15:04:05 2006 (-0700)"))
+		fmt.Println("   Undoing it will:")
+		for _, step := range plan {
+			fmt.Printf("   - %s\n", step)
+		}
+	}
+	if !yes {
+		var response string
+		fmt.Print("Proceed? [y/N]: ")
+		fmt.Scanln(&response)
+		if r := strings.ToLower(response); r != "y" && r != "yes" {
+			Exit("aborted")
+		}
+	}
+
+	if op.Command == ginclient.OpRemoveContent {
+		// downloading content requires access to the server
+		conf := config.Read()
+		gincl := ginclient.New(conf.DefaultServer)
+		requirelogin(cmd, gincl, prStyle != psJSON)
+		runUndo(gincl, op, prStyle)
+	} else {
+		runUndo(ginclient.New(""), op, prStyle)
+	}
+}
+
+func runUndo(gincl *ginclient.Client, op ginclient.Operation, prStyle printstyle) {
+	if prStyle == psDefault {
+		fmt.Printf(":: Undoing '%s'\n", op.Command)
+	}
+	undochan := make(chan git.RepoFileStatus)
+	go gincl.Undo(op, undochan)
+	filesuccess := printOutput(undochan, prStyle, len(op.Files))
+	dieOnFailures(filesuccess)
+	CheckError(ginclient.DropLastOperation())
+	if prStyle == psDefault {
+		fmt.Fprintf(color.Output, ":: Undo %s\n", green("OK"))
+	}
+}
+
+// UndoCmd sets up the 'undo' subcommand
+func UndoCmd() *cobra.Command {
+	description := "Undo the last operation that changed the local repository. The operations that can be undone are 'commit', 'version', 'lock', 'unlock', and 'remove-content'. Before anything is changed, the steps that will be performed are printed and confirmation is requested.\n\nUndoing a 'commit' removes the recorded version but keeps the changes in the local files. Undoing a 'version' restores the files that were rolled back. Versions that have already been uploaded cannot be undone. Undoing 'lock' and 'unlock' reverses the lock state of the affected files and undoing 'remove-content' downloads the content of the affected files again.\n\nOperations are undone one at a time, from the most recent one."
+	var cmd = &cobra.Command{
+		Use:                   "undo [--json] [--yes]",
+		Short:                 "Undo the last operation on the local repository",
+		Long:                  formatdesc(description, nil),
+		Args:                  cobra.NoArgs,
+		Run:                   undo,
+		DisableFlagsInUseLine: true,
+	}
+	cmd.Flags().Bool("json", false, jsonHelpMsg)
+	cmd.Flags().BoolP("yes", "y", false, "Do not ask for confirmation before undoing the operation.")
+	return cmd
+}
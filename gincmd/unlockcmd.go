@@ -42,7 +42,9 @@ func unlock(cmd *cobra.Command, args []string) {
 	nitems := countItemsLockChange(args)
 	unlockchan := make(chan git.RepoFileStatus)
 	go gincl.UnlockContent(args, unlockchan)
-	formatOutput(unlockchan, prStyle, nitems)
+	filesuccess := printOutput(unlockchan, prStyle, nitems)
+	recordFileOperation(ginclient.OpUnlock, filesuccess)
+	dieOnFailures(filesuccess)
 }
 
 // UnlockCmd sets up the file 'unlock' subcommand
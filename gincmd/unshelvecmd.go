@@ -0,0 +1,78 @@
+package gincmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	ginclient "github.com/G-Node/gin-cli/ginclient"
+	"github.com/G-Node/gin-cli/gincmd/ginerrors"
+	"github.com/G-Node/gin-cli/git"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// printUnshelved prints the results of an Unshelve operation and returns the number of conflicts.
+func printUnshelved(results []ginclient.UnshelvedFile, prStyle printstyle) (nconflicts int) {
+	for _, res := range results {
+		if res.Conflict {
+			nconflicts++
+		}
+		if prStyle == psJSON {
+			j, _ := json.Marshal(res)
+			fmt.Println(string(j))
+			continue
+		}
+		switch {
+		case res.Err != nil:
+			fmt.Fprintf(color.Output, "  %s: %s %s\n", res.Name, red("failed"), res.Err.Error())
+		case res.Conflict && res.Destination == "":
+			fmt.Fprintf(color.Output, "  %s: %s file was changed since it was deleted locally; keeping the new version\n", res.Name, yellow("conflict"))
+		case res.Conflict:
+			fmt.Fprintf(color.Output, "  %s: %s file was changed since it was shelved; local version restored as '%s'\n", res.Name, yellow("conflict"), res.Destination)
+		default:
+			fmt.Fprintf(color.Output, "  %s: %s (%s)\n", res.Name, green("restored"), res.State)
+		}
+	}
+	return
+}
+
+func unshelve(cmd *cobra.Command, args []string) {
+	prStyle := determinePrintStyle(cmd)
+	if !git.IsRepo() {
+		Die(ginerrors.NotInRepo)
+	}
+	var id string
+	if len(args) > 0 {
+		id = args[0]
+	}
+	reporoot, _ := git.FindRepoRoot(".")
+	workingdir, _ := os.Getwd()
+	os.Chdir(reporoot)
+	defer os.Chdir(workingdir)
+
+	if prStyle == psDefault {
+		fmt.Println(":: Restoring shelved changes")
+	}
+	results, err := ginclient.Unshelve(id)
+	printUnshelved(results, prStyle)
+	CheckError(err)
+}
+
+// UnshelveCmd sets up the 'unshelve' subcommand
+func UnshelveCmd() *cobra.Command {
+	description := "Restore changes that were set aside using the 'shelve' command. By default, the most recently shelved changes are restored.\n\nIf a shelved file was changed in the meantime (for instance, by a download), the file is not overwritten. Instead, the shelved version is restored next to it with the suffix '-shelved' added to its name. Restored changes are not staged and can be committed as usual."
+	args := map[string]string{
+		"<id>": "The ID of the shelf to restore, as shown by 'gin shelve --list'.",
+	}
+	var cmd = &cobra.Command{
+		Use:                   "unshelve [--json] [<id>]",
+		Short:                 "Restore shelved local changes",
+		Long:                  formatdesc(description, args),
+		Args:                  cobra.MaximumNArgs(1),
+		Run:                   unshelve,
+		DisableFlagsInUseLine: true,
+	}
+	cmd.Flags().Bool("json", false, jsonHelpMsg)
+	return cmd
+}
@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -159,13 +161,22 @@ func AnnexPull(remote string) error {
 		log.Write("Error during AnnexPull.")
 		log.Write("[Error]: %v", err)
 		logstd(stdout, stderr)
+		if unmerged, _ := LsUnmerged(); !IsDirect() && MergeInProgress() && len(unmerged) > 0 {
+			// leave the merge in progress so the conflicts can be resolved
+			// (not in direct mode, where git-annex manages the index)
+			names := make([]string, len(unmerged))
+			for idx, uf := range unmerged {
+				names[idx] = uf.Name
+			}
+			return fmt.Errorf("download failed: files changed locally and remotely and cannot be automatically merged (merge conflict):\n  %s\nUse 'gin resolve' to review and resolve the conflicts or 'gin resolve --abort' to cancel the download", strings.Join(names, ", "))
+		}
 		mergeAbort() // abort a potential failed merge attempt
 		// TODO: Use giterror
 		if strings.Contains(sstderr, "Permission denied") {
 			return fmt.Errorf("download failed: permission denied")
 		} else if strings.Contains(sstderr, "Host key verification failed") {
 			// Bad host key configured
-			return fmt.Errorf("download failed: server key does not match known host key")
+			return newHostKeyError("download failed", sstderr)
 		} else {
 			err = checkMergeErrors(sstdout, sstderr)
 			if err == nil {
@@ -211,6 +222,9 @@ func AnnexSync(content bool) error {
 		log.Write("Error during AnnexSync.")
 		log.Write("[Error]: %v", err)
 		logstd(stdout, stderr)
+		if MergeInProgress() {
+			return fmt.Errorf("%s\nThe merge could not be completed. Use 'gin resolve' to review and resolve the conflicts", string(stderr))
+		}
 		return fmt.Errorf(string(stderr))
 	}
 	return nil
@@ -276,7 +290,7 @@ func AnnexPush(paths []string, remote string, pushchan chan<- RepoFileStatus) {
 		if strings.Contains(sstderr, "Permission denied") {
 			errmsg = "upload failed: permission denied"
 		} else if strings.Contains(sstderr, "Host key verification failed") {
-			errmsg = "upload failed: server key does not match known host key"
+			errmsg = newHostKeyError("upload failed", sstderr).Error()
 		} else if strings.Contains(sstderr, "rejected") {
 			errmsg = "upload failed: changes were made on the server that have not been downloaded; run 'gin download' to update local copies"
 		}
@@ -357,6 +371,10 @@ func AnnexPush(paths []string, remote string, pushchan chan<- RepoFileStatus) {
 				continue
 			}
 			status.FileName = getresult.File
+			if status.FileName == "" {
+				// content retrieved by key (e.g., with --all)
+				status.FileName = getresult.Key
+			}
 			if getresult.Success {
 				status.Progress = progcomplete
 				status.Err = nil
@@ -450,6 +468,10 @@ func baseAnnexGet(cmdargs []string, getchan chan<- RepoFileStatus) {
 				continue
 			}
 			status.FileName = getresult.File
+			if status.FileName == "" {
+				// content retrieved by key (e.g., with --all)
+				status.FileName = getresult.Key
+			}
 			if getresult.Success {
 				status.Progress = progcomplete
 				status.Err = nil
@@ -462,6 +484,9 @@ func baseAnnexGet(cmdargs []string, getchan chan<- RepoFileStatus) {
 			}
 		} else {
 			status.FileName = progress.Action.File
+			if status.FileName == "" {
+				status.FileName = progress.Action.Key
+			}
 			status.Progress = progress.PercentProgress
 			dbytes := progress.ByteProgress - prevByteProgress
 			now := time.Now()
@@ -499,6 +524,15 @@ func AnnexGet(filepaths []string, getchan chan<- RepoFileStatus) {
 	baseAnnexGet(cmdargs, getchan)
 }
 
+// AnnexGetAll retrieves the content of all versions of all files (including files that no longer exist in the current version) from the given remote.
+// The status channel 'getchan' is closed when this function returns.
+// (git annex get --all --from <remote>)
+func AnnexGetAll(remote string, getchan chan<- RepoFileStatus) {
+	defer close(getchan)
+	cmdargs := []string{"get", "--all", "--json-progress", fmt.Sprintf("--from=%s", remote)}
+	baseAnnexGet(cmdargs, getchan)
+}
+
 // AnnexGetKey retrieves the content of a single specified key.
 // The status channel 'getchan' is closed when this function returns.
 // (git annex get)
@@ -922,6 +956,47 @@ func AnnexContentLocation(key string) (string, error) {
 	return sstdout, nil
 }
 
+// maxPointerSize is the maximum size of a file (or symlink target) that is
+// considered when checking for annex pointers.
+const maxPointerSize = 1024
+
+// AnnexKeyFromPointer returns the annex key referenced by the contents of an
+// annex pointer file or the target of an annex symlink.
+// The second return value is false if the contents do not refer to annexed content.
+func AnnexKeyFromPointer(content []byte) (string, bool) {
+	if len(content) > maxPointerSize {
+		return "", false
+	}
+	keypath := strings.TrimSpace(string(content))
+	// symlink targets on Windows may use backslashes
+	keypath = strings.Replace(keypath, "\\", "/", -1)
+	if !strings.Contains(keypath, "/annex/objects/") || strings.Contains(keypath, "\n") {
+		return "", false
+	}
+	_, key := path.Split(keypath)
+	if key == "" {
+		return "", false
+	}
+	return key, true
+}
+
+// AnnexKeySize returns the size of the content referenced by an annex key, as
+// recorded in the size field of the key (e.g., MD5-s1048576--<hash>).
+// The second return value is false if the key does not contain a size field.
+func AnnexKeySize(key string) (int64, bool) {
+	keyfields := strings.SplitN(key, "--", 2)
+	for _, field := range strings.Split(keyfields[0], "-")[1:] {
+		if strings.HasPrefix(field, "s") {
+			size, err := strconv.ParseInt(field[1:], 10, 64)
+			if err != nil {
+				return 0, false
+			}
+			return size, true
+		}
+	}
+	return 0, false
+}
+
 // AnnexFsck runs fsck (filesystem check) on the specified files, fixing any
 // issues with the annexed files in the working tree.
 func AnnexFsck(paths []string) error {
@@ -1088,7 +1163,8 @@ func AnnexCommand(args ...string) shell.Cmd {
 	// gitannexbin := config.Bin.GitAnnex
 	gitbin := config.Bin.Git
 	gitannexpath := config.Bin.GitAnnexPath
-	cmdargs := []string{"annex"}
+	// git passes configuration given with -c to the git commands run by git-annex
+	cmdargs := append(httpsArgs(), "annex")
 	cmdargs = append(cmdargs, args...)
 	cmd := shell.Command(gitbin, cmdargs...)
 	env := os.Environ()
@@ -1098,7 +1174,7 @@ func AnnexCommand(args ...string) shell.Cmd {
 		syspath += string(os.PathListSeparator) + gitannexpath
 		cmd.Env = append(cmd.Env, syspath)
 	}
-	cmd.Env = append(cmd.Env, sshEnv())
+	cmd.Env = append(cmd.Env, sshEnv(args))
 	cmd.Env = append(cmd.Env, "GIT_ANNEX_USE_GIT_SSH=1")
 	workingdir, _ := filepath.Abs(".")
 	log.Write("Running shell command (Dir: %s): %s", workingdir, strings.Join(cmd.Args, " "))
@@ -0,0 +1,59 @@
+package git
+
+import "testing"
+
+func TestAnnexKeyFromPointer(t *testing.T) {
+	key := "SHA256E-s1048576--5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03.dat"
+	tests := []struct {
+		content string
+		key     string
+		ok      bool
+	}{
+		// pointer file (unlocked or v6+ repositories)
+		{"/annex/objects/" + key + "\n", key, true},
+		// symlink target (locked files)
+		{"../../.git/annex/objects/Xq/9P/" + key + "/" + key, key, true},
+		// symlink target written on Windows
+		{"..\\.git\\annex\\objects\\Xq\\9P\\" + key + "\\" + key, key, true},
+		{"/annex/objects/", "", false},
+		{"regular file content\n", "", false},
+		{"/annex/objects/" + key + "\nmore content\n", "", false},
+		{"", "", false},
+	}
+	for _, test := range tests {
+		key, ok := AnnexKeyFromPointer([]byte(test.content))
+		if key != test.key || ok != test.ok {
+			t.Errorf("AnnexKeyFromPointer(%q) = (%q, %v); expected (%q, %v)", test.content, key, ok, test.key, test.ok)
+		}
+	}
+
+	large := make([]byte, maxPointerSize+1)
+	copy(large, "/annex/objects/"+key)
+	if _, ok := AnnexKeyFromPointer(large); ok {
+		t.Error("Content larger than the maximum pointer size was parsed as a pointer")
+	}
+}
+
+func TestAnnexKeySize(t *testing.T) {
+	tests := []struct {
+		key  string
+		size int64
+		ok   bool
+	}{
+		{"SHA256E-s1048576--5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03.dat", 1048576, true},
+		{"MD5-s0--d41d8cd98f00b204e9800998ecf8427e", 0, true},
+		// chunked and WORM keys have additional fields
+		{"SHA256E-s20971520-S1048576-C3--abcdef.bin", 20971520, true},
+		{"WORM-s1234-m1500000000--data-s99.csv", 1234, true},
+		// the size field is optional
+		{"URL--https&c%%example.org%data-s10.csv", 0, false},
+		{"SHA256E-sX--abcdef", 0, false},
+		{"", 0, false},
+	}
+	for _, test := range tests {
+		size, ok := AnnexKeySize(test.key)
+		if size != test.size || ok != test.ok {
+			t.Errorf("AnnexKeySize(%q) = (%d, %v); expected (%d, %v)", test.key, size, ok, test.size, test.ok)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/G-Node/gin-cli/ginclient/config"
+	"github.com/G-Node/gin-cli/ginclient/log"
+)
+
+// CredentialHelperCmd is the gin subcommand that implements the git credential helper protocol.
+// It provides the stored login token to git for servers that use HTTPS for git operations.
+const CredentialHelperCmd = "git-credential"
+
+// shellQuote quotes a string for use in a git credential helper command line, which is run by the shell.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// httpsArgs returns the git configuration arguments for each server that
+// uses HTTPS for git operations. They set up the gin credential helper and
+// apply the connection settings of the server (CA bundle, certificate
+// verification, client certificate, and proxy).
+// The helper is scoped to the server address and any other helpers
+// configured for the same address are disabled, so that git always uses the
+// token of the current login.
+func httpsArgs() []string {
+	servers := make(map[string]config.WebCfg)
+	var addresses []string
+	for _, srvcfg := range config.Read().Servers {
+		if srvcfg.Git.UseHTTPS() {
+			address := srvcfg.GitAddressStr()
+			servers[address] = srvcfg.Web
+			addresses = append(addresses, address)
+		}
+	}
+	if len(addresses) == 0 {
+		return nil
+	}
+	sort.Strings(addresses)
+	var helper string
+	if ginbin, err := os.Executable(); err == nil {
+		helper = fmt.Sprintf("!%s %s", shellQuote(filepath.ToSlash(ginbin)), CredentialHelperCmd)
+	} else {
+		log.Write("Failed to determine gin executable path for git credential helper: %v", err)
+	}
+	var args []string
+	for _, address := range addresses {
+		if helper != "" {
+			key := fmt.Sprintf("credential.%s.helper", address)
+			// an empty value clears the list of helpers configured so far
+			args = append(args, "-c", key+"=", "-c", key+"="+helper)
+		}
+		webconf := servers[address]
+		httpopt := func(name, value string) {
+			args = append(args, "-c", fmt.Sprintf("http.%s.%s=%s", address, name, value))
+		}
+		if webconf.CABundle != "" {
+			httpopt("sslCAInfo", ExpandHome(webconf.CABundle))
+		}
+		if webconf.Insecure {
+			httpopt("sslVerify", "false")
+		}
+		if webconf.ClientCert != "" {
+			httpopt("sslCert", ExpandHome(webconf.ClientCert))
+		}
+		if webconf.ClientKey != "" {
+			httpopt("sslKey", ExpandHome(webconf.ClientKey))
+		}
+		if webconf.Proxy != "" {
+			httpopt("proxy", webconf.Proxy)
+		}
+	}
+	return args
+}
@@ -0,0 +1,87 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/G-Node/gin-cli/ginclient/config"
+)
+
+// testServerConfig sets up a configuration directory with the given server configurations in addition to the default server.
+func testServerConfig(t *testing.T, servers map[string]config.ServerCfg) string {
+	confdir := t.TempDir()
+	os.Setenv("GIN_CONFIG_DIR", confdir)
+	os.Setenv("GIN_SYSTEM_CONFIG", filepath.Join(confdir, "system.yml"))
+	cwd, _ := os.Getwd()
+	os.Chdir(confdir)
+	t.Cleanup(func() {
+		os.Unsetenv("GIN_CONFIG_DIR")
+		os.Unsetenv("GIN_SYSTEM_CONFIG")
+		os.Chdir(cwd)
+	})
+	if servers == nil {
+		servers = make(map[string]config.ServerCfg)
+	}
+	// writing the configuration also invalidates the configuration read by previous tests
+	if err := config.SetConfig("servers", servers); err != nil {
+		t.Fatalf("Failed to write server configuration: %v", err)
+	}
+	return confdir
+}
+
+func TestHTTPSArgs(t *testing.T) {
+	home, _ := os.UserHomeDir()
+	testServerConfig(t, map[string]config.ServerCfg{
+		"web": {
+			Web: config.WebCfg{Protocol: "https", Host: "gin.example.org", Port: 443},
+			Git: config.GitCfg{Protocol: config.GitProtocolHTTPS},
+		},
+		"lab": {
+			Web: config.WebCfg{Protocol: "https", Host: "lab.example.org", Port: 3000, CABundle: "~/ca.pem", Insecure: true, Proxy: "http://proxy:8080"},
+			Git: config.GitCfg{Protocol: config.GitProtocolHTTPS},
+		},
+		// same web host as 'web', but git over SSH
+		"ssh": {
+			Web: config.WebCfg{Protocol: "https", Host: "gin.example.org", Port: 443},
+			Git: config.GitCfg{Protocol: config.GitProtocolSSH, User: "git", Host: "gin.example.org", Port: 22},
+		},
+	})
+
+	args := httpsArgs()
+	var values []string
+	for idx := 0; idx < len(args); idx += 2 {
+		if args[idx] != "-c" {
+			t.Fatalf("Unexpected argument %q in %v", args[idx], args)
+		}
+		values = append(values, args[idx+1])
+	}
+	ginbin, _ := os.Executable()
+	helper := "!'" + filepath.ToSlash(ginbin) + "' " + CredentialHelperCmd
+	// servers in sorted order of their addresses; each helper list is cleared before the gin helper is added
+	expected := []string{
+		"credential.https://gin.example.org:443.helper=",
+		"credential.https://gin.example.org:443.helper=" + helper,
+		"credential.https://lab.example.org:3000.helper=",
+		"credential.https://lab.example.org:3000.helper=" + helper,
+		"http.https://lab.example.org:3000.sslCAInfo=" + filepath.Join(home, "ca.pem"),
+		"http.https://lab.example.org:3000.sslVerify=false",
+		"http.https://lab.example.org:3000.proxy=http://proxy:8080",
+	}
+	if strings.Join(values, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("Unexpected arguments:\n%s\nexpected:\n%s", strings.Join(values, "\n"), strings.Join(expected, "\n"))
+	}
+	for _, value := range values {
+		if strings.Contains(value, "ssh://") {
+			t.Errorf("Credential configuration for SSH server: %s", value)
+		}
+	}
+}
+
+func TestHTTPSArgsNone(t *testing.T) {
+	testServerConfig(t, nil)
+	if args := httpsArgs(); len(args) != 0 {
+		t.Fatalf("Expected no arguments without HTTPS servers, got %v", args)
+	}
+}
@@ -57,6 +57,7 @@ type GinCommit struct {
 	Subject         string    `json:"subject"`
 	Body            string    `json:"body"`
 	FileStats       DiffStat
+	AnnexStats      AnnexDiffStat `json:"annexstats"`
 }
 
 type DiffStat struct {
@@ -190,7 +191,10 @@ func Clone(remotepath string, repopath string, clonechan chan<- RepoFileStatus)
 			gerr.Description = fmt.Sprintf("Repository download failed.\n"+
 				"'%s' already exists in the current directory and is not empty.", repoName)
 		} else if strings.Contains(errstring, "Host key verification failed") {
-			gerr.Description = "Server key does not match known/configured host key."
+			gerr.Description = newHostKeyError("Repository download failed", errstring).Error()
+		} else if strings.Contains(errstring, "Authentication failed") {
+			gerr.Description = "Repository download failed: the server did not accept the stored login token\n" +
+				"Log in again using 'gin login'"
 		} else {
 			gerr.Description = fmt.Sprintf("Repository download failed. Internal git command returned: %s", errstring)
 		}
@@ -504,6 +508,131 @@ func RevParse(rev string) (string, error) {
 	return string(stdout), nil
 }
 
+// LastCommit returns the hash of the last commit that changed the given path.
+// It returns an empty string if the path has never been committed.
+// (git log -n1 --format=%H -- <path>)
+func LastCommit(path string) (string, error) {
+	fn := fmt.Sprintf("LastCommit(%s)", path)
+	cmd := Command("log", "-n1", "--format=%H", "--", path)
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		log.Write("Error during log command")
+		logstd(stdout, stderr)
+		return "", giterror{UError: string(stderr), Origin: fn}
+	}
+	return strings.TrimSpace(string(stdout)), nil
+}
+
+// StatusEntry describes the state of a changed file in the index and the working tree.
+// Index and WorkTree hold the two-letter status codes of git status --porcelain.
+type StatusEntry struct {
+	Name string
+	// OrigName is the original name of a renamed or copied file.
+	OrigName string
+	Index    byte
+	WorkTree byte
+}
+
+// Untracked returns true if the file is not tracked by git.
+func (e StatusEntry) Untracked() bool {
+	return e.Index == '?' && e.WorkTree == '?'
+}
+
+// StatusPorcelain returns the files that have changes in the index or the
+// working tree. Untracked files are listed individually if untracked is true.
+// Ignored files are never listed.
+// File names are relative to the root of the repository.
+// (git status --porcelain)
+func StatusPorcelain(untracked bool) ([]StatusEntry, error) {
+	fn := fmt.Sprintf("StatusPorcelain(%v)", untracked)
+	untrackedopt := "--untracked-files=no"
+	if untracked {
+		untrackedopt = "--untracked-files=all"
+	}
+	cmd := Command("status", "--porcelain", "-z", untrackedopt)
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		log.Write("Error during git status")
+		logstd(stdout, stderr)
+		return nil, giterror{UError: string(stderr), Origin: fn}
+	}
+	return parseStatusPorcelain(string(stdout)), nil
+}
+
+// parseStatusPorcelain parses the NUL-separated output of status --porcelain (with -z).
+func parseStatusPorcelain(output string) []StatusEntry {
+	var entries []StatusEntry
+	items := strings.Split(output, "\000")
+	for idx := 0; idx < len(items); idx++ {
+		// XY SP <path> [NUL <origpath>]
+		item := items[idx]
+		if len(item) < 4 {
+			continue
+		}
+		entry := StatusEntry{Index: item[0], WorkTree: item[1], Name: item[3:]}
+		if entry.Index == 'R' || entry.Index == 'C' {
+			// next item is the original path
+			idx++
+			if idx < len(items) {
+				entry.OrigName = items[idx]
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// ResetPaths resets the index entries for the given paths to their state in
+// HEAD, unstaging any changes. The working tree is not modified.
+// (git reset -- <paths>)
+func ResetPaths(paths []string) error {
+	cmdargs := append([]string{"reset", "--quiet", "--"}, paths...)
+	cmd := Command(cmdargs...)
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		log.Write("Error during git reset")
+		logstd(stdout, stderr)
+		return giterror{UError: string(stderr), Origin: "ResetPaths()", Description: "failed to unstage changes"}
+	}
+	return nil
+}
+
+// Reset moves the current branch to the given revision.
+// The mode is one of the git reset modes (e.g., "soft", "mixed", "keep") and
+// determines how the index and working tree are updated.
+// (git reset --<mode>)
+func Reset(rev, mode string) error {
+	fn := fmt.Sprintf("Reset(%s, %s)", rev, mode)
+	cmd := Command("reset", "--quiet", fmt.Sprintf("--%s", mode), rev)
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		log.Write("Error during git reset")
+		logstd(stdout, stderr)
+		return giterror{UError: string(stderr), Origin: fn, Description: "failed to reset to previous version"}
+	}
+	return nil
+}
+
+// RemoteBranchesContaining returns the names of the remote tracking branches that contain the given commit.
+// (git branch --remotes --contains)
+func RemoteBranchesContaining(commit string) ([]string, error) {
+	fn := fmt.Sprintf("RemoteBranchesContaining(%s)", commit)
+	cmd := Command("branch", "--remotes", "--format=%(refname:short)", "--contains", commit)
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		log.Write("Error during git branch --contains")
+		logstd(stdout, stderr)
+		return nil, giterror{UError: string(stderr), Origin: fn}
+	}
+	var branches []string
+	for _, line := range strings.Split(string(stdout), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			branches = append(branches, line)
+		}
+	}
+	return branches, nil
+}
+
 // IsRepo checks whether the current working directory is in a git repository.
 // This function will also return true for bare repositories that use git annex (direct mode).
 func IsRepo() bool {
@@ -687,19 +816,46 @@ func DescribeIndex() (string, error) {
 	return changesBuffer.String(), nil
 }
 
-// Log returns the commit logs for the repository.
-// The number of commits can be limited by the count argument.
-// If count <= 0, the entire commit history is returned.
-// Revisions which match only the deletion of the matching paths can be filtered using the showdeletes argument.
-func Log(count uint, revrange string, paths []string, showdeletes bool) ([]GinCommit, error) {
-	logformat := `{"hash":"%H","abbrevhash":"%h","authorname":"%an","authoremail":"%ae","date":"%aI","subject":"%s","body":"%b"}`
-	cmdargs := []string{"log", "-z", fmt.Sprintf("--format=%s", logformat)}
+// LogFilter holds optional criteria for limiting the commits returned by LogFiltered.
+// Empty fields are ignored.
+type LogFilter struct {
+	// Author limits commits to those whose author name or email matches the given pattern.
+	Author string
+	// Since and Until limit commits by date. Any date format understood by git is accepted (e.g., "2019-03-01", "2 weeks ago").
+	Since string
+	Until string
+	// Grep limits commits to those whose message matches the given pattern.
+	Grep string
+}
+
+// args returns the git log options for the filter.
+func (f LogFilter) args() []string {
+	var args []string
+	if f.Author != "" {
+		args = append(args, fmt.Sprintf("--author=%s", f.Author))
+	}
+	if f.Since != "" {
+		args = append(args, fmt.Sprintf("--since=%s", f.Since))
+	}
+	if f.Until != "" {
+		args = append(args, fmt.Sprintf("--until=%s", f.Until))
+	}
+	if f.Grep != "" {
+		args = append(args, fmt.Sprintf("--grep=%s", f.Grep), "--regexp-ignore-case")
+	}
+	return args
+}
+
+// logArgs builds the arguments for a git log invocation with the given format options.
+func logArgs(formatargs []string, count uint, revrange string, paths []string, showdeletes bool, filter LogFilter) []string {
+	cmdargs := append([]string{"log"}, formatargs...)
 	if count > 0 {
 		cmdargs = append(cmdargs, fmt.Sprintf("--max-count=%d", count))
 	}
 	if !showdeletes {
 		cmdargs = append(cmdargs, "--diff-filter=d")
 	}
+	cmdargs = append(cmdargs, filter.args()...)
 	if revrange != "" {
 		cmdargs = append(cmdargs, revrange)
 	}
@@ -708,6 +864,22 @@ func Log(count uint, revrange string, paths []string, showdeletes bool) ([]GinCo
 	if paths != nil && len(paths) > 0 {
 		cmdargs = append(cmdargs, paths...)
 	}
+	return cmdargs
+}
+
+// Log returns the commit logs for the repository.
+// The number of commits can be limited by the count argument.
+// If count <= 0, the entire commit history is returned.
+// Revisions which match only the deletion of the matching paths can be filtered using the showdeletes argument.
+func Log(count uint, revrange string, paths []string, showdeletes bool) ([]GinCommit, error) {
+	return LogFiltered(count, revrange, paths, showdeletes, LogFilter{})
+}
+
+// LogFiltered returns the commit logs for the repository, limited to the commits matching the given filter.
+// The remaining arguments are the same as for Log.
+func LogFiltered(count uint, revrange string, paths []string, showdeletes bool, filter LogFilter) ([]GinCommit, error) {
+	logformat := `{"hash":"%H","abbrevhash":"%h","authorname":"%an","authoremail":"%ae","date":"%aI","subject":"%s","body":"%b"}`
+	cmdargs := logArgs([]string{"-z", fmt.Sprintf("--format=%s", logformat)}, count, revrange, paths, showdeletes, filter)
 	cmd := Command(cmdargs...)
 	err := cmd.Start()
 	if err != nil {
@@ -753,7 +925,7 @@ func Log(count uint, revrange string, paths []string, showdeletes bool) ([]GinCo
 	}
 
 	// TODO: Combine diffstats into first git log invocation
-	logstats, err := LogDiffStat(count, paths, showdeletes)
+	logstats, err := logDiffStat(count, revrange, paths, showdeletes, filter)
 	if err != nil {
 		log.Write("Failed to get diff stats")
 		return commits, nil
@@ -766,19 +938,14 @@ func Log(count uint, revrange string, paths []string, showdeletes bool) ([]GinCo
 	return commits, nil
 }
 
+// LogDiffStat returns the names of the files added, modified, and deleted by each commit, keyed by commit hash.
 func LogDiffStat(count uint, paths []string, showdeletes bool) (map[string]DiffStat, error) {
+	return logDiffStat(count, "", paths, showdeletes, LogFilter{})
+}
+
+func logDiffStat(count uint, revrange string, paths []string, showdeletes bool, filter LogFilter) (map[string]DiffStat, error) {
 	logformat := `::%H`
-	cmdargs := []string{"log", fmt.Sprintf("--format=%s", logformat), "--name-status"}
-	if count > 0 {
-		cmdargs = append(cmdargs, fmt.Sprintf("--max-count=%d", count))
-	}
-	if !showdeletes {
-		cmdargs = append(cmdargs, "--diff-filter=d")
-	}
-	cmdargs = append(cmdargs, "--") // separate revisions from paths, even if there are no paths
-	if paths != nil && len(paths) > 0 {
-		cmdargs = append(cmdargs, paths...)
-	}
+	cmdargs := logArgs([]string{fmt.Sprintf("--format=%s", logformat), "--name-status"}, count, revrange, paths, showdeletes, filter)
 	cmd := Command(cmdargs...)
 	err := cmd.Start()
 	if err != nil {
@@ -832,6 +999,166 @@ func LogDiffStat(count uint, paths []string, showdeletes bool) (map[string]DiffS
 	return stats, nil
 }
 
+// AnnexDiffStat holds the amount of annexed data added and removed by a commit, in bytes.
+// Modified files count towards both: the size of the new content is added and the size of the old content is removed.
+type AnnexDiffStat struct {
+	Added   int64 `json:"added"`
+	Removed int64 `json:"removed"`
+}
+
+// DiffEntry is a single line of the raw diff output format: a changed file and the objects on each side of the diff.
+// The hash of a missing side (added or deleted file, or changed file in the working tree) is NullHash.
+type DiffEntry struct {
+	OldMode string
+	NewMode string
+	OldHash string
+	NewHash string
+	Status  string
+	Name    string
+}
+
+// NullHash is the object name used by git for a missing (or not yet hashed) side of a diff.
+const NullHash = "0000000000000000000000000000000000000000"
+
+// parseRawDiff parses the NUL-separated output of a diff in --raw format (with -z).
+// Any other lines (e.g., the '::<hash>' commit headers used by LogAnnexStat)
+// are returned in the order they appear, along with the entries that follow them.
+func parseRawDiff(output string) (headers []string, entries [][]DiffEntry) {
+	items := strings.Split(output, "\000")
+	cur := -1
+	for idx := 0; idx < len(items); idx++ {
+		item := strings.Trim(items[idx], "\n")
+		if item == "" {
+			continue
+		}
+		if !strings.HasPrefix(item, ":") || strings.HasPrefix(item, "::") {
+			headers = append(headers, item)
+			entries = append(entries, nil)
+			cur++
+			continue
+		}
+		// :<old mode> SP <new mode> SP <old hash> SP <new hash> SP <status> NUL <path>
+		fields := strings.Fields(strings.TrimPrefix(item, ":"))
+		// skip the path of malformed entries so it is not taken for a header
+		idx++
+		if len(fields) < 5 || idx >= len(items) {
+			continue
+		}
+		entry := DiffEntry{OldMode: fields[0], NewMode: fields[1], OldHash: fields[2], NewHash: fields[3], Status: fields[4][:1], Name: items[idx]}
+		if entry.Status == "R" || entry.Status == "C" {
+			// renames and copies are followed by the new path
+			idx++
+			if idx < len(items) {
+				entry.Name = items[idx]
+			}
+		}
+		if cur < 0 {
+			headers = append(headers, "")
+			entries = append(entries, nil)
+			cur++
+		}
+		entries[cur] = append(entries[cur], entry)
+	}
+	return
+}
+
+// DiffRaw returns the files that differ between two revisions.
+// If rev2 is empty, rev1 is compared to the working tree.
+// If both are empty, HEAD is compared to the working tree.
+// Renames are reported as deletions and additions.
+// (git diff --raw)
+func DiffRaw(rev1, rev2 string, paths []string) ([]DiffEntry, error) {
+	cmdargs := append([]string{"diff", "--raw", "-z", "--no-abbrev", "--no-renames"}, diffRevArgs(rev1, rev2)...)
+	cmdargs = append(cmdargs, "--")
+	cmdargs = append(cmdargs, paths...)
+	cmd := Command(cmdargs...)
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		log.Write("Error during DiffRaw")
+		logstd(stdout, stderr)
+		return nil, giterror{UError: string(stderr), Origin: "DiffRaw()", Description: "failed to compare versions"}
+	}
+	_, entries := parseRawDiff(string(stdout))
+	var diff []DiffEntry
+	for _, e := range entries {
+		diff = append(diff, e...)
+	}
+	return diff, nil
+}
+
+// DiffText returns the textual (patch) diff of the given paths between two revisions.
+// The revisions are interpreted as in DiffRaw.
+// (git diff)
+func DiffText(rev1, rev2 string, paths []string) (string, error) {
+	cmdargs := append([]string{"diff", "--no-ext-diff", "--no-renames"}, diffRevArgs(rev1, rev2)...)
+	cmdargs = append(cmdargs, "--")
+	cmdargs = append(cmdargs, paths...)
+	cmd := Command(cmdargs...)
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		log.Write("Error during DiffText")
+		logstd(stdout, stderr)
+		return "", giterror{UError: string(stderr), Origin: "DiffText()", Description: "failed to compare versions"}
+	}
+	return string(stdout), nil
+}
+
+func diffRevArgs(rev1, rev2 string) []string {
+	if rev1 == "" {
+		rev1 = "HEAD"
+	}
+	if rev2 == "" {
+		return []string{rev1}
+	}
+	return []string{rev1, rev2}
+}
+
+// LogAnnexStat returns the amount of annexed data added and removed by each commit, keyed by commit hash.
+// The sizes are determined from the annex keys of the files changed by each commit, so the annexed content does not need to be available locally.
+// The arguments are the same as for LogFiltered.
+func LogAnnexStat(count uint, revrange string, paths []string, showdeletes bool, filter LogFilter) (map[string]AnnexDiffStat, error) {
+	cmdargs := logArgs([]string{"-z", "--format=::%H", "--raw", "--no-abbrev", "--no-renames"}, count, revrange, paths, showdeletes, filter)
+	cmd := Command(cmdargs...)
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		log.Write("Error during LogAnnexStat")
+		logstd(stdout, stderr)
+		return nil, fmt.Errorf("%s", string(stderr))
+	}
+
+	headers, entries := parseRawDiff(string(stdout))
+	var hashes []string
+	for _, commitentries := range entries {
+		for _, entry := range commitentries {
+			for _, hash := range []string{entry.OldHash, entry.NewHash} {
+				if hash != NullHash {
+					hashes = append(hashes, hash)
+				}
+			}
+		}
+	}
+	blobinfo, err := CatFileBlobInfo(hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make(map[string]AnnexDiffStat, len(headers))
+	for idx, header := range headers {
+		hash := strings.TrimPrefix(header, "::")
+		var stat AnnexDiffStat
+		for _, entry := range entries[idx] {
+			if info, ok := blobinfo[entry.NewHash]; ok && info.AnnexKey != "" {
+				stat.Added += info.ContentSize()
+			}
+			if info, ok := blobinfo[entry.OldHash]; ok && info.AnnexKey != "" {
+				stat.Removed += info.ContentSize()
+			}
+		}
+		stats[hash] = stat
+	}
+	return stats, nil
+}
+
 // Checkout performs a git checkout of a specific commit.
 // Individual files or directories may be specified, otherwise the entire tree is checked out.
 func Checkout(hash string, paths []string) error {
@@ -925,6 +1252,97 @@ func CatFileType(object string) (string, error) {
 	return string(stdout), nil
 }
 
+// BlobInfo holds the size of a blob object and the annex key it refers to, if
+// the blob is an annex pointer file or a symlink to annexed content.
+type BlobInfo struct {
+	Hash     string `json:"hash"`
+	Size     int64  `json:"size"`
+	AnnexKey string `json:"annexkey,omitempty"`
+}
+
+// ContentSize returns the size of the file content the blob represents.
+// For annexed files this is the size recorded in the annex key, otherwise it is the size of the blob itself.
+func (b BlobInfo) ContentSize() int64 {
+	if b.AnnexKey != "" {
+		if size, ok := AnnexKeySize(b.AnnexKey); ok {
+			return size
+		}
+	}
+	return b.Size
+}
+
+// CatFileBlobInfo returns the size and annex key (if any) for each of the given blob hashes.
+// Only blobs small enough to be annex pointers are read in full.
+// (git cat-file --batch-check; git cat-file --batch)
+func CatFileBlobInfo(hashes []string) (map[string]BlobInfo, error) {
+	infos := make(map[string]BlobInfo, len(hashes))
+	if len(hashes) == 0 {
+		return infos, nil
+	}
+
+	cmd := Command("cat-file", "--batch-check")
+	cmd.Stdin = strings.NewReader(strings.Join(hashes, "\n") + "\n")
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		log.Write("Error during GitCatFile (batch-check)")
+		logstd(stdout, stderr)
+		return nil, fmt.Errorf("%s", string(stderr))
+	}
+
+	var pointers []string
+	for _, line := range strings.Split(string(stdout), "\n") {
+		// <object> SP <type> SP <size>
+		words := strings.Fields(line)
+		if len(words) < 3 || words[1] != "blob" {
+			continue
+		}
+		size, perr := strconv.ParseInt(words[2], 10, 64)
+		if perr != nil {
+			continue
+		}
+		infos[words[0]] = BlobInfo{Hash: words[0], Size: size}
+		if size <= maxPointerSize {
+			pointers = append(pointers, words[0])
+		}
+	}
+	if len(pointers) == 0 {
+		return infos, nil
+	}
+
+	cmd = Command("cat-file", "--batch")
+	cmd.Stdin = strings.NewReader(strings.Join(pointers, "\n") + "\n")
+	stdout, stderr, err = cmd.OutputError()
+	if err != nil {
+		log.Write("Error during GitCatFile (batch)")
+		logstd(stdout, stderr)
+		return nil, fmt.Errorf("%s", string(stderr))
+	}
+	for len(stdout) > 0 {
+		// <object> SP <type> SP <size> LF <contents> LF
+		nlidx := bytes.IndexByte(stdout, '\n')
+		if nlidx < 0 {
+			break
+		}
+		words := strings.Fields(string(stdout[:nlidx]))
+		stdout = stdout[nlidx+1:]
+		if len(words) < 3 {
+			continue
+		}
+		size, perr := strconv.Atoi(words[2])
+		if perr != nil || size > len(stdout) {
+			break
+		}
+		content := stdout[:size]
+		stdout = bytes.TrimPrefix(stdout[size:], []byte("\n"))
+		if key, ok := AnnexKeyFromPointer(content); ok {
+			info := infos[words[0]]
+			info.AnnexKey = key
+			infos[words[0]] = info
+		}
+	}
+	return infos, nil
+}
+
 // RevCount returns the number of commits between two revisions.
 func RevCount(a, b string) (int, error) {
 	cmd := Command("rev-list", "--count", fmt.Sprintf("%s..%s", a, b))
@@ -975,7 +1393,15 @@ func IsVersion6() bool {
 }
 
 // mergeAbort aborts an unfinished git merge.
+// Any error is logged and ignored.
 func mergeAbort() {
+	_ = MergeAbort()
+}
+
+// MergeAbort aborts an unfinished git merge and restores the state of the
+// repository from before the merge started.
+// (git merge --abort)
+func MergeAbort() error {
 	// Here, we run a git status without checking any part of the result. It
 	// seems git-annex performs some cleanup or consistency fixes to the index
 	// when git status is run and before that, the merge --abort fails.
@@ -983,9 +1409,146 @@ func mergeAbort() {
 	cmd := Command("merge", "--abort")
 	stdout, stderr, err := cmd.OutputError()
 	if err != nil {
-		// log error but do nothing
 		logstd(stdout, stderr)
+		return giterror{UError: string(stderr), Origin: "MergeAbort()", Description: "failed to abort merge"}
+	}
+	return nil
+}
+
+// MergeHead returns the hash of the commit that is being merged into the
+// current branch (MERGE_HEAD).
+// An error is returned if there is no merge in progress.
+func MergeHead() (string, error) {
+	cmd := Command("rev-parse", "--verify", "--quiet", "MERGE_HEAD")
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		logstd(stdout, stderr)
+		return "", fmt.Errorf("no merge in progress")
+	}
+	return strings.TrimSpace(string(stdout)), nil
+}
+
+// MergeInProgress returns true if the repository is in the middle of an unfinished merge.
+func MergeInProgress() bool {
+	_, err := MergeHead()
+	return err == nil
+}
+
+// UnmergedFile describes a file with an unresolved merge conflict.
+// The Local and Remote objects describe the version of the file on each side
+// of the merge. If a file was deleted on one side, the corresponding object
+// has an empty Hash.
+type UnmergedFile struct {
+	Name   string
+	Base   Object
+	Local  Object
+	Remote Object
+}
+
+// LsUnmerged returns the files that have unresolved merge conflicts in the index.
+// File names are relative to the root of the repository.
+// (git ls-files --unmerged)
+func LsUnmerged() ([]UnmergedFile, error) {
+	fn := "LsUnmerged()"
+	cmd := Command("ls-files", "-z", "--unmerged", "--full-name")
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		log.Write("Error during ls-files --unmerged")
+		logstd(stdout, stderr)
+		return nil, giterror{UError: string(stderr), Origin: fn}
+	}
+	return parseLsUnmerged(string(stdout)), nil
+}
+
+// parseLsUnmerged parses the NUL-separated output of ls-files --unmerged (with -z).
+// Files are returned in the order they appear.
+func parseLsUnmerged(output string) []UnmergedFile {
+	filemap := make(map[string]*UnmergedFile)
+	var names []string
+	for _, line := range strings.Split(output, "\000") {
+		// <mode> SP <object> SP <stage> TAB <file>
+		fnamesplit := strings.SplitN(line, "\t", 2)
+		if len(fnamesplit) < 2 {
+			continue
+		}
+		words := strings.Fields(fnamesplit[0])
+		if len(words) < 3 {
+			continue
+		}
+		fname := fnamesplit[1]
+		uf, ok := filemap[fname]
+		if !ok {
+			uf = &UnmergedFile{Name: fname}
+			filemap[fname] = uf
+			names = append(names, fname)
+		}
+		obj := Object{Name: fname, Mode: words[0], Hash: words[1], Type: "blob"}
+		switch words[2] {
+		case "1":
+			uf.Base = obj
+		case "2":
+			uf.Local = obj
+		case "3":
+			uf.Remote = obj
+		}
+	}
+
+	unmerged := make([]UnmergedFile, len(names))
+	for idx, fname := range names {
+		unmerged[idx] = *filemap[fname]
+	}
+	return unmerged
+}
+
+// UpdateIndexEntry sets the index entry for a path to the given object,
+// marking any merge conflict for that path as resolved.
+// If the object hash is empty, the path is removed from the index.
+// The working tree is not modified (see CheckoutIndex).
+// (git update-index --cacheinfo | --force-remove)
+func UpdateIndexEntry(fname string, obj Object) error {
+	fn := fmt.Sprintf("UpdateIndexEntry(%s)", fname)
+	var cmd shell.Cmd
+	if obj.Hash == "" {
+		cmd = Command("update-index", "--force-remove", "--", fname)
+	} else {
+		cacheinfo := fmt.Sprintf("%s,%s,%s", obj.Mode, obj.Hash, fname)
+		cmd = Command("update-index", "--add", "--cacheinfo", cacheinfo)
+	}
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		log.Write("Error during update-index")
+		logstd(stdout, stderr)
+		return giterror{UError: string(stderr), Origin: fn, Description: fmt.Sprintf("failed to update index entry for '%s'", fname)}
 	}
+	return nil
+}
+
+// CheckoutIndex updates the files in the working tree to match the version in the index.
+// (git checkout -- <paths>)
+func CheckoutIndex(paths []string) error {
+	cmdargs := append([]string{"checkout", "--"}, paths...)
+	cmd := Command(cmdargs...)
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		log.Write("Error during checkout from index")
+		logstd(stdout, stderr)
+		return fmt.Errorf("%s", string(stderr))
+	}
+	return nil
+}
+
+// CommitMerge concludes a merge once all conflicts have been resolved, using
+// the default merge commit message.
+// (git commit --no-edit)
+func CommitMerge() error {
+	cmd := Command("commit", "--no-edit")
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		log.Write("Error during merge commit")
+		logstd(stdout, stderr)
+		return giterror{UError: string(stderr), Origin: "CommitMerge()", Description: "failed to record merge"}
+	}
+	return nil
 }
 
 func SetBare(state bool) {
@@ -1069,9 +1632,10 @@ func Command(args ...string) shell.Cmd {
 	config := config.Read()
 	gitbin := config.Bin.Git
 	cmd := shell.Command(gitbin)
+	cmd.Args = append(cmd.Args, httpsArgs()...)
 	cmd.Args = append(cmd.Args, args...)
 	env := os.Environ()
-	cmd.Env = append(env, sshEnv())
+	cmd.Env = append(env, sshEnv(args))
 	workingdir, _ := filepath.Abs(".")
 	log.Write("Running shell command (Dir: %s): %s", workingdir, strings.Join(cmd.Args, " "))
 	return cmd
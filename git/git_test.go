@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -68,3 +69,187 @@ func TestInit(t *testing.T) {
 		t.Fatalf("Expected bare repository: %s", bare)
 	}
 }
+
+func TestParseLsUnmerged(t *testing.T) {
+	const (
+		base   = "1111111111111111111111111111111111111111"
+		local  = "2222222222222222222222222222222222222222"
+		remote = "3333333333333333333333333333333333333333"
+	)
+	output := "100644 " + base + " 1\tdata/both.csv\000" +
+		"100644 " + local + " 2\tdata/both.csv\000" +
+		"100644 " + remote + " 3\tdata/both.csv\000" +
+		"100644 " + base + " 1\tdeleted remotely.txt\000" +
+		"120000 " + local + " 2\tdeleted remotely.txt\000" +
+		"100644 " + remote + " 3\tadded\ttab.txt\000"
+
+	unmerged := parseLsUnmerged(output)
+	expected := []UnmergedFile{
+		{
+			Name:   "data/both.csv",
+			Base:   Object{Name: "data/both.csv", Mode: "100644", Hash: base, Type: "blob"},
+			Local:  Object{Name: "data/both.csv", Mode: "100644", Hash: local, Type: "blob"},
+			Remote: Object{Name: "data/both.csv", Mode: "100644", Hash: remote, Type: "blob"},
+		},
+		{
+			Name:  "deleted remotely.txt",
+			Base:  Object{Name: "deleted remotely.txt", Mode: "100644", Hash: base, Type: "blob"},
+			Local: Object{Name: "deleted remotely.txt", Mode: "120000", Hash: local, Type: "blob"},
+		},
+		{
+			Name:   "added\ttab.txt",
+			Remote: Object{Name: "added\ttab.txt", Mode: "100644", Hash: remote, Type: "blob"},
+		},
+	}
+	if !reflect.DeepEqual(unmerged, expected) {
+		t.Fatalf("Unexpected unmerged files:\n%+v\nexpected:\n%+v", unmerged, expected)
+	}
+
+	for _, output := range []string{"", "\000", "malformed line\000", "100644 " + base + "\tno-stage\000"} {
+		if unmerged := parseLsUnmerged(output); len(unmerged) != 0 {
+			t.Fatalf("Unexpected unmerged files for %q: %+v", output, unmerged)
+		}
+	}
+}
+
+func TestParseStatusPorcelain(t *testing.T) {
+	output := " M data/modified.csv\000" +
+		"A  added file.txt\000" +
+		"R  new name.txt\000old name.txt\000" +
+		"C  copy.txt\000original.txt\000" +
+		"?? untracked.dat\000" +
+		"MD both.txt\000"
+	entries := parseStatusPorcelain(output)
+	expected := []StatusEntry{
+		{Name: "data/modified.csv", Index: ' ', WorkTree: 'M'},
+		{Name: "added file.txt", Index: 'A', WorkTree: ' '},
+		{Name: "new name.txt", OrigName: "old name.txt", Index: 'R', WorkTree: ' '},
+		{Name: "copy.txt", OrigName: "original.txt", Index: 'C', WorkTree: ' '},
+		{Name: "untracked.dat", Index: '?', WorkTree: '?'},
+		{Name: "both.txt", Index: 'M', WorkTree: 'D'},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Fatalf("Unexpected status entries:\n%+v\nexpected:\n%+v", entries, expected)
+	}
+	for idx, entry := range entries {
+		if untracked := entry.Untracked(); untracked != (idx == 4) {
+			t.Errorf("Untracked() = %v for %+v", untracked, entry)
+		}
+	}
+
+	// rename at the end of truncated output
+	entries = parseStatusPorcelain("R  new.txt")
+	if len(entries) != 1 || entries[0].Name != "new.txt" || entries[0].OrigName != "" {
+		t.Fatalf("Unexpected status entries for truncated output: %+v", entries)
+	}
+
+	if entries := parseStatusPorcelain(""); len(entries) != 0 {
+		t.Fatalf("Unexpected status entries for empty output: %+v", entries)
+	}
+}
+
+func TestParseRawDiffLog(t *testing.T) {
+	const (
+		commit1 = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+		commit2 = "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+		hash1   = "1111111111111111111111111111111111111111"
+		hash2   = "2222222222222222222222222222222222222222"
+	)
+	// git log -z --format=::%H --raw: the commit header is followed by a newline and the entries of the commit
+	output := "::" + commit1 + "\n\000" +
+		":000000 100644 " + NullHash + " " + hash1 + " A\000data/new.csv\000" +
+		":100644 120000 " + hash1 + " " + hash2 + " T\000data/typechange.csv\000" +
+		"::" + commit2 + "\n\000" +
+		"\000" +
+		"::" + commit2 + "-empty\n"
+	headers, entries := parseRawDiff(output)
+	expheaders := []string{"::" + commit1, "::" + commit2, "::" + commit2 + "-empty"}
+	expentries := [][]DiffEntry{
+		{
+			{OldMode: "000000", NewMode: "100644", OldHash: NullHash, NewHash: hash1, Status: "A", Name: "data/new.csv"},
+			{OldMode: "100644", NewMode: "120000", OldHash: hash1, NewHash: hash2, Status: "T", Name: "data/typechange.csv"},
+		},
+		nil,
+		nil,
+	}
+	if !reflect.DeepEqual(headers, expheaders) {
+		t.Fatalf("Unexpected headers:\n%q\nexpected:\n%q", headers, expheaders)
+	}
+	if !reflect.DeepEqual(entries, expentries) {
+		t.Fatalf("Unexpected entries:\n%+v\nexpected:\n%+v", entries, expentries)
+	}
+}
+
+func TestLogArgs(t *testing.T) {
+	format := []string{"--format=::%H"}
+	tests := []struct {
+		count       uint
+		revrange    string
+		paths       []string
+		showdeletes bool
+		filter      LogFilter
+		expected    []string
+	}{
+		{0, "", nil, true, LogFilter{}, []string{"log", "--format=::%H", "--"}},
+		{10, "", nil, false, LogFilter{}, []string{"log", "--format=::%H", "--max-count=10", "--diff-filter=d", "--"}},
+		{0, "v1..HEAD", []string{"data", "a file.csv"}, true, LogFilter{}, []string{"log", "--format=::%H", "v1..HEAD", "--", "data", "a file.csv"}},
+		{
+			1, "", nil, true,
+			LogFilter{Author: "alice", Since: "2 weeks ago", Until: "2019-03-01", Grep: "fix"},
+			[]string{"log", "--format=::%H", "--max-count=1", "--author=alice", "--since=2 weeks ago", "--until=2019-03-01", "--grep=fix", "--regexp-ignore-case", "--"},
+		},
+	}
+	for _, test := range tests {
+		args := logArgs(format, test.count, test.revrange, test.paths, test.showdeletes, test.filter)
+		if !reflect.DeepEqual(args, test.expected) {
+			t.Errorf("Unexpected log arguments:\n%q\nexpected:\n%q", args, test.expected)
+		}
+	}
+}
+
+func TestParseRawDiff(t *testing.T) {
+	const (
+		hash1 = "1111111111111111111111111111111111111111"
+		hash2 = "2222222222222222222222222222222222222222"
+	)
+	// git diff --raw -z: entries without headers; the new hash is NullHash for changes in the working tree
+	output := ":100644 100644 " + hash1 + " " + NullHash + " M\000modified.txt\000" +
+		":100644 000000 " + hash1 + " " + NullHash + " D\000removed.txt\000" +
+		":100644 100644 " + hash1 + " " + hash2 + " R086\000old name.txt\000new name.txt\000" +
+		":100644 100644 " + hash1 + " " + hash2 + " C100\000original.txt\000copy.txt\000" +
+		":100644 100644 " + hash1 + "\000malformed.txt\000"
+	headers, entries := parseRawDiff(output)
+	expected := []DiffEntry{
+		{OldMode: "100644", NewMode: "100644", OldHash: hash1, NewHash: NullHash, Status: "M", Name: "modified.txt"},
+		{OldMode: "100644", NewMode: "000000", OldHash: hash1, NewHash: NullHash, Status: "D", Name: "removed.txt"},
+		{OldMode: "100644", NewMode: "100644", OldHash: hash1, NewHash: hash2, Status: "R", Name: "new name.txt"},
+		{OldMode: "100644", NewMode: "100644", OldHash: hash1, NewHash: hash2, Status: "C", Name: "copy.txt"},
+	}
+	if len(headers) != 1 || headers[0] != "" {
+		t.Fatalf("Unexpected headers for diff without headers: %q", headers)
+	}
+	if len(entries) != 1 || !reflect.DeepEqual(entries[0], expected) {
+		t.Fatalf("Unexpected entries:\n%+v\nexpected:\n%+v", entries, expected)
+	}
+
+	if headers, entries := parseRawDiff(""); len(headers) != 0 || len(entries) != 0 {
+		t.Fatalf("Unexpected result for empty diff: %q %+v", headers, entries)
+	}
+}
+
+func TestDiffRevArgs(t *testing.T) {
+	tests := []struct {
+		rev1, rev2 string
+		expected   []string
+	}{
+		{"", "", []string{"HEAD"}},
+		{"v1", "", []string{"v1"}},
+		{"v1", "v2", []string{"v1", "v2"}},
+		{"", "v2", []string{"HEAD", "v2"}},
+	}
+	for _, test := range tests {
+		if args := diffRevArgs(test.rev1, test.rev2); !reflect.DeepEqual(args, test.expected) {
+			t.Errorf("diffRevArgs(%q, %q) = %q; expected %q", test.rev1, test.rev2, args, test.expected)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package git
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/G-Node/gin-cli/ginclient/config"
+	"github.com/G-Node/gin-cli/ginclient/log"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Functions for inspecting, refreshing, and pinning the host keys of the configured git servers.
+
+// hostKeyLogName is the name of the file in the configuration directory where host key changes are recorded.
+const hostKeyLogName = "hostkeys.log"
+
+// HostKeyInfo describes a host key in known_hosts format.
+type HostKeyInfo struct {
+	Hosts       []string `json:"hosts"`
+	Type        string   `json:"type"`
+	Fingerprint string   `json:"fingerprint"`
+}
+
+// ParseHostKey parses a host key line in known_hosts format.
+func ParseHostKey(hostkey string) (HostKeyInfo, error) {
+	_, hosts, pubkey, _, _, err := ssh.ParseKnownHosts([]byte(hostkey))
+	if err != nil {
+		return HostKeyInfo{}, giterror{UError: err.Error(), Origin: "ParseHostKey()", Description: "invalid host key"}
+	}
+	return HostKeyInfo{Hosts: hosts, Type: pubkey.Type(), Fingerprint: ssh.FingerprintSHA256(pubkey)}, nil
+}
+
+// PresentedHostKey queries the git server for its host key. The key type of
+// the configured host key is requested first, so that servers with multiple
+// host keys are compared against the same type of key. If the server does not
+// offer that type, the key type is negotiated.
+func PresentedHostKey(gitconf config.GitCfg) (hostkeystr, fingerprint string, err error) {
+	if info, perr := ParseHostKey(gitconf.HostKey); perr == nil {
+		hostkeystr, fingerprint, err = GetHostKeyOfType(gitconf, info.Type)
+		if err == nil && hostkeystr != "" {
+			return
+		}
+		log.Write("Failed to get %s host key from %s: %v", info.Type, gitconf.Host, err)
+	}
+	return GetHostKey(gitconf)
+}
+
+// FindHostKey queries the git server for a host key with the given
+// fingerprint. Since servers may have multiple host keys, all common key
+// types are requested until a key with a matching fingerprint is found.
+func FindHostKey(gitconf config.GitCfg, fingerprint string) (string, error) {
+	fn := fmt.Sprintf("FindHostKey(%s)", gitconf.Host)
+	var lasterr error
+	for _, keytype := range []string{"", ssh.KeyAlgoED25519, ssh.KeyAlgoECDSA256, ssh.KeyAlgoRSA} {
+		hostkeystr, presented, err := GetHostKeyOfType(gitconf, keytype)
+		if err != nil {
+			lasterr = err
+			continue
+		}
+		if presented == fingerprint {
+			return hostkeystr, nil
+		}
+	}
+	if lasterr != nil {
+		log.Write("Error while retrieving host keys from %s: %v", gitconf.Host, lasterr)
+	}
+	return "", giterror{Origin: fn, Description: fmt.Sprintf("server %s did not present a host key with fingerprint %s", gitconf.AddressStr(), fingerprint)}
+}
+
+// HostKeyLogPath returns the path of the file where host key changes are recorded.
+func HostKeyLogPath() string {
+	configpath, _ := config.Path(false) // Error can only occur when attempting to create directory
+	return filepath.Join(configpath, hostKeyLogName)
+}
+
+// logHostKeyChange appends a record of a host key change to the host key log.
+func logHostKeyChange(alias, oldkey, newkey, reason string) {
+	fingerprint := func(hostkey string) string {
+		if info, err := ParseHostKey(hostkey); err == nil {
+			return info.Fingerprint
+		}
+		return "none"
+	}
+	record := fmt.Sprintf("%s\t%s\t%s\told=%s\tnew=%s\n", time.Now().Format(time.RFC3339), alias, reason, fingerprint(oldkey), fingerprint(newkey))
+	log.Write("Host key change: %s", strings.TrimSpace(record))
+	if _, err := config.Path(true); err != nil {
+		log.Write("Failed to create config directory for host key log: %v", err)
+		return
+	}
+	f, err := os.OpenFile(HostKeyLogPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Write("Failed to open host key log: %v", err)
+		return
+	}
+	defer f.Close()
+	if _, err = f.WriteString(record); err != nil {
+		log.Write("Failed to write host key log: %v", err)
+	}
+}
+
+// TrustHostKey sets the host key (in known_hosts format) of the server with
+// the given alias, rewrites the known_hosts file, and records the change and
+// the reason for it in the host key log.
+func TrustHostKey(alias, hostkey, reason string) error {
+	fn := fmt.Sprintf("TrustHostKey(%s)", alias)
+	srvcfg, ok := config.Read().Servers[alias]
+	if !ok {
+		return giterror{Origin: fn, Description: fmt.Sprintf("unknown server alias '%s'", alias)}
+	}
+	if _, err := ParseHostKey(hostkey); err != nil {
+		return err
+	}
+	oldkey := srvcfg.Git.HostKey
+	if err := config.SetConfig(fmt.Sprintf("servers.%s.git.hostkey", alias), hostkey); err != nil {
+		return giterror{UError: err.Error(), Origin: fn, Description: "failed to update configuration file"}
+	}
+	if err := WriteKnownHosts(); err != nil {
+		return giterror{UError: err.Error(), Origin: fn, Description: "failed to write known_hosts file"}
+	}
+	logHostKeyChange(alias, oldkey, hostkey, reason)
+	return nil
+}
+
+// TrustOnFirstUse retrieves and stores the host key of the server with the
+// given alias if it has no host key configured. The fingerprint of the
+// trusted key is returned. It is empty if the server already has a host key
+// or does not use SSH for git operations. Subsequent connections are checked
+// against the stored key.
+func TrustOnFirstUse(alias string) (fingerprint string, err error) {
+	fn := fmt.Sprintf("TrustOnFirstUse(%s)", alias)
+	srvcfg, ok := config.Read().Servers[alias]
+	if !ok {
+		return "", giterror{Origin: fn, Description: fmt.Sprintf("unknown server alias '%s'", alias)}
+	}
+	if srvcfg.Git.HostKey != "" || srvcfg.Git.Host == "" || srvcfg.Git.UseHTTPS() {
+		return "", nil
+	}
+	hostkeystr, fingerprint, err := GetHostKey(srvcfg.Git)
+	if err != nil {
+		return "", err
+	}
+	if hostkeystr == "" {
+		return "", giterror{Origin: fn, Description: fmt.Sprintf("server %s did not present a host key", srvcfg.Git.AddressStr())}
+	}
+	if err = TrustHostKey(alias, hostkeystr, "trust-on-first-use"); err != nil {
+		return "", err
+	}
+	return fingerprint, nil
+}
+
+// HostKeyError is returned when the git server does not present the configured host key.
+type HostKeyError struct {
+	// Prefix is prepended to the error message (e.g., "upload failed").
+	Prefix  string
+	Alias   string
+	Address string
+	// Expected is the fingerprint of the configured host key. It is empty if no key is configured.
+	Expected string
+	// Presented is the fingerprint of the key presented by the server. It is empty if it could not be determined.
+	Presented string
+}
+
+func (e HostKeyError) Error() string {
+	var msg string
+	switch {
+	case e.Expected == "":
+		msg = fmt.Sprintf("no host key is configured for server '%s' (%s)\nRun 'gin hostkeys --refresh %s' to review and trust the key presented by the server", e.Alias, e.Address, e.Alias)
+	case e.Presented == e.Expected:
+		msg = fmt.Sprintf("the known hosts file was out of date for server '%s' (%s) and has been rewritten; please try again", e.Alias, e.Address)
+	default:
+		presented := e.Presented
+		if presented == "" {
+			presented = "(could not be determined)"
+		}
+		msg = fmt.Sprintf("the host key presented by server '%s' (%s) does not match the configured host key\n  expected:  %s\n  presented: %s\nThis may indicate that the server's key has changed or that someone is intercepting the connection.\nVerify the presented fingerprint with the server administrators, then run 'gin hostkeys --refresh %s' to trust the new key", e.Alias, e.Address, e.Expected, presented, e.Alias)
+	}
+	if e.Prefix != "" {
+		return fmt.Sprintf("%s: %s", e.Prefix, msg)
+	}
+	return msg
+}
+
+// hostKeyServer returns the alias of the server whose git address appears in
+// the ssh error output. ssh names hosts as in known_hosts files: the host name
+// alone for the default port and [host]:port otherwise. Only exact matches
+// are considered, with servers checked in sorted order of their aliases. The
+// default server is returned if no address matches.
+func hostKeyServer(conf config.GinCliCfg, sshstderr string) string {
+	aliases := make([]string, 0, len(conf.Servers))
+	for alias := range conf.Servers {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	for _, alias := range aliases {
+		gitconf := conf.Servers[alias].Git
+		if gitconf.Host == "" || gitconf.UseHTTPS() {
+			continue
+		}
+		name := knownhosts.Normalize(net.JoinHostPort(gitconf.Host, strconv.Itoa(int(gitconf.Port))))
+		// the name must not be part of a longer host name or address
+		namere := regexp.MustCompile(`(^|[^\w.\-\[])` + regexp.QuoteMeta(name) + `($|[^\w\-\]:.]|\.($|[^\w\-]))`)
+		if namere.MatchString(sshstderr) {
+			return alias
+		}
+	}
+	return conf.DefaultServer
+}
+
+// newHostKeyError diagnoses a host key verification failure reported by ssh.
+// The server is identified by its address in the ssh error output (see
+// hostKeyServer) and its current host key is retrieved for comparison with
+// the configured key.
+func newHostKeyError(prefix, sshstderr string) error {
+	conf := config.Read()
+	alias := hostKeyServer(conf, sshstderr)
+	srvcfg := conf.Servers[alias]
+	herr := HostKeyError{Prefix: prefix, Alias: alias, Address: srvcfg.Git.AddressStr()}
+	if info, err := ParseHostKey(srvcfg.Git.HostKey); err == nil {
+		herr.Expected = info.Fingerprint
+	}
+	if _, fingerprint, err := PresentedHostKey(srvcfg.Git); err == nil {
+		herr.Presented = fingerprint
+	} else {
+		log.Write("Failed to retrieve host key of %s: %v", alias, err)
+	}
+	if herr.Expected != "" && herr.Expected == herr.Presented {
+		// the configured key is correct: the known_hosts file is stale
+		if err := WriteKnownHosts(); err != nil {
+			log.Write("Failed to rewrite known_hosts file: %v", err)
+		}
+	}
+	log.Write("Host key verification failed for %s: expected %s, presented %s", alias, herr.Expected, herr.Presented)
+	return herr
+}
@@ -0,0 +1,208 @@
+package git
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/G-Node/gin-cli/ginclient/config"
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/ssh"
+)
+
+// sshHostKeyServer starts an SSH server on the local host that presents the returned host key and rejects all authentication attempts.
+func sshHostKeyServer(t *testing.T) (net.Listener, ssh.Signer) {
+	_, privkey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate host key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(privkey)
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+	srvconf := &ssh.ServerConfig{
+		PublicKeyCallback: func(ssh.ConnMetadata, ssh.PublicKey) (*ssh.Permissions, error) {
+			return nil, fmt.Errorf("access denied")
+		},
+	}
+	srvconf.AddHostKey(signer)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				ssh.NewServerConn(conn, srvconf)
+				conn.Close()
+			}()
+		}
+	}()
+	return listener, signer
+}
+
+func TestHostKeyServer(t *testing.T) {
+	conf := config.GinCliCfg{
+		DefaultServer: "gin",
+		Servers: map[string]config.ServerCfg{
+			"gin":    {Git: config.GitCfg{Host: "gin.example.org", Port: 22}},
+			"sub":    {Git: config.GitCfg{Host: "git.gin.example.org", Port: 22}},
+			"lab":    {Git: config.GitCfg{Host: "gin.example.org", Port: 2222}},
+			"lab2":   {Git: config.GitCfg{Host: "gin.example.org", Port: 2222}},
+			"local":  {Git: config.GitCfg{Host: "10.0.0.1", Port: 22}},
+			"secure": {Git: config.GitCfg{Host: "secure.example.org", Protocol: config.GitProtocolHTTPS}},
+		},
+	}
+	tests := map[string]string{
+		"Host key for git.gin.example.org has changed and you have requested strict checking.":        "sub",
+		"Host key for [gin.example.org]:2222 has changed and you have requested strict checking.":     "lab",
+		"No ED25519 host key is known for gin.example.org and you have requested strict checking.":    "gin",
+		"No ED25519 host key is known for [gin.example.org]:22222 and you have requested strict.":     "gin",
+		"Offending ECDSA key in /home/alice/.config/gin/known_hosts:1\nHost key for 10.0.0.1 changed": "local",
+		"Host key for 10.0.0.10 has changed":                                                          "gin",
+		"Host key for gin.example.org.evil.org has changed":                                           "gin",
+		"Host key verification failed.":                                                               "gin",
+		"Host key for secure.example.org has changed":                                                 "gin",
+		"Host key for [git.gin.example.org]:22 has changed":                                           "gin",
+		"The host key for git.gin.example.org. has changed.":                                          "sub",
+		"ssh: connect to host gin.example.org port 22: refused":                                       "gin",
+	}
+	for stderr, expected := range tests {
+		if alias := hostKeyServer(conf, stderr); alias != expected {
+			t.Errorf("hostKeyServer(%q) = %s; expected %s", stderr, alias, expected)
+		}
+	}
+}
+
+func TestHostKeyError(t *testing.T) {
+	tests := []struct {
+		herr     HostKeyError
+		expected []string
+	}{
+		{
+			HostKeyError{Prefix: "upload failed", Alias: "lab", Address: "ssh://git@lab.example.org:22"},
+			[]string{"upload failed: no host key is configured for server 'lab' (ssh://git@lab.example.org:22)", "gin hostkeys --refresh lab"},
+		},
+		{
+			HostKeyError{Alias: "lab", Address: "ssh://git@lab.example.org:22", Expected: "SHA256:aaaa", Presented: "SHA256:aaaa"},
+			[]string{"the known hosts file was out of date for server 'lab'", "please try again"},
+		},
+		{
+			HostKeyError{Prefix: "download failed", Alias: "lab", Address: "ssh://git@lab.example.org:22", Expected: "SHA256:aaaa", Presented: "SHA256:bbbb"},
+			[]string{"download failed: the host key presented by server 'lab'", "expected:  SHA256:aaaa\n", "presented: SHA256:bbbb\n", "gin hostkeys --refresh lab"},
+		},
+		{
+			HostKeyError{Alias: "lab", Address: "ssh://git@lab.example.org:22", Expected: "SHA256:aaaa"},
+			[]string{"the host key presented by server 'lab'", "presented: (could not be determined)"},
+		},
+	}
+	for idx, test := range tests {
+		msg := test.herr.Error()
+		if test.herr.Prefix == "" && strings.Contains(msg, ": the") {
+			t.Errorf("%d: unexpected prefix in message: %s", idx, msg)
+		}
+		for _, part := range test.expected {
+			if !strings.Contains(msg, part) {
+				t.Errorf("%d: message does not contain %q:\n%s", idx, part, msg)
+			}
+		}
+	}
+}
+
+func TestLogHostKeyChange(t *testing.T) {
+	testServerConfig(t, nil)
+	_, signer := sshHostKeyServer(t)
+	hostkey := "gin.example.org " + strings.TrimSpace(string(ssh.MarshalAuthorizedKey(signer.PublicKey())))
+	fingerprint := ssh.FingerprintSHA256(signer.PublicKey())
+
+	logHostKeyChange("gin", "", hostkey, "trust-on-first-use")
+	logHostKeyChange("gin", hostkey, "invalid", "refresh")
+	data, err := ioutil.ReadFile(HostKeyLogPath())
+	if err != nil {
+		t.Fatalf("Failed to read host key log: %v", err)
+	}
+	records := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	expected := [][]string{
+		{"gin", "trust-on-first-use", "old=none", "new=" + fingerprint},
+		{"gin", "refresh", "old=" + fingerprint, "new=none"},
+	}
+	if len(records) != len(expected) {
+		t.Fatalf("Expected %d records, got:\n%s", len(expected), data)
+	}
+	for idx, record := range records {
+		fields := strings.Split(record, "\t")
+		if len(fields) != 5 {
+			t.Fatalf("Unexpected record format: %q", record)
+		}
+		if _, err := time.Parse(time.RFC3339, fields[0]); err != nil {
+			t.Errorf("Invalid time in record %q: %v", record, err)
+		}
+		if strings.Join(fields[1:], "\t") != strings.Join(expected[idx], "\t") {
+			t.Errorf("Unexpected record %q; expected %q", record, strings.Join(expected[idx], "\t"))
+		}
+	}
+	if runtime.GOOS != "windows" {
+		if info, err := os.Stat(HostKeyLogPath()); err != nil {
+			t.Errorf("Failed to stat host key log: %v", err)
+		} else if info.Mode().Perm() != 0600 {
+			t.Errorf("Unexpected permissions for host key log: %v", info.Mode().Perm())
+		}
+	}
+}
+
+func TestTrustOnFirstUse(t *testing.T) {
+	listener, signer := sshHostKeyServer(t)
+	addr := listener.Addr().(*net.TCPAddr)
+	testServerConfig(t, map[string]config.ServerCfg{
+		"local": {
+			Web: config.WebCfg{Protocol: "http", Host: "127.0.0.1", Port: 3000},
+			Git: config.GitCfg{User: "git", Host: "127.0.0.1", Port: uint16(addr.Port)},
+		},
+		"secure": {
+			Web: config.WebCfg{Protocol: "https", Host: "gin.example.org", Port: 443},
+			Git: config.GitCfg{Protocol: config.GitProtocolHTTPS},
+		},
+	})
+
+	fingerprint, err := TrustOnFirstUse("local")
+	if err != nil {
+		t.Fatalf("TrustOnFirstUse failed: %v", err)
+	}
+	if expected := ssh.FingerprintSHA256(signer.PublicKey()); fingerprint != expected {
+		t.Fatalf("Unexpected fingerprint %s; expected %s", fingerprint, expected)
+	}
+	hostkey := config.Read().Servers["local"].Git.HostKey
+	if info, err := ParseHostKey(hostkey); err != nil || info.Fingerprint != fingerprint {
+		t.Fatalf("Host key not stored in configuration: %q (%v)", hostkey, err)
+	}
+	knownhosts, err := ioutil.ReadFile(filepath.Join(filepath.Dir(HostKeyLogPath()), "known_hosts"))
+	if err != nil || !strings.Contains(string(knownhosts), hostkey) {
+		t.Fatalf("Host key not written to known_hosts file: %s (%v)", knownhosts, err)
+	}
+	if data, _ := ioutil.ReadFile(HostKeyLogPath()); !strings.Contains(string(data), "\tlocal\ttrust-on-first-use\told=none\tnew="+fingerprint+"\n") {
+		t.Fatalf("Host key change not logged: %s", data)
+	}
+
+	// the stored key is not replaced
+	if fingerprint, err = TrustOnFirstUse("local"); err != nil || fingerprint != "" {
+		t.Fatalf("Expected no change for server with host key, got %q (%v)", fingerprint, err)
+	}
+	if fingerprint, err = TrustOnFirstUse("secure"); err != nil || fingerprint != "" {
+		t.Fatalf("Expected no change for HTTPS server, got %q (%v)", fingerprint, err)
+	}
+	if _, err = TrustOnFirstUse("unknown"); err == nil {
+		t.Fatal("Expected error for unknown server alias")
+	}
+}
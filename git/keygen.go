@@ -1,19 +1,29 @@
 package git
 
 import (
+	"bytes"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
+	"encoding/binary"
 	"encoding/pem"
 	"fmt"
 	"net"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/G-Node/gin-cli/ginclient/config"
+	"github.com/G-Node/gin-cli/ginclient/credentials"
 	"github.com/G-Node/gin-cli/ginclient/log"
+	"github.com/G-Node/gin-cli/git/shell"
+	"golang.org/x/crypto/ed25519"
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
 	"golang.org/x/crypto/ssh/knownhosts"
 )
 
@@ -30,56 +40,160 @@ type KeyFile struct {
 	Active   bool
 }
 
-// MakeKeyPair generates and returns a private-public key pair.
-func MakeKeyPair() (*KeyPair, error) {
-	log.Write("Creating key pair")
-	privkey, err := rsa.GenerateKey(rand.Reader, 2048) // TODO: Key size as parameter
-	if err != nil {
-		return nil, fmt.Errorf("Error generating key pair: %s", err)
-	}
+// Supported key types for session keys
+const (
+	KeyTypeEd25519 = "ed25519"
+	KeyTypeRSA     = "rsa"
+)
+
+// rsaKeySize is the size of generated RSA keys in bits.
+const rsaKeySize = 4096
+
+// MakeKeyPair generates and returns a private-public key pair of the given type (ed25519 or rsa).
+// If keytype is empty, an ed25519 key pair is generated.
+func MakeKeyPair(keytype string) (*KeyPair, error) {
+	log.Write("Creating key pair [%s]", keytype)
+	var privStr string
+	var pubkey ssh.PublicKey
+	switch keytype {
+	case "", KeyTypeEd25519:
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("Error generating key pair: %s", err)
+		}
+		privBlk, err := marshalED25519PrivateKey(pub, priv)
+		if err != nil {
+			return nil, fmt.Errorf("Error encoding private key: %s", err)
+		}
+		privStr = string(pem.EncodeToMemory(privBlk))
+		pubkey, err = ssh.NewPublicKey(pub)
+		if err != nil {
+			return nil, err
+		}
+	case KeyTypeRSA:
+		privkey, err := rsa.GenerateKey(rand.Reader, rsaKeySize)
+		if err != nil {
+			return nil, fmt.Errorf("Error generating key pair: %s", err)
+		}
 
-	// Private key to string
-	privkeyDer := x509.MarshalPKCS1PrivateKey(privkey)
-	privBlk := pem.Block{
-		Type:    "RSA PRIVATE KEY",
-		Headers: nil,
-		Bytes:   privkeyDer,
+		// Private key to string
+		privkeyDer := x509.MarshalPKCS1PrivateKey(privkey)
+		privBlk := pem.Block{
+			Type:    "RSA PRIVATE KEY",
+			Headers: nil,
+			Bytes:   privkeyDer,
+		}
+		privStr = string(pem.EncodeToMemory(&privBlk))
+		pubkey, err = ssh.NewPublicKey(&privkey.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported key type '%s' (supported types are '%s' and '%s')", keytype, KeyTypeEd25519, KeyTypeRSA)
 	}
-	privStr := string(pem.EncodeToMemory(&privBlk))
 
 	// Public key to string
-	pubkey, err := ssh.NewPublicKey(&privkey.PublicKey)
-	if err != nil {
-		return nil, err
-	}
 	pubStr := string(ssh.MarshalAuthorizedKey(pubkey))
 
 	return &KeyPair{privStr, pubStr}, nil
 }
 
-// PrivKeyPath returns a map with the full path for all the currently available private key files indexed by the server alias for each key.
-func PrivKeyPath() map[string]string {
-	configpath, err := config.Path(false)
+// marshalED25519PrivateKey encodes an ed25519 private key in the OpenSSH
+// private key format (unencrypted), which is the only format supported by
+// OpenSSH for ed25519 keys.
+func marshalED25519PrivateKey(pub ed25519.PublicKey, priv ed25519.PrivateKey) (*pem.Block, error) {
+	const magic = "openssh-key-v1\x00"
+	var check [4]byte
+	if _, err := rand.Read(check[:]); err != nil {
+		return nil, err
+	}
+	checkint := binary.BigEndian.Uint32(check[:])
+
+	pubkey := struct {
+		KeyType string
+		Pub     []byte
+	}{ssh.KeyAlgoED25519, pub}
+
+	privkey := struct {
+		Check1  uint32
+		Check2  uint32
+		KeyType string
+		Pub     []byte
+		Priv    []byte
+		Comment string
+		Pad     []byte `ssh:"rest"`
+	}{
+		Check1:  checkint,
+		Check2:  checkint,
+		KeyType: ssh.KeyAlgoED25519,
+		Pub:     pub,
+		Priv:    priv,
+	}
+	// the private section is padded to the cipher block size (8 for "none") with the bytes 1, 2, 3, ...
+	bsize := 8
+	plen := len(ssh.Marshal(privkey))
+	for idx := 0; (plen+idx)%bsize != 0; idx++ {
+		privkey.Pad = append(privkey.Pad, byte(idx+1))
+	}
+
+	container := struct {
+		CipherName   string
+		KdfName      string
+		KdfOpts      string
+		NumKeys      uint32
+		PubKey       []byte
+		PrivKeyBlock []byte
+	}{
+		CipherName:   "none",
+		KdfName:      "none",
+		KdfOpts:      "",
+		NumKeys:      1,
+		PubKey:       ssh.Marshal(pubkey),
+		PrivKeyBlock: ssh.Marshal(privkey),
+	}
+	data := append([]byte(magic), ssh.Marshal(container)...)
+	return &pem.Block{Type: "OPENSSH PRIVATE KEY", Bytes: data}, nil
+}
+
+// PublicKeyString returns the public key (in authorized_keys format, without comment) for a PEM encoded private key.
+func PublicKeyString(privkey []byte) (string, error) {
+	signer, err := ssh.ParsePrivateKey(privkey)
 	if err != nil {
-		log.Write("Error getting user's config path. Can't load key file.")
-		log.Write(err.Error())
-		return nil
+		return "", err
 	}
-	servers := config.Read().Servers
-	keys := make(map[string]string)
-	for srvalias := range servers {
-		keyfilepath := filepath.Join(configpath, fmt.Sprintf("%s.key", srvalias))
-		if pathExists(keyfilepath) {
-			keys[srvalias] = keyfilepath
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(signer.PublicKey()))), nil
+}
+
+// KeyName returns the name of the private key credential for the given server alias.
+func KeyName(srvalias string) string {
+	return fmt.Sprintf("%s.key", srvalias)
+}
+
+// PrivKeyPath returns the full path of the private key file created on login for the server with the given alias.
+// Keys that are kept in a credential store other than plain files are written to a temporary file (see credentials.KeyFile).
+// The second return value is false if there is no key for the server.
+func PrivKeyPath(srvalias string) (string, bool) {
+	keyfilepath, err := credentials.KeyFile(KeyName(srvalias))
+	if err != nil {
+		if !credentials.IsNotFound(err) {
+			log.Write("Can't load key file for %s: %v", srvalias, err)
 		}
+		return "", false
 	}
-	return keys
+	return keyfilepath, true
 }
 
 // GetHostKey takes a git server configuration, queries the server via SSH, and
 // returns the public key of the host (in the format required for the
 // known_hosts file) and the key fingerprint.
 func GetHostKey(gitconf config.GitCfg) (hostkeystr, fingerprint string, err error) {
+	return GetHostKeyOfType(gitconf, "")
+}
+
+// GetHostKeyOfType is like GetHostKey but requests a host key of the given
+// type (e.g., ssh-ed25519) from the server. If keytype is empty, the server
+// and client negotiate the key type.
+func GetHostKeyOfType(gitconf config.GitCfg, keytype string) (hostkeystr, fingerprint string, err error) {
 	// HostKeyCallback constructs the keystring
 	keycb := func(hostname string, remote net.Addr, key ssh.PublicKey) error {
 		addr := []string{hostname, remote.String()}
@@ -92,7 +206,10 @@ func GetHostKey(gitconf config.GitCfg) (hostkeystr, fingerprint string, err erro
 		User:            gitconf.User,
 		HostKeyCallback: keycb,
 	}
-	_, derr := ssh.Dial("tcp", fmt.Sprintf("%s:%d", gitconf.Host, gitconf.Port), &sshcon)
+	if keytype != "" {
+		sshcon.HostKeyAlgorithms = []string{keytype}
+	}
+	_, derr := sshDial(gitconf, &sshcon)
 	if derr != nil && !strings.Contains(derr.Error(), "unable to authenticate") {
 		// Other errors (auth error in particular) should be ignored
 		err = fmt.Errorf("connection test failed: %s", derr)
@@ -100,6 +217,38 @@ func GetHostKey(gitconf config.GitCfg) (hostkeystr, fingerprint string, err erro
 	return
 }
 
+// CheckSSHAccess connects to the git server using the given (PEM encoded)
+// private key and returns an error if authentication fails.
+// The host key of the server is checked against the configured host keys.
+func CheckSSHAccess(gitconf config.GitCfg, privkey []byte) error {
+	fn := fmt.Sprintf("CheckSSHAccess(%s)", gitconf.Host)
+	signer, err := ssh.ParsePrivateKey(privkey)
+	if err != nil {
+		return giterror{UError: err.Error(), Origin: fn, Description: "failed to parse private key"}
+	}
+	hkfile, err := GetKnownHosts()
+	if err != nil {
+		return giterror{UError: err.Error(), Origin: fn, Description: "failed to read known hosts"}
+	}
+	hkcb, err := knownhosts.New(hkfile)
+	if err != nil {
+		return giterror{UError: err.Error(), Origin: fn, Description: "failed to read known hosts"}
+	}
+	sshcon := ssh.ClientConfig{
+		User:            gitconf.User,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: hkcb,
+		Timeout:         30 * time.Second,
+	}
+	log.Write("Checking SSH access to %s:%d", gitconf.Host, gitconf.Port)
+	client, err := sshDial(gitconf, &sshcon)
+	if err != nil {
+		return giterror{UError: err.Error(), Origin: fn, Description: fmt.Sprintf("SSH access check failed: %s", err)}
+	}
+	client.Close()
+	return nil
+}
+
 // hostkeypath returns the full path for the location of the gin host key file.
 func hostkeypath() string {
 	configpath, _ := config.Path(false) // Error can only occur when attempting to create directory
@@ -123,6 +272,9 @@ func WriteKnownHosts() error {
 	}
 	defer f.Close()
 	for _, srvcfg := range conf.Servers {
+		if srvcfg.Git.HostKey == "" {
+			continue
+		}
 		_, err := f.WriteString(srvcfg.Git.HostKey + "\n")
 		if err != nil {
 			return err
@@ -142,10 +294,134 @@ func GetKnownHosts() (string, error) {
 	return hkpath, err
 }
 
-// sshEnv returns the value that should be set for the GIT_SSH_COMMAND environment variable
-// in order to use the user's private keys.
-// The returned string contains all available private keys.
-func sshEnv() string {
+// ExpandHome replaces a leading ~ in a path with the user's home directory (see config.ExpandHome).
+func ExpandHome(path string) string {
+	return config.ExpandHome(path)
+}
+
+// AgentHasKey returns true if the SSH agent (SSH_AUTH_SOCK) holds the private
+// key for the given public key (in authorized_keys format).
+// An error is returned if the agent can not be reached.
+func AgentHasKey(pubkey []byte) (bool, error) {
+	fn := "AgentHasKey()"
+	key, _, _, _, err := ssh.ParseAuthorizedKey(pubkey)
+	if err != nil {
+		return false, giterror{UError: err.Error(), Origin: fn, Description: "failed to parse public key"}
+	}
+	sock := os.Getenv("SSH_AUTH_SOCK")
+	if sock == "" {
+		return false, giterror{UError: "SSH_AUTH_SOCK not set", Origin: fn, Description: "no SSH agent is running"}
+	}
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		return false, giterror{UError: err.Error(), Origin: fn, Description: "failed to connect to the SSH agent"}
+	}
+	defer conn.Close()
+	agentkeys, err := agent.NewClient(conn).List()
+	if err != nil {
+		return false, giterror{UError: err.Error(), Origin: fn, Description: "failed to list the keys of the SSH agent"}
+	}
+	for _, agentkey := range agentkeys {
+		if bytes.Equal(agentkey.Marshal(), key.Marshal()) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// IdentityFile returns the private key file that should be used for the
+// server with the given alias: the configured identity file if one is set,
+// otherwise the key created by the client on login (if it exists).
+func IdentityFile(srvalias string) (string, bool) {
+	if srvcfg, ok := config.Read().Servers[srvalias]; ok && srvcfg.Git.IdentityFile != "" {
+		return ExpandHome(srvcfg.Git.IdentityFile), true
+	}
+	return PrivKeyPath(srvalias)
+}
+
+// sshServerForURL returns the alias of the git server (using SSH) that a
+// remote URL refers to. Both ssh:// URLs and the scp-like syntax
+// ([user@]host:path) are recognised.
+func sshServerForURL(remoteurl string, servers map[string]config.ServerCfg) (string, bool) {
+	var host string
+	port := uint16(22)
+	if strings.Contains(remoteurl, "://") {
+		u, err := url.Parse(remoteurl)
+		if err != nil || (u.Scheme != "ssh" && u.Scheme != "git+ssh") {
+			return "", false
+		}
+		host = u.Hostname()
+		if u.Port() != "" {
+			p, err := strconv.ParseUint(u.Port(), 10, 16)
+			if err != nil {
+				return "", false
+			}
+			port = uint16(p)
+		}
+	} else {
+		colon := strings.Index(remoteurl, ":")
+		if colon < 1 || strings.Contains(remoteurl[:colon], "/") {
+			// local path
+			return "", false
+		}
+		host = remoteurl[:colon]
+		if at := strings.LastIndex(host, "@"); at >= 0 {
+			host = host[at+1:]
+		}
+	}
+	for alias, srvcfg := range servers {
+		if !srvcfg.Git.UseHTTPS() && srvcfg.Git.Host == host && srvcfg.Git.Port == port {
+			return alias, true
+		}
+	}
+	return "", false
+}
+
+// remoteURLs returns the URLs of the remotes of the repository in the working directory.
+// The git configuration is read without Command, which calls sshEnv.
+func remoteURLs() []string {
+	cmd := shell.Command(config.Read().Bin.Git, "config", "--get-regexp", `^remote\..*\.(push)?url$`)
+	stdout, _, err := cmd.OutputError()
+	if err != nil {
+		// not in a repository or no remotes configured
+		return nil
+	}
+	var urls []string
+	for _, line := range strings.Split(string(stdout), "\n") {
+		// <key> SP <value>
+		if parts := strings.SplitN(strings.TrimSpace(line), " ", 2); len(parts) == 2 {
+			urls = append(urls, parts[1])
+		}
+	}
+	return urls
+}
+
+// sshServers returns the aliases of the git servers that a git command with
+// the given arguments may contact over SSH: the servers of the remotes of the
+// repository in the working directory and the servers of any URLs in the
+// arguments (e.g., for clone).
+func sshServers(args []string) []string {
+	servers := config.Read().Servers
+	found := make(map[string]bool)
+	for _, remoteurl := range append(remoteURLs(), args...) {
+		if alias, ok := sshServerForURL(remoteurl, servers); ok {
+			found[alias] = true
+		}
+	}
+	aliases := make([]string, 0, len(found))
+	for alias := range found {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	return aliases
+}
+
+// sshEnv returns the value that should be set for the GIT_SSH_COMMAND
+// environment variable of a git command with the given arguments in order to
+// use the user's private keys.
+// The keys and agent settings of the servers the command may contact (see
+// sshServers) are set for each host in a generated ssh configuration file (see sshConfig).
+func sshEnv(args []string) string {
 	// Windows git seems to require Unix paths for the SSH command -- this is dirty but works
 	fixpathsep := func(p string) string {
 		p = filepath.ToSlash(p)
@@ -154,20 +430,23 @@ func sshEnv() string {
 	}
 	config := config.Read()
 	sshbin := fixpathsep(config.Bin.SSH)
-	keys := PrivKeyPath()
-	keyargs := make([]string, len(keys))
-	idx := 0
-	for _, k := range keys {
-		keyargs[idx] = fmt.Sprintf("-i %s", fixpathsep(k))
-		idx++
-	}
-	keystr := strings.Join(keyargs, " ")
+	var cfgoptstr string
+	if aliases := sshServers(args); len(aliases) > 0 {
+		if cfgfile, err := sshConfigFile(aliases); err == nil {
+			cfgoptstr = fmt.Sprintf("-F %s", fixpathsep(cfgfile))
+		} else {
+			log.Write("Failed to write ssh configuration file: %v", err)
+		}
+	}
 	hostkeyfile, err := GetKnownHosts()
 	var hfoptstr string
 	if err == nil {
 		hfoptstr = fmt.Sprintf("-o 'UserKnownHostsFile=\"%s\"'", hostkeyfile)
 	}
-	gitSSHCmd := fmt.Sprintf("GIT_SSH_COMMAND=%s %s -o IdentitiesOnly=yes -o StrictHostKeyChecking=yes %s", sshbin, keystr, hfoptstr)
+	gitSSHCmd := fmt.Sprintf("GIT_SSH_COMMAND=%s %s -o StrictHostKeyChecking=yes %s", sshbin, cfgoptstr, hfoptstr)
+	if proxyopt := proxyCommandOpt(); proxyopt != "" {
+		gitSSHCmd = fmt.Sprintf("%s %s", gitSSHCmd, proxyopt)
+	}
 	log.Write("env %s", gitSSHCmd)
 	return gitSSHCmd
 }
@@ -0,0 +1,85 @@
+package git
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/G-Node/gin-cli/ginclient/config"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestMakeKeyPair(t *testing.T) {
+	for _, keytype := range []string{KeyTypeEd25519, KeyTypeRSA} {
+		keypair, err := MakeKeyPair(keytype)
+		if err != nil {
+			t.Fatalf("Failed to create %s key pair: %s", keytype, err.Error())
+		}
+		signer, err := ssh.ParsePrivateKey([]byte(keypair.Private))
+		if err != nil {
+			t.Fatalf("Failed to parse %s private key: %s", keytype, err.Error())
+		}
+		pubkey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(keypair.Public))
+		if err != nil {
+			t.Fatalf("Failed to parse %s public key: %s", keytype, err.Error())
+		}
+		if ssh.FingerprintSHA256(pubkey) != ssh.FingerprintSHA256(signer.PublicKey()) {
+			t.Fatalf("Public key does not match private key for key type %s", keytype)
+		}
+		pubstr, err := PublicKeyString([]byte(keypair.Private))
+		if err != nil {
+			t.Fatalf("Failed to derive %s public key: %s", keytype, err.Error())
+		}
+		if pubstr != strings.TrimSpace(keypair.Public) {
+			t.Fatalf("Derived public key does not match: %s != %s", pubstr, keypair.Public)
+		}
+	}
+
+	if !strings.HasPrefix(mustKeyPair(t, "").Public, ssh.KeyAlgoED25519) {
+		t.Fatal("Default key type should be ed25519")
+	}
+	if _, err := MakeKeyPair("dsa"); err == nil {
+		t.Fatal("Expected error for unsupported key type")
+	}
+}
+
+func mustKeyPair(t *testing.T, keytype string) *KeyPair {
+	keypair, err := MakeKeyPair(keytype)
+	if err != nil {
+		t.Fatalf("Failed to create key pair: %s", err.Error())
+	}
+	return keypair
+}
+
+func TestSSHServerForURL(t *testing.T) {
+	servers := map[string]config.ServerCfg{
+		"gin":   {Git: config.GitCfg{User: "git", Host: "gin.g-node.org", Port: 22}},
+		"local": {Git: config.GitCfg{User: "git", Host: "localhost", Port: 2222}},
+		"https": {Git: config.GitCfg{Host: "web.example.org", Port: 22, Protocol: config.GitProtocolHTTPS}},
+	}
+	tests := []struct {
+		url   string
+		alias string
+	}{
+		{"ssh://git@gin.g-node.org:22/alice/ephys", "gin"},
+		{"ssh://git@gin.g-node.org/alice/ephys", "gin"},
+		{"git@gin.g-node.org:alice/ephys", "gin"},
+		{"gin.g-node.org:alice/ephys", "gin"},
+		{"ssh://git@localhost:2222/alice/ephys", "local"},
+		// wrong port
+		{"ssh://git@localhost/alice/ephys", ""},
+		{"git@localhost:alice/ephys", ""},
+		// servers using HTTPS for git are not contacted over SSH
+		{"ssh://git@web.example.org/alice/ephys", ""},
+		{"https://gin.g-node.org/alice/ephys", ""},
+		{"/media/backup/ephys", ""},
+		{"../ephys", ""},
+		{"data/file.txt", ""},
+		{"origin", ""},
+	}
+	for _, test := range tests {
+		alias, ok := sshServerForURL(test.url, servers)
+		if alias != test.alias || ok != (test.alias != "") {
+			t.Errorf("sshServerForURL(%q) = (%q, %v); expected %q", test.url, alias, ok, test.alias)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package git
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/G-Node/gin-cli/ginclient/config"
+	"github.com/G-Node/gin-cli/ginclient/log"
+	"golang.org/x/crypto/ssh"
+)
+
+// Functions for connecting to git servers over SSH through the proxy configured for the server (web.proxy).
+
+// ProxyCommandCmd is the gin subcommand that is used as the ssh ProxyCommand for servers with a proxy.
+const ProxyCommandCmd = "ssh-proxy"
+
+// dialTimeout is the timeout for establishing connections to git servers and proxies.
+const dialTimeout = 30 * time.Second
+
+// ProxyFor returns the proxy URL configured for the git server with the given host and port.
+// It returns an empty string if the server has no proxy configured.
+func ProxyFor(host string, port uint16) string {
+	for _, srvcfg := range config.Read().Servers {
+		if !srvcfg.Git.UseHTTPS() && srvcfg.Git.Host == host && srvcfg.Git.Port == port {
+			return srvcfg.Web.Proxy
+		}
+	}
+	return ""
+}
+
+// bufferedConn is a connection with data that was read ahead into a buffer.
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+// DialProxy opens a connection to addr (host:port) through an HTTP proxy using the CONNECT method.
+// Credentials in the proxy URL are used for basic authentication with the proxy.
+func DialProxy(proxy, addr string) (net.Conn, error) {
+	fn := fmt.Sprintf("DialProxy(%s, %s)", proxy, addr)
+	proxyurl, err := url.Parse(proxy)
+	if err != nil || proxyurl.Host == "" {
+		return nil, giterror{UError: fmt.Sprintf("invalid proxy URL %q", proxy), Origin: fn, Description: fmt.Sprintf("invalid proxy URL %s", proxy)}
+	}
+	if proxyurl.Scheme != "http" {
+		return nil, giterror{UError: fmt.Sprintf("unsupported proxy scheme %q", proxyurl.Scheme), Origin: fn, Description: fmt.Sprintf("only http proxies can be used for SSH connections (proxy %s)", proxy)}
+	}
+	proxyaddr := proxyurl.Host
+	if proxyurl.Port() == "" {
+		proxyaddr = net.JoinHostPort(proxyurl.Hostname(), "80")
+	}
+	conn, err := net.DialTimeout("tcp", proxyaddr, dialTimeout)
+	if err != nil {
+		return nil, giterror{UError: err.Error(), Origin: fn, Description: fmt.Sprintf("failed to connect to proxy %s", proxyurl.Host)}
+	}
+	req := &http.Request{
+		Method: "CONNECT",
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if proxyurl.User != nil {
+		password, _ := proxyurl.User.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(proxyurl.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+	conn.SetDeadline(time.Now().Add(dialTimeout))
+	if err = req.Write(conn); err != nil {
+		conn.Close()
+		return nil, giterror{UError: err.Error(), Origin: fn, Description: "failed to send request to proxy"}
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		return nil, giterror{UError: err.Error(), Origin: fn, Description: "invalid response from proxy"}
+	}
+	if resp.StatusCode != http.StatusOK {
+		// the body of an error response is not read: it may only end when the connection is closed
+		conn.Close()
+		return nil, giterror{UError: resp.Status, Origin: fn, Description: fmt.Sprintf("proxy refused connection to %s: %s", addr, resp.Status)}
+	}
+	conn.SetDeadline(time.Time{})
+	return bufferedConn{Conn: conn, reader: reader}, nil
+}
+
+// dialGit opens a connection to the git server, through the server's proxy if one is configured.
+func dialGit(host string, port uint16) (net.Conn, error) {
+	addr := net.JoinHostPort(host, fmt.Sprintf("%d", port))
+	if proxy := ProxyFor(host, port); proxy != "" {
+		log.Write("Connecting to %s through proxy %s", addr, proxy)
+		return DialProxy(proxy, addr)
+	}
+	return net.DialTimeout("tcp", addr, dialTimeout)
+}
+
+// sshDial is like ssh.Dial for the configured git server, but connects through the server's proxy if one is configured.
+func sshDial(gitconf config.GitCfg, sshcon *ssh.ClientConfig) (*ssh.Client, error) {
+	conn, err := dialGit(gitconf.Host, gitconf.Port)
+	if err != nil {
+		return nil, err
+	}
+	addr := fmt.Sprintf("%s:%d", gitconf.Host, gitconf.Port)
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, sshcon)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// ProxyCommand connects to the git server with the given host and port
+// (through the server's proxy if one is configured) and relays the
+// connection to stdin and stdout. It implements the ssh ProxyCommand used for
+// git operations and returns when the server closes the connection.
+func ProxyCommand(host string, port uint16) error {
+	conn, err := dialGit(host, port)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	go io.Copy(conn, os.Stdin)
+	_, err = io.Copy(os.Stdout, conn)
+	return err
+}
+
+// proxyCommandOpt returns the ssh option that sets up the gin ProxyCommand if any git server has a proxy configured.
+func proxyCommandOpt() string {
+	for _, srvcfg := range config.Read().Servers {
+		if srvcfg.Git.UseHTTPS() || srvcfg.Web.Proxy == "" {
+			continue
+		}
+		ginbin, err := os.Executable()
+		if err != nil {
+			log.Write("Failed to determine gin executable path for ssh ProxyCommand: %v", err)
+			return ""
+		}
+		return fmt.Sprintf("-o 'ProxyCommand=\"%s\" %s %%h %%p'", filepath.ToSlash(ginbin), ProxyCommandCmd)
+	}
+	return ""
+}
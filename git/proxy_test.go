@@ -0,0 +1,86 @@
+package git
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// connectProxy returns an HTTP proxy that supports the CONNECT method and requires the given proxy authorization header.
+func connectProxy(t *testing.T, auth string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "CONNECT" || r.Header.Get("Proxy-Authorization") != auth {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		target, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("Hijack failed: %s", err.Error())
+			return
+		}
+		buf.Flush()
+		go func() {
+			io.Copy(target, conn)
+			target.Close()
+		}()
+		io.Copy(conn, target)
+		conn.Close()
+	}))
+}
+
+func TestDialProxy(t *testing.T) {
+	echo, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err.Error())
+	}
+	defer echo.Close()
+	go func() {
+		for {
+			conn, err := echo.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(conn, conn)
+				conn.Close()
+			}()
+		}
+	}()
+
+	// "user:secret" encoded
+	proxy := connectProxy(t, "Basic dXNlcjpzZWNyZXQ=")
+	defer proxy.Close()
+	proxyurl := "http://user:secret@" + proxy.Listener.Addr().String()
+
+	conn, err := DialProxy(proxyurl, echo.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to connect through proxy: %s", err.Error())
+	}
+	defer conn.Close()
+	msg := []byte("SSH-2.0-gin\r\n")
+	if _, err = conn.Write(msg); err != nil {
+		t.Fatalf("Write failed: %s", err.Error())
+	}
+	reply := make([]byte, len(msg))
+	if _, err = io.ReadFull(conn, reply); err != nil {
+		t.Fatalf("Read failed: %s", err.Error())
+	}
+	if string(reply) != string(msg) {
+		t.Fatalf("Unexpected reply through proxy: %q", reply)
+	}
+
+	if _, err = DialProxy("http://"+proxy.Listener.Addr().String(), echo.Addr().String()); err == nil {
+		t.Fatal("Expected error when proxy authentication fails")
+	}
+	if _, err = DialProxy("socks5://"+proxy.Listener.Addr().String(), echo.Addr().String()); err == nil {
+		t.Fatal("Expected error for unsupported proxy scheme")
+	}
+}
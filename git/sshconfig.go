@@ -0,0 +1,80 @@
+package git
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/G-Node/gin-cli/ginclient/config"
+	"github.com/G-Node/gin-cli/ginclient/credentials"
+)
+
+// Functions for generating the ssh configuration used for git operations.
+
+// sshHostOptions holds the ssh options for a single git host.
+type sshHostOptions struct {
+	identityFiles []string
+	useAgent      bool
+}
+
+// sshConfig returns the contents of the ssh configuration file for git
+// commands that contact the servers with the given aliases.
+// Each git host has a section with the identity files of the servers on that
+// host, so keys are only offered to the servers they belong to, and keys from
+// the SSH agent are only offered to hosts of servers that enable the agent.
+// The user and system configuration files are included at the end, since
+// they are not read when a configuration file is specified.
+func sshConfig(aliases []string) string {
+	servers := config.Read().Servers
+	hosts := make(map[string]*sshHostOptions)
+	var hostnames []string
+	for _, alias := range aliases {
+		srvcfg, ok := servers[alias]
+		if !ok || srvcfg.Git.Host == "" {
+			continue
+		}
+		opts, ok := hosts[srvcfg.Git.Host]
+		if !ok {
+			opts = &sshHostOptions{}
+			hosts[srvcfg.Git.Host] = opts
+			hostnames = append(hostnames, srvcfg.Git.Host)
+		}
+		if keyfile, ok := IdentityFile(alias); ok {
+			opts.identityFiles = append(opts.identityFiles, filepath.ToSlash(keyfile))
+		}
+		opts.useAgent = opts.useAgent || srvcfg.Git.UseAgent
+	}
+	sort.Strings(hostnames)
+
+	var b strings.Builder
+	b.WriteString("# ssh configuration generated by gin for git operations\n")
+	for _, hostname := range hostnames {
+		opts := hosts[hostname]
+		fmt.Fprintf(&b, "Host %s\n", hostname)
+		sort.Strings(opts.identityFiles)
+		for _, keyfile := range opts.identityFiles {
+			fmt.Fprintf(&b, "    IdentityFile \"%s\"\n", keyfile)
+		}
+		identitiesOnly := "yes"
+		if opts.useAgent {
+			identitiesOnly = "no"
+		}
+		fmt.Fprintf(&b, "    IdentitiesOnly %s\n", identitiesOnly)
+	}
+	b.WriteString("Host *\n")
+	b.WriteString("    Include ~/.ssh/config\n")
+	b.WriteString("    Include /etc/ssh/ssh_config\n")
+	return b.String()
+}
+
+// sshConfigFile writes the ssh configuration for git commands that contact
+// the servers with the given aliases (see sshConfig) to a temporary file and
+// returns its path. The file is removed when the credential files are cleaned
+// up (credentials.Cleanup).
+func sshConfigFile(aliases []string) (string, error) {
+	contents := sshConfig(aliases)
+	name := fmt.Sprintf("ssh_config-%x", sha256.Sum256([]byte(contents)))[:24]
+	return credentials.WriteTempFile(name, []byte(contents))
+}
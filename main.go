@@ -5,6 +5,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/G-Node/gin-cli/ginclient/credentials"
 	"github.com/G-Node/gin-cli/ginclient/log"
 	"github.com/G-Node/gin-cli/gincmd"
 	"github.com/G-Node/gin-cli/git"
@@ -37,15 +38,17 @@ func init() {
 		annexVer = err.Error()
 	}
 	verinfo.Annex = annexVer
+	err = log.Init()
 	if err != nil {
-		fmt.Println("Failed to initialise log file")
+		// stderr: stdout is reserved for the output of helper commands (git-credential, ssh-proxy)
+		fmt.Fprintln(os.Stderr, "Failed to initialise log file")
 	}
-	err = log.Init()
 	log.Write("VERSION: %s", verinfo.String())
 }
 
 func main() {
 	defer log.Close()
+	defer credentials.Cleanup()
 
 	var args = make([]string, len(os.Args))
 	for idx, a := range os.Args {
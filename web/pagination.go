@@ -0,0 +1,121 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+
+	"github.com/G-Node/gin-cli/ginclient/log"
+)
+
+// PageSize is the number of items requested per page of a paginated list.
+const PageSize = 50
+
+// maxPages limits the number of pages retrieved for a single list.
+const maxPages = 1000
+
+// PageHandler processes one page of a paginated list. It receives the
+// response, whose status should be checked, and the response body, and
+// returns the number of items on the page. Retrieval stops if the handler
+// returns an error, which is then returned to the caller.
+type PageHandler func(res *http.Response, body []byte) (int, error)
+
+var linkNextRe = regexp.MustCompile(`<([^>]*)>\s*;[^,]*rel="?next"?`)
+
+// nextLink returns the URL of the next page from a Link header (RFC 8288), or an empty string if there is none.
+func nextLink(header string) string {
+	match := linkNextRe.FindStringSubmatch(header)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+// pageURL returns the URL for the given page of the list at requrl using the page and limit query parameters.
+func pageURL(requrl *url.URL, page int) string {
+	pageurl := *requrl
+	query := pageurl.Query()
+	query.Set("page", strconv.Itoa(page))
+	query.Set("limit", strconv.Itoa(PageSize))
+	pageurl.RawQuery = query.Encode()
+	return pageurl.String()
+}
+
+// GetPages retrieves all pages of the list at address and passes each page to
+// the handler. The address is appended to the client host, so it should be
+// specified without a host prefix. It may include query parameters, which are
+// kept for all pages.
+//
+// Pages are followed using the 'next' link of the Link response header. If the
+// server does not send Link headers, pages are requested with the 'page' and
+// 'limit' query parameters until a page contains fewer than PageSize items.
+// Servers that do not paginate the list return the full list on the first page.
+func (cl *Client) GetPages(ctx context.Context, address string, handler PageHandler) error {
+	return cl.getPages(ctx, address, cl.setTokenAuth, handler)
+}
+
+// GetPagesBasicAuth is like GetPages, but the username and password are used to perform Basic authentication.
+func (cl *Client) GetPagesBasicAuth(ctx context.Context, address, username, password string, handler PageHandler) error {
+	return cl.getPages(ctx, address, basicAuth(username, password), handler)
+}
+
+func (cl *Client) getPages(ctx context.Context, address string, auth func(*http.Request), handler PageHandler) error {
+	fn := "GetPages(" + address + ")"
+	ref, err := url.Parse(address)
+	if err != nil {
+		return weberror{UError: err.Error(), Origin: fn, Description: "invalid request address"}
+	}
+	listurl, err := url.Parse(urlJoin(cl.Host, ref.Path))
+	if err != nil || listurl.Host == "" {
+		return weberror{UError: "invalid URL", Origin: fn, Description: "invalid request address"}
+	}
+	listurl.RawQuery = ref.RawQuery
+	requrl := pageURL(listurl, 1)
+	var prevbody []byte
+	for page := 1; page <= maxPages; page++ {
+		res, err := cl.sendURL(ctx, http.MethodGet, requrl, nil, auth)
+		if err != nil {
+			return err
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return weberror{UError: err.Error(), Origin: fn, Description: "failed to read response body"}
+		}
+		if page > 1 && res.StatusCode == http.StatusOK && bytes.Equal(body, prevbody) {
+			// the server ignores the page parameter and repeats the full list
+			log.Write("Server returned the same list for page %d; stopping", page)
+			return nil
+		}
+		nitems, err := handler(res, body)
+		if err != nil {
+			return err
+		}
+		if res.StatusCode != http.StatusOK {
+			return nil
+		}
+		prevbody = body
+
+		if link := nextLink(res.Header.Get("Link")); link != "" {
+			next, err := listurl.Parse(link)
+			if err != nil || next.Host != listurl.Host {
+				// never send credentials to a different host
+				log.Write("Ignoring invalid next page link %q", link)
+				return nil
+			}
+			requrl = next.String()
+			continue
+		}
+		if res.Header.Get("Link") != "" || nitems < PageSize {
+			// last page
+			return nil
+		}
+		requrl = pageURL(listurl, page+1)
+	}
+	log.Write("Stopped retrieving %s after %d pages", address, maxPages)
+	return nil
+}
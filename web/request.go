@@ -0,0 +1,129 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/G-Node/gin-cli/ginclient/log"
+)
+
+// Default request settings, used by New.
+const (
+	DefaultTimeout = 60 * time.Second
+	DefaultRetries = 3
+	DefaultBackoff = time.Second
+)
+
+// active is the number of requests in progress.
+var active int32
+
+// Active returns true if any request is in progress.
+func Active() bool {
+	return atomic.LoadInt32(&active) > 0
+}
+
+// idempotent returns true if requests with the given method can safely be repeated.
+func idempotent(method string) bool {
+	return method == http.MethodGet || method == http.MethodDelete
+}
+
+// transient returns true if a failed request may succeed if repeated.
+// Certificate errors and invalid client options are not transient.
+func (cl *Client) transient(res *http.Response, err error) bool {
+	if err != nil {
+		errmsg := err.Error()
+		return cl.err == nil && !strings.Contains(errmsg, "x509: ") && !strings.Contains(errmsg, "tls: ")
+	}
+	return res.StatusCode >= 500
+}
+
+// discard reads and closes the body of a response that is not returned to the caller.
+func discard(res *http.Response) {
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+}
+
+// send sends a request with the given method to address. The data (if not
+// nil) is sent JSON encoded as the body of the request, and the auth function
+// (if not nil) sets the authorisation header.
+// Requests with idempotent methods (GET and DELETE) are retried with
+// exponential backoff if the connection fails or the server responds with a
+// server error (5xx). The request, and any waiting between attempts, is
+// cancelled when ctx is done.
+func (cl *Client) send(ctx context.Context, method, address string, data interface{}, auth func(*http.Request)) (*http.Response, error) {
+	return cl.sendURL(ctx, method, urlJoin(cl.Host, address), data, auth)
+}
+
+// sendURL is like send, but takes the full URL of the request instead of an address relative to the client host.
+func (cl *Client) sendURL(ctx context.Context, method, requrl string, data interface{}, auth func(*http.Request)) (*http.Response, error) {
+	fn := fmt.Sprintf("%s(%s)", method, requrl)
+	var body []byte
+	if data != nil {
+		var err error
+		if body, err = json.Marshal(data); err != nil {
+			return nil, weberror{UError: err.Error(), Origin: fn}
+		}
+	}
+	attempts := 1
+	if idempotent(method) {
+		attempts += cl.retries
+	}
+	cancelled := func() error {
+		return weberror{UError: ctx.Err().Error(), Origin: fn, Description: "request cancelled"}
+	}
+
+	atomic.AddInt32(&active, 1)
+	defer atomic.AddInt32(&active, -1)
+	delay := cl.backoff
+	for attempt := 1; ; attempt++ {
+		req, err := http.NewRequest(method, requrl, bytes.NewReader(body))
+		if err != nil {
+			return nil, weberror{UError: err.Error(), Origin: fn}
+		}
+		req = req.WithContext(ctx)
+		req.Header.Set("content-type", "application/json")
+		if auth != nil {
+			auth(req)
+		}
+		log.Write("Performing %s: %s", method, req.URL)
+		res, err := cl.do(req)
+		if ctx.Err() != nil {
+			if err == nil {
+				discard(res)
+			}
+			return nil, cancelled()
+		}
+		if attempt >= attempts || !cl.transient(res, err) {
+			if err != nil {
+				return nil, weberror{UError: err.Error(), Origin: fn, Description: parseServerError(err)}
+			}
+			return res, nil
+		}
+
+		var reason string
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = res.Status
+			discard(res)
+		}
+		// wait between delay and 1.5 × delay
+		wait := delay + time.Duration(rand.Int63n(int64(delay)/2+1))
+		log.Write("Request failed (attempt %d of %d): %s; retrying in %s", attempt, attempts, reason, wait)
+		select {
+		case <-ctx.Done():
+			return nil, cancelled()
+		case <-time.After(wait):
+		}
+		delay *= 2
+	}
+}
@@ -0,0 +1,109 @@
+package web
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/G-Node/gin-cli/ginclient/log"
+)
+
+// Options holds the connection settings of a Client.
+type Options struct {
+	// CABundle is a file with PEM encoded certificates of additional certificate authorities to trust (e.g., an institutional CA).
+	CABundle string
+	// Insecure disables verification of the server certificate.
+	Insecure bool
+	// ClientCert and ClientKey are PEM encoded files with the certificate and private key used for TLS client authentication.
+	ClientCert string
+	ClientKey  string
+	// Proxy is the URL of the proxy used for all requests.
+	// If it is empty, the proxy is determined from the environment (HTTPS_PROXY, HTTP_PROXY, NO_PROXY).
+	Proxy string
+	// Timeout is the time limit for each attempt of a request, including reading the response. Zero means no timeout.
+	Timeout time.Duration
+	// Retries is the number of times a failed GET or DELETE request is repeated.
+	Retries int
+	// Backoff is the time to wait before the first retry. It is doubled for each subsequent retry.
+	Backoff time.Duration
+}
+
+// TLSConfig returns the TLS configuration for the options.
+func (opts Options) TLSConfig() (*tls.Config, error) {
+	fn := "Options.TLSConfig()"
+	tlsconf := &tls.Config{InsecureSkipVerify: opts.Insecure}
+	if opts.CABundle != "" {
+		pem, err := ioutil.ReadFile(opts.CABundle)
+		if err != nil {
+			return nil, weberror{UError: err.Error(), Origin: fn, Description: fmt.Sprintf("failed to read CA bundle %s", opts.CABundle)}
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			log.Write("Failed to load system certificates: %v", err)
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, weberror{UError: "no certificates found", Origin: fn, Description: fmt.Sprintf("CA bundle %s does not contain any PEM encoded certificates", opts.CABundle)}
+		}
+		tlsconf.RootCAs = pool
+	}
+	if opts.ClientCert != "" || opts.ClientKey != "" {
+		if opts.ClientCert == "" || opts.ClientKey == "" {
+			return nil, weberror{UError: "incomplete client certificate configuration", Origin: fn, Description: "both a client certificate and a client key are required for TLS client authentication"}
+		}
+		cert, err := tls.LoadX509KeyPair(opts.ClientCert, opts.ClientKey)
+		if err != nil {
+			return nil, weberror{UError: err.Error(), Origin: fn, Description: "failed to load client certificate"}
+		}
+		tlsconf.Certificates = []tls.Certificate{cert}
+	}
+	return tlsconf, nil
+}
+
+// Transport returns an HTTP transport configured with the options.
+// Apart from the TLS and proxy settings, it uses the defaults of the standard library (including timeouts for connecting and TLS handshakes).
+func (opts Options) Transport() (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	tlsconf, err := opts.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsconf
+	if opts.Proxy != "" {
+		proxyurl, err := url.Parse(opts.Proxy)
+		if err != nil || proxyurl.Host == "" {
+			return nil, weberror{UError: fmt.Sprintf("invalid proxy URL %q", opts.Proxy), Origin: "Options.Transport()", Description: fmt.Sprintf("invalid proxy URL %s", opts.Proxy)}
+		}
+		transport.Proxy = http.ProxyURL(proxyurl)
+	}
+	return transport, nil
+}
+
+// NewWithOptions creates a new client for a given host with the given connection options.
+// If the options are invalid (e.g., the CA bundle can not be read), the error is returned by every request made with the client.
+func NewWithOptions(host string, opts Options) *Client {
+	cl := &Client{Host: host, web: &http.Client{Timeout: opts.Timeout}, retries: opts.Retries, backoff: opts.Backoff}
+	if cl.backoff <= 0 {
+		cl.backoff = DefaultBackoff
+	}
+	transport, err := opts.Transport()
+	if err != nil {
+		log.Write("Invalid connection options for %s: %v", host, err)
+		cl.err = err
+		return cl
+	}
+	cl.web.Transport = transport
+	return cl
+}
+
+// do sends a request with the client's HTTP client, unless the client's connection options are invalid.
+func (cl *Client) do(req *http.Request) (*http.Response, error) {
+	if cl.err != nil {
+		return nil, cl.err
+	}
+	return cl.web.Do(req)
+}
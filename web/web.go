@@ -7,18 +7,18 @@ package web
 
 import (
 	"bytes"
+	"context"
 	"encoding/gob"
-	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
 	"path"
-	"path/filepath"
 	"strings"
+	"time"
 
-	"github.com/G-Node/gin-cli/ginclient/config"
+	"github.com/G-Node/gin-cli/ginclient/credentials"
 	"github.com/G-Node/gin-cli/ginclient/log"
 	"github.com/G-Node/gin-cli/git/shell"
 	gogs "github.com/gogits/go-gogs-client"
@@ -38,8 +38,17 @@ type Client struct {
 	Host string
 	UserToken
 	web *http.Client
+	// err is set if the connection options of the client are invalid
+	err error
+	// retries and backoff control the repetition of failed requests (see Options)
+	retries int
+	backoff time.Duration
 }
 
+// urlJoin appends the path parts to the URL given as the first part.
+// Escape sequences in the parts (e.g., path segments escaped with
+// url.PathEscape) are kept, so that escaped slashes are not split.
+// A query string in a part (e.g., "trees/master?recursive=1") becomes the query of the URL.
 func urlJoin(parts ...string) string {
 	// First part must be a valid URL
 	u, err := url.Parse(parts[0])
@@ -49,7 +58,15 @@ func urlJoin(parts ...string) string {
 	}
 
 	for _, part := range parts[1:] {
-		u.Path = path.Join(u.Path, part)
+		if idx := strings.Index(part, "?"); idx >= 0 {
+			part, u.RawQuery = part[:idx], part[idx+1:]
+		}
+		rawpath := path.Join(u.EscapedPath(), part)
+		if unescaped, err := url.PathUnescape(rawpath); err == nil {
+			u.Path, u.RawPath = unescaped, rawpath
+		} else {
+			u.Path, u.RawPath = path.Join(u.Path, part), ""
+		}
 	}
 	return u.String()
 }
@@ -62,146 +79,140 @@ func parseServerError(err error) (errmsg string) {
 			errmsg = "server refused connection"
 		} else if strings.HasSuffix(errmsg, "no such host") {
 			errmsg = "server unreachable"
-		} else if strings.HasSuffix(errmsg, "timeout") {
+		} else if strings.HasSuffix(errmsg, "timeout") || strings.Contains(errmsg, "Client.Timeout exceeded") {
 			errmsg = "request timed out"
+		} else if strings.Contains(errmsg, "x509: certificate signed by unknown authority") {
+			errmsg = "server certificate is not trusted: the certificate of the issuing authority can be added with the 'web.cabundle' configuration option"
+		} else if strings.Contains(errmsg, "remote error: tls: ") && strings.Contains(errmsg, "certificate") {
+			errmsg = "server rejected the connection: a valid client certificate may be required (see the 'web.clientcert' and 'web.clientkey' configuration options)"
 		}
 	}
 	return
 }
 
-// Get sends a GET request to address.
-// The address is appended to the client host, so it should be specified without a host prefix.
-func (cl *Client) Get(address string) (*http.Response, error) {
-	requrl := urlJoin(cl.Host, address)
-	req, err := http.NewRequest("GET", requrl, nil)
-	if err != nil {
-		return nil, weberror{UError: err.Error(), Origin: fmt.Sprintf("Get(%s)", requrl)}
-	}
-	req.Header.Set("content-type", "application/jsonAuthorization")
-	log.Write("Performing GET: %s", req.URL)
+// setTokenAuth adds the client's token (if any) to the request.
+func (cl *Client) setTokenAuth(req *http.Request) {
 	if cl.Token != "" {
 		req.Header.Set("Authorization", fmt.Sprintf("token %s", cl.Token))
 	}
-	resp, err := cl.web.Do(req)
-	if err != nil {
-		return nil, weberror{UError: err.Error(), Origin: fmt.Sprintf("Get(%s)", requrl), Description: parseServerError(err)}
+}
+
+// basicAuth returns a function that adds Basic authentication with the given username and password to a request.
+func basicAuth(username, password string) func(*http.Request) {
+	return func(req *http.Request) {
+		req.Header.Set("Authorization", fmt.Sprintf("Basic %s", gogs.BasicAuthEncode(username, password)))
 	}
-	return resp, nil
+}
+
+// Get sends a GET request to address.
+// The address is appended to the client host, so it should be specified without a host prefix.
+func (cl *Client) Get(ctx context.Context, address string) (*http.Response, error) {
+	return cl.send(ctx, http.MethodGet, address, nil, cl.setTokenAuth)
 }
 
 // Post sends a POST request to address with the provided data.
 // The address is appended to the client host, so it should be specified without a host prefix.
-func (cl *Client) Post(address string, data interface{}) (*http.Response, error) {
-	fn := fmt.Sprintf("Post(%s, <data>)", address)
-	datajson, err := json.Marshal(data)
-	if err != nil {
-		return nil, weberror{UError: err.Error(), Origin: fn}
-	}
-	requrl := urlJoin(cl.Host, address)
-	req, err := http.NewRequest("POST", requrl, bytes.NewReader(datajson))
-	if err != nil {
-		return nil, weberror{UError: err.Error(), Origin: fn}
-	}
-	req.Header.Set("content-type", "application/jsonAuthorization")
-	if cl.Token != "" {
-		req.Header.Set("Authorization", fmt.Sprintf("token %s", cl.Token))
-		log.Write("Added token to POST")
-	}
-	log.Write("Performing POST: %s", req.URL)
-	resp, err := cl.web.Do(req)
-	if err != nil {
-		err = weberror{UError: err.Error(), Origin: fn, Description: parseServerError(err)}
-	}
-	return resp, err
+func (cl *Client) Post(ctx context.Context, address string, data interface{}) (*http.Response, error) {
+	return cl.send(ctx, http.MethodPost, address, data, cl.setTokenAuth)
+}
+
+// Put sends a PUT request to address with the provided data (may be nil).
+// The address is appended to the client host, so it should be specified without a host prefix.
+func (cl *Client) Put(ctx context.Context, address string, data interface{}) (*http.Response, error) {
+	return cl.send(ctx, http.MethodPut, address, data, cl.setTokenAuth)
+}
+
+// Patch sends a PATCH request to address with the provided data.
+// The address is appended to the client host, so it should be specified without a host prefix.
+func (cl *Client) Patch(ctx context.Context, address string, data interface{}) (*http.Response, error) {
+	return cl.send(ctx, http.MethodPatch, address, data, cl.setTokenAuth)
+}
+
+// Delete sends a DELETE request to address.
+func (cl *Client) Delete(ctx context.Context, address string) (*http.Response, error) {
+	return cl.send(ctx, http.MethodDelete, address, nil, cl.setTokenAuth)
 }
 
 // GetBasicAuth sends a GET request to address.
 // The username and password are used to perform Basic authentication.
-func (cl *Client) GetBasicAuth(address, username, password string) (*http.Response, error) {
-	fn := fmt.Sprintf("GetBasicAuth(%s)", address)
-	requrl := urlJoin(cl.Host, address)
-	req, err := http.NewRequest("GET", requrl, nil)
-	if err != nil {
-		return nil, weberror{UError: err.Error(), Origin: fn}
-	}
-	req.Header.Set("content-type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Basic %s", gogs.BasicAuthEncode(username, password)))
-	log.Write("Performing GET: %s", req.URL)
-	resp, err := cl.web.Do(req)
-	if err != nil {
-		err = weberror{UError: err.Error(), Origin: fn, Description: parseServerError(err)}
-	}
-	return resp, err
+func (cl *Client) GetBasicAuth(ctx context.Context, address, username, password string) (*http.Response, error) {
+	return cl.send(ctx, http.MethodGet, address, nil, basicAuth(username, password))
 }
 
 // PostBasicAuth sends a POST request to address with the provided data.
 // The username and password are used to perform Basic authentication.
-func (cl *Client) PostBasicAuth(address, username, password string, data interface{}) (*http.Response, error) {
-	fn := fmt.Sprintf("PostBasicAuth(%s)", address)
-	datajson, err := json.Marshal(data)
-	if err != nil {
-		return nil, weberror{UError: err.Error(), Origin: fn}
-	}
-	requrl := urlJoin(cl.Host, address)
-	req, err := http.NewRequest("POST", requrl, bytes.NewReader(datajson))
-	if err != nil {
-		return nil, weberror{UError: err.Error(), Origin: fn}
-	}
-	req.Header.Set("content-type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Basic %s", gogs.BasicAuthEncode(username, password)))
-	log.Write("Performing POST: %s", req.URL)
-	resp, err := cl.web.Do(req)
-	if err != nil {
-		err = weberror{UError: err.Error(), Origin: fn, Description: parseServerError(err)}
-	}
-	return resp, err
+func (cl *Client) PostBasicAuth(ctx context.Context, address, username, password string, data interface{}) (*http.Response, error) {
+	return cl.send(ctx, http.MethodPost, address, data, basicAuth(username, password))
 }
 
-// Delete sends a DELETE request to address.
-func (cl *Client) Delete(address string) (*http.Response, error) {
-	fn := fmt.Sprintf("Delete(%s)", address)
-	requrl := urlJoin(cl.Host, address)
-	req, err := http.NewRequest("DELETE", requrl, nil)
-	if err != nil {
-		return nil, weberror{UError: err.Error(), Origin: fn}
-	}
-	req.Header.Set("content-type", "application/jsonAuthorization")
-	if cl.Token != "" {
-		req.Header.Set("Authorization", fmt.Sprintf("token %s", cl.Token))
-		log.Write("Added token to DELETE")
-	}
-	log.Write("Performing DELETE: %s", req.URL)
-	resp, err := cl.web.Do(req)
-	if err != nil {
-		err = weberror{UError: err.Error(), Origin: fn, Description: parseServerError(err)}
-	}
-	return resp, err
+// DeleteBasicAuth sends a DELETE request to address.
+// The username and password are used to perform Basic authentication.
+func (cl *Client) DeleteBasicAuth(ctx context.Context, address, username, password string) (*http.Response, error) {
+	return cl.send(ctx, http.MethodDelete, address, nil, basicAuth(username, password))
 }
 
-// New creates a new client for a given host.
+// New creates a new client for a given host with the default connection options.
 func New(host string) *Client {
-	return &Client{Host: host, web: &http.Client{}}
+	return NewWithOptions(host, Options{Timeout: DefaultTimeout, Retries: DefaultRetries, Backoff: DefaultBackoff})
+}
+
+// Environment variables that provide a token (and optionally the username) for
+// non-interactive use. When set, they take precedence over the token file.
+// The token is only used for the server named in GIN_TOKEN_SERVER, or the
+// server 'gin' if it is not set, so that it is never sent to another server.
+const (
+	TokenEnvVar       = "GIN_TOKEN"
+	UsernameEnvVar    = "GIN_USERNAME"
+	TokenServerEnvVar = "GIN_TOKEN_SERVER"
+)
+
+// DefaultTokenServer is the server the token from the environment is used for when GIN_TOKEN_SERVER is not set.
+const DefaultTokenServer = "gin"
+
+// EnvToken returns the token and username set in the environment if they
+// belong to the server with the given alias.
+// The token is empty if it is not set or it belongs to another server.
+func EnvToken(srvalias string) (token, username string) {
+	token = strings.TrimSpace(os.Getenv(TokenEnvVar))
+	if token == "" {
+		return "", ""
+	}
+	tokenserver := strings.TrimSpace(os.Getenv(TokenServerEnvVar))
+	if tokenserver == "" {
+		tokenserver = DefaultTokenServer
+	}
+	if tokenserver != srvalias {
+		log.Write("Ignoring token from environment variable %s for server %s: token belongs to server %s", TokenEnvVar, srvalias, tokenserver)
+		return "", ""
+	}
+	return token, strings.TrimSpace(os.Getenv(UsernameEnvVar))
 }
 
 // LoadToken reads the username and auth token from the token file and sets the
 // values in the struct.
+// If the GIN_TOKEN environment variable is set for the server (see EnvToken),
+// the token (and the username from GIN_USERNAME, if set) are taken from the
+// environment instead.
 func (ut *UserToken) LoadToken(srvalias string) error {
 	fn := fmt.Sprintf("LoadToken(%s)", srvalias)
 	if ut.Username != "" && ut.Token != "" {
 		return nil
 	}
-	path, _ := config.Path(false) // Error can only occur when create=True
-	filename := fmt.Sprintf("%s.token", srvalias)
-	filepath := filepath.Join(path, filename)
-	log.Write("Loading token [server %s] %s", srvalias, filepath)
-	file, err := os.Open(filepath)
+	if token, username := EnvToken(srvalias); token != "" {
+		log.Write("Using token from environment variable %s [server %s]", TokenEnvVar, srvalias)
+		ut.Token = token
+		ut.Username = username
+		return nil
+	}
+	name := TokenName(srvalias)
+	log.Write("Loading token [server %s] %s", srvalias, name)
+	data, err := credentials.Load(name)
 	if err != nil {
 		log.Write("Failed to load")
 		return weberror{UError: err.Error(), Origin: fn, Description: "failed to load user token"}
 	}
-	defer closeFile(file)
 
-	decoder := gob.NewDecoder(file)
+	decoder := gob.NewDecoder(bytes.NewReader(data))
 	err = decoder.Decode(ut)
 	if err != nil {
 		log.Write("Failed to parse")
@@ -210,39 +221,28 @@ func (ut *UserToken) LoadToken(srvalias string) error {
 	return nil
 }
 
-// StoreToken saves the username and auth token to the token file.
+// StoreToken saves the username and auth token in the configured credential store.
 func (ut *UserToken) StoreToken(srvalias string) error {
 	fn := fmt.Sprintf("StoreToken(%s)", srvalias)
-	path, err := config.Path(true)
+	var buf bytes.Buffer
+	encoder := gob.NewEncoder(&buf)
+	err := encoder.Encode(ut)
 	if err != nil {
-		return weberror{UError: err.Error(), Origin: fn}
+		return weberror{UError: err.Error(), Origin: fn, Description: "failed to encode token"}
 	}
-	filename := fmt.Sprintf("%s.token", srvalias)
-	filepath := filepath.Join(path, filename)
-	log.Write("Saving token [server %s] %s", srvalias, filepath)
-	file, err := os.Create(filepath)
-	if err != nil {
-		log.Write("Failed to create token file %s", filepath)
-		return weberror{UError: err.Error(), Origin: fn, Description: fmt.Sprintf("failed to create token file %s", filepath)}
-	}
-	defer closeFile(file)
-
-	encoder := gob.NewEncoder(file)
-	err = encoder.Encode(ut)
-	if err != nil {
-		log.Write("Failed to write token to file %s", filepath)
+	name := TokenName(srvalias)
+	log.Write("Saving token [server %s] %s", srvalias, name)
+	if err = credentials.Save(name, buf.Bytes()); err != nil {
+		log.Write("Failed to store token %s", name)
 		return weberror{UError: err.Error(), Origin: fn, Description: "failed to store token"}
 	}
 	log.Write("Saved")
 	return nil
 }
 
-// DeleteToken deletes the token file if it exists (for finalising a logout).
+// DeleteToken deletes the token from the credential store if it exists (for finalising a logout).
 func DeleteToken(srvalias string) error {
-	path, _ := config.Path(false) // Error can only occur when create=True
-	filename := fmt.Sprintf("%s.token", srvalias)
-	tokenpath := filepath.Join(path, filename)
-	err := os.Remove(tokenpath)
+	err := credentials.Delete(TokenName(srvalias))
 	if err != nil {
 		return weberror{UError: err.Error(), Origin: "DeleteToken()", Description: "could not delete token"}
 	}
@@ -250,11 +250,12 @@ func DeleteToken(srvalias string) error {
 	return nil
 }
 
+// TokenName returns the name of the token credential for the given server alias.
+func TokenName(srvalias string) string {
+	return fmt.Sprintf("%s.token", srvalias)
+}
+
 // CloseRes closes a given result buffer (for use with defer).
 func CloseRes(b io.ReadCloser) {
 	b.Close()
 }
-
-func closeFile(f *os.File) {
-	_ = f.Close()
-}
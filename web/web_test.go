@@ -0,0 +1,371 @@
+package web
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func okHandler(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("{}"))
+}
+
+func writePEM(t *testing.T, path, blocktype string, data []byte) {
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blocktype, Bytes: data}), 0600); err != nil {
+		t.Fatalf("Failed to write %s: %s", path, err.Error())
+	}
+}
+
+func checkGet(t *testing.T, cl *Client, expectOK bool) error {
+	res, err := cl.Get(context.Background(), "/api/v1/user")
+	if err != nil {
+		if expectOK {
+			t.Fatalf("Request failed: %s", err.Error())
+		}
+		return err
+	}
+	defer res.Body.Close()
+	if !expectOK {
+		t.Fatalf("Request succeeded unexpectedly: %s", res.Status)
+	}
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("Unexpected response: %s", res.Status)
+	}
+	return nil
+}
+
+func TestCABundle(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(okHandler))
+	defer srv.Close()
+	tmpdir := t.TempDir()
+	cabundle := filepath.Join(tmpdir, "ca.pem")
+	writePEM(t, cabundle, "CERTIFICATE", srv.Certificate().Raw)
+
+	// server certificate is not trusted by default
+	err := checkGet(t, New(srv.URL), false)
+	if !strings.Contains(err.Error(), "web.cabundle") {
+		t.Fatalf("Error does not mention CA bundle option: %s", err.Error())
+	}
+
+	checkGet(t, NewWithOptions(srv.URL, Options{CABundle: cabundle}), true)
+	checkGet(t, NewWithOptions(srv.URL, Options{Insecure: true}), true)
+
+	// invalid options are reported on request
+	checkGet(t, NewWithOptions(srv.URL, Options{CABundle: filepath.Join(tmpdir, "missing.pem")}), false)
+	notpem := filepath.Join(tmpdir, "notpem.txt")
+	ioutil.WriteFile(notpem, []byte("not a certificate"), 0600)
+	checkGet(t, NewWithOptions(srv.URL, Options{CABundle: notpem}), false)
+}
+
+func TestClientCertificate(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate client key: %s", err.Error())
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "gin-client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	certder, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create client certificate: %s", err.Error())
+	}
+	cert, _ := x509.ParseCertificate(certder)
+	keyder, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to encode client key: %s", err.Error())
+	}
+	tmpdir := t.TempDir()
+	certfile := filepath.Join(tmpdir, "client.pem")
+	keyfile := filepath.Join(tmpdir, "client.key")
+	writePEM(t, certfile, "CERTIFICATE", certder)
+	writePEM(t, keyfile, "EC PRIVATE KEY", keyder)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(cert)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(okHandler))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	srv.StartTLS()
+	defer srv.Close()
+
+	checkGet(t, NewWithOptions(srv.URL, Options{Insecure: true}), false)
+	checkGet(t, NewWithOptions(srv.URL, Options{Insecure: true, ClientCert: certfile, ClientKey: keyfile}), true)
+	// certificate without key
+	checkGet(t, NewWithOptions(srv.URL, Options{Insecure: true, ClientCert: certfile}), false)
+}
+
+func TestProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		okHandler(w, r)
+	}))
+	defer proxy.Close()
+
+	checkGet(t, NewWithOptions("http://gin.invalid", Options{Proxy: proxy.URL}), true)
+	if proxied != "http://gin.invalid/api/v1/user" {
+		t.Fatalf("Request was not sent through proxy: %q", proxied)
+	}
+
+	checkGet(t, NewWithOptions("http://gin.invalid", Options{Proxy: "::invalid"}), false)
+}
+
+func TestRetry(t *testing.T) {
+	var hits int
+	failures := 2
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if hits <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		okHandler(w, r)
+	}))
+	defer srv.Close()
+	opts := Options{Retries: 3, Backoff: time.Millisecond}
+
+	checkGet(t, NewWithOptions(srv.URL, opts), true)
+	if hits != 3 {
+		t.Fatalf("Expected 3 attempts, got %d", hits)
+	}
+
+	// POST is not idempotent and is never repeated
+	hits = 0
+	res, err := NewWithOptions(srv.URL, opts).Post(context.Background(), "/api/v1/user/repos", struct{}{})
+	if err != nil {
+		t.Fatalf("POST failed: %s", err.Error())
+	}
+	res.Body.Close()
+	if hits != 1 || res.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Expected a single failed POST attempt, got %d attempts (%s)", hits, res.Status)
+	}
+
+	// the last response is returned when all retries fail
+	hits = 0
+	failures = 10
+	res, err = NewWithOptions(srv.URL, opts).Get(context.Background(), "/api/v1/user")
+	if err != nil {
+		t.Fatalf("GET failed: %s", err.Error())
+	}
+	res.Body.Close()
+	if hits != 4 || res.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 4 failed attempts, got %d attempts (%s)", hits, res.Status)
+	}
+
+	// connection errors are retried
+	srv.Close()
+	start := time.Now()
+	opts.Backoff = 20 * time.Millisecond
+	checkGet(t, NewWithOptions(srv.URL, opts), false)
+	if elapsed := time.Since(start); elapsed < 140*time.Millisecond {
+		t.Fatalf("Connection errors were not retried with backoff (%s)", elapsed)
+	}
+}
+
+func TestTimeoutAndCancel(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	err := checkGet(t, NewWithOptions(srv.URL, Options{Timeout: 50 * time.Millisecond}), false)
+	if !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("Unexpected timeout error: %s", err.Error())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	_, err = NewWithOptions(srv.URL, Options{Retries: 3}).Get(ctx, "/api/v1/user")
+	if err == nil || !strings.Contains(err.Error(), "cancelled") {
+		t.Fatalf("Expected cancellation error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("Cancelled request took %s", elapsed)
+	}
+	if Active() {
+		t.Fatal("Request still marked as active after cancellation")
+	}
+}
+
+func TestGetPages(t *testing.T) {
+	items := make([]int, 2*PageSize+10)
+	for idx := range items {
+		items[idx] = idx
+	}
+	var linkheaders bool
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		start := (page - 1) * PageSize
+		end := start + PageSize
+		if end >= len(items) {
+			end = len(items)
+		} else if linkheaders {
+			w.Header().Set("Link", fmt.Sprintf(`<%s?page=%d&limit=%d>; rel="next", <%s?page=3>; rel="last"`, r.URL.Path, page+1, PageSize, r.URL.Path))
+		}
+		if linkheaders && end == len(items) {
+			w.Header().Set("Link", fmt.Sprintf(`<%s?page=1>; rel="first"`, r.URL.Path))
+		}
+		json.NewEncoder(w).Encode(items[start:end])
+	}))
+	defer srv.Close()
+
+	count := func(res *http.Response, body []byte) (int, error) {
+		var page []int
+		if err := json.Unmarshal(body, &page); err != nil {
+			return 0, err
+		}
+		return len(page), nil
+	}
+	for _, linkheaders = range []bool{false, true} {
+		requests = 0
+		var total int
+		err := New(srv.URL).GetPages(context.Background(), "/api/v1/user/repos", func(res *http.Response, body []byte) (int, error) {
+			n, err := count(res, body)
+			total += n
+			return n, err
+		})
+		if err != nil {
+			t.Fatalf("GetPages failed: %s", err.Error())
+		}
+		if total != len(items) || requests != 3 {
+			t.Fatalf("Expected %d items in 3 requests (Link headers: %t), got %d items in %d requests", len(items), linkheaders, total, requests)
+		}
+	}
+
+	// servers without pagination return the full list for every page
+	full := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(items)
+	}))
+	defer full.Close()
+	var total int
+	err := New(full.URL).GetPages(context.Background(), "/api/v1/user/repos", func(res *http.Response, body []byte) (int, error) {
+		n, err := count(res, body)
+		total += n
+		return n, err
+	})
+	if err != nil || total != len(items) {
+		t.Fatalf("Expected %d items from unpaginated list, got %d (%v)", len(items), total, err)
+	}
+
+	// query parameters of the request are kept on every page
+	t.Run("query", func(t *testing.T) {
+		var pages []string
+		search := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if q := r.URL.Query().Get("q"); q != "data" {
+				t.Errorf("Query parameter q=%q in request %s; expected \"data\"", q, r.URL)
+			}
+			pages = append(pages, r.URL.Query().Get("page"))
+			page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+			start := (page - 1) * PageSize
+			end := start + PageSize
+			if end >= len(items) {
+				end = len(items)
+			} else {
+				w.Header().Set("Link", fmt.Sprintf(`<%s?q=data&page=%d&limit=%d>; rel="next"`, r.URL.Path, page+1, PageSize))
+			}
+			json.NewEncoder(w).Encode(items[start:end])
+		}))
+		defer search.Close()
+		var total int
+		err := New(search.URL).GetPages(context.Background(), "/api/v1/repos/search?q=data", func(res *http.Response, body []byte) (int, error) {
+			n, err := count(res, body)
+			total += n
+			return n, err
+		})
+		if err != nil {
+			t.Fatalf("GetPages failed: %s", err.Error())
+		}
+		if total != len(items) || strings.Join(pages, ",") != "1,2,3" {
+			t.Fatalf("Expected %d items from pages 1,2,3, got %d items from pages %v", len(items), total, pages)
+		}
+	})
+}
+
+func TestEnvToken(t *testing.T) {
+	os.Setenv(TokenEnvVar, " envtoken\n")
+	defer os.Unsetenv(TokenEnvVar)
+	os.Setenv(UsernameEnvVar, "alice")
+	defer os.Unsetenv(UsernameEnvVar)
+
+	tests := []struct {
+		tokenserver string
+		srvalias    string
+		token       string
+	}{
+		// without GIN_TOKEN_SERVER, the token belongs to the server 'gin'
+		{"", "gin", "envtoken"},
+		{"", "other", ""},
+		{"other", "other", "envtoken"},
+		{"other", "gin", ""},
+	}
+	for _, test := range tests {
+		os.Setenv(TokenServerEnvVar, test.tokenserver)
+		token, username := EnvToken(test.srvalias)
+		if token != test.token || (token != "" && username != "alice") || (token == "" && username != "") {
+			t.Errorf("EnvToken(%q) with %s=%q returned (%q, %q)", test.srvalias, TokenServerEnvVar, test.tokenserver, token, username)
+		}
+	}
+	os.Unsetenv(TokenServerEnvVar)
+
+	var ut UserToken
+	if err := ut.LoadToken("gin"); err != nil || ut.Token != "envtoken" || ut.Username != "alice" {
+		t.Fatalf("Token not loaded from environment: %+v (%v)", ut, err)
+	}
+
+	os.Unsetenv(TokenEnvVar)
+	if token, username := EnvToken("gin"); token != "" || username != "" {
+		t.Fatalf("Unexpected token without %s: (%q, %q)", TokenEnvVar, token, username)
+	}
+}
+
+func TestURLJoin(t *testing.T) {
+	tests := []struct {
+		parts    []string
+		expected string
+	}{
+		{[]string{"https://gin.example.org", "/api/v1/user"}, "https://gin.example.org/api/v1/user"},
+		{[]string{"https://gin.example.org/prefix/", "api/v1", "user"}, "https://gin.example.org/prefix/api/v1/user"},
+		{[]string{"https://gin.example.org", "/api/v1/users/alice/tokens/" + url.PathEscape("name with/slash")}, "https://gin.example.org/api/v1/users/alice/tokens/name%20with%2Fslash"},
+		{[]string{"https://gin.example.org", "/repos/alice/my data/file.txt"}, "https://gin.example.org/repos/alice/my%20data/file.txt"},
+		{[]string{"https://gin.example.org", "/repos/alice/100%.txt"}, "https://gin.example.org/repos/alice/100%25.txt"},
+		{[]string{"https://gin.example.org", "/api/v1/repos/alice/data/git/trees/master?recursive=1"}, "https://gin.example.org/api/v1/repos/alice/data/git/trees/master?recursive=1"},
+	}
+	for _, test := range tests {
+		if joined := urlJoin(test.parts...); joined != test.expected {
+			t.Errorf("urlJoin(%q) = %s, expected %s", test.parts, joined, test.expected)
+		}
+	}
+}